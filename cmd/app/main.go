@@ -17,9 +17,12 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/exchangerates"
 	httpHandler "subscriptions-service/internal/handler/http"
+	"subscriptions-service/internal/model"
 	"subscriptions-service/internal/repository/postgres"
 	"subscriptions-service/internal/service"
+	"subscriptions-service/internal/userservice"
 )
 
 // @title           Subscriptions Service API
@@ -41,7 +44,7 @@ func main() {
 	log.Info("config loaded successfully")
 
 	// Database
-	pool, err := pgxpool.New(context.Background(), cfg.Database.DSN())
+	pool, err := pgxpool.New(context.Background(), cfg.Database.PoolDSN())
 	if err != nil {
 		log.Error("failed to connect to database", "error", err)
 		os.Exit(1)
@@ -53,7 +56,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	log.Info("database connection established")
+	log.Info("database connection established", "query_exec_mode", cfg.Database.QueryExecMode)
 
 	// Migrations
 	m, err := migrate.New(
@@ -72,11 +75,38 @@ func main() {
 	log.Info("migrations applied successfully")
 
 	// Initialize repository, service, handler and router
-	repo := postgres.NewSubscriptionRepository(pool, log)
-	svc := service.NewSubscriptionService(repo, log)
-	h := httpHandler.NewHandler(svc, log)
+	repo := postgres.NewSubscriptionRepository(pool, cfg.Retry, log)
+	catalog := service.NewCatalogService(postgres.NewCatalogRepository(pool, log), cfg.Catalog, log)
+	audit := postgres.NewAuditRepository(pool, log)
+	var userValidator service.UserValidator = &userservice.Stub{}
+	if cfg.UserService.BaseURL != "" {
+		userValidator = userservice.NewClient(cfg.UserService, log)
+	} else {
+		log.Warn("USER_SERVICE_URL is not set, accepting every user")
+	}
+	svc := service.NewSubscriptionService(repo, repo, catalog, audit, userValidator, cfg.Cache, cfg.Cost, cfg.Idempotency, cfg.Archive, cfg.Quota, cfg.Budget, log)
+	users := service.NewUserService(postgres.NewUserRepository(pool, log), log)
+	var rateSource service.RateSource
+	if cfg.Rates.SourceURL != "" {
+		rateSource = exchangerates.NewClient(cfg.Rates)
+	}
+	rates := service.NewExchangeRateService(postgres.NewExchangeRateRepository(pool, log), rateSource, log)
+	h := httpHandler.NewHandler(svc, catalog, users, rates, cfg.Pagination, cfg.Concurrency, cfg.Currency, log)
 	router := h.InitRoutes()
 
+	// Background jobs. They work across tenants and their changes are
+	// attributed to the service itself.
+	jobsCtx := model.WithActor(model.WithAllTenants(context.Background()), model.Actor{Name: model.SystemActor})
+	jobsCtx, stopJobs := context.WithCancel(jobsCtx)
+	defer stopJobs()
+	go cleanupIdempotencyKeys(jobsCtx, svc, log)
+	go purgeDeletedSubscriptions(jobsCtx, svc, cfg.Purge, log)
+	go activateEndedTrials(jobsCtx, svc, log)
+	go expireEndedSubscriptions(jobsCtx, svc, log)
+	if rateSource != nil {
+		go refreshExchangeRates(jobsCtx, rates, cfg.Rates, log)
+	}
+
 	// Server
 	log.Info("starting server", "port", cfg.Server.Port)
 
@@ -111,3 +141,113 @@ func main() {
 
 	log.Info("server exited properly")
 }
+
+// idempotencyKeyCleanupInterval is how often expired idempotency keys are
+// deleted.
+const idempotencyKeyCleanupInterval = time.Hour
+
+// cleanupIdempotencyKeys periodically deletes expired idempotency keys
+// until ctx is canceled.
+func cleanupIdempotencyKeys(ctx context.Context, svc *service.SubscriptionService, log *slog.Logger) {
+	ticker := time.NewTicker(idempotencyKeyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.CleanupIdempotencyKeys(ctx); err != nil {
+				log.Error("failed to clean up idempotency keys", "error", err)
+			}
+		}
+	}
+}
+
+// purgeInterval is how often soft-deleted subscriptions past their
+// retention are purged.
+const purgeInterval = 24 * time.Hour
+
+// purgeDeletedSubscriptions periodically removes subscriptions that were
+// soft-deleted longer than cfg.Retention ago until ctx is canceled.
+func purgeDeletedSubscriptions(ctx context.Context, svc *service.SubscriptionService, cfg config.PurgeConfig, log *slog.Logger) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.PurgeDeleted(ctx, cfg.Retention); err != nil {
+				log.Error("failed to purge deleted subscriptions", "error", err)
+			}
+		}
+	}
+}
+
+// trialActivationInterval is how often trialing subscriptions whose trial
+// is over are moved to active.
+const trialActivationInterval = time.Hour
+
+// activateEndedTrials periodically moves trialing subscriptions whose trial
+// is over to active until ctx is canceled.
+func activateEndedTrials(ctx context.Context, svc *service.SubscriptionService, log *slog.Logger) {
+	ticker := time.NewTicker(trialActivationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.ActivateEndedTrials(ctx); err != nil {
+				log.Error("failed to activate ended trials", "error", err)
+			}
+		}
+	}
+}
+
+// expiryInterval is how often ended subscriptions that do not renew
+// automatically are marked as expired.
+const expiryInterval = time.Hour
+
+// expireEndedSubscriptions periodically marks ended subscriptions that do
+// not renew automatically as expired until ctx is canceled.
+func expireEndedSubscriptions(ctx context.Context, svc *service.SubscriptionService, log *slog.Logger) {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := svc.ExpireEnded(ctx); err != nil {
+				log.Error("failed to expire ended subscriptions", "error", err)
+			}
+		}
+	}
+}
+
+// refreshExchangeRates fetches exchange rates from the configured source
+// right away and then every cfg.RefreshInterval until ctx is canceled.
+func refreshExchangeRates(ctx context.Context, rates *service.ExchangeRateService, cfg config.ExchangeRatesConfig, log *slog.Logger) {
+	if _, err := rates.Refresh(ctx); err != nil {
+		log.Error("failed to refresh exchange rates", "error", err)
+	}
+
+	ticker := time.NewTicker(cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := rates.Refresh(ctx); err != nil {
+				log.Error("failed to refresh exchange rates", "error", err)
+			}
+		}
+	}
+}
@@ -2,18 +2,137 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Pagination  PaginationConfig
+	Cache       CacheConfig
+	Cost        CostConfig
+	Idempotency IdempotencyConfig
+	Purge       PurgeConfig
+	Archive     ArchiveConfig
+	Concurrency ConcurrencyConfig
+	Currency    CurrencyConfig
+	Catalog     CatalogConfig
+	UserService UserServiceConfig
+	Quota       QuotaConfig
+	Budget      BudgetConfig
+	Rates       ExchangeRatesConfig
+	Retry       RetryConfig
 }
 
 type ServerConfig struct {
 	Port int `mapstructure:"port"`
 }
 
+type PaginationConfig struct {
+	DefaultLimit int `mapstructure:"default_limit"`
+	MaxLimit     int `mapstructure:"max_limit"`
+}
+
+// CacheConfig controls in-process caching. A zero TTL disables the cache.
+// Writes only invalidate the cache of the replica that served them, so with
+// more than one replica the others keep serving the old total_cost until
+// the entry expires. Keep TotalCostTTL short enough for that staleness to
+// be acceptable, or disable the cache.
+type CacheConfig struct {
+	TotalCostTTL time.Duration `mapstructure:"total_cost_ttl"`
+}
+
+// CostConfig controls how cost breakdowns are computed. By default months
+// are expanded and summed in Postgres; ExpandInGo loads the subscriptions
+// and expands them in the service instead, which is slower but easier to
+// debug.
+type CostConfig struct {
+	ExpandInGo bool `mapstructure:"expand_in_go"`
+}
+
+// IdempotencyConfig controls how long Idempotency-Key values are honored.
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `mapstructure:"key_ttl"`
+}
+
+// PurgeConfig controls how long soft-deleted subscriptions are kept before
+// the purge job removes them for good.
+type PurgeConfig struct {
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// ArchiveConfig controls how ended subscriptions are moved to the archive.
+// Every batch of BatchSize rows is moved in a transaction of its own, so
+// that locks are held briefly.
+type ArchiveConfig struct {
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// ConcurrencyConfig controls optimistic concurrency on updates. Unless
+// RequireIfMatch is set, updates without an expected version keep
+// last-write-wins semantics.
+type ConcurrencyConfig struct {
+	RequireIfMatch bool `mapstructure:"require_if_match"`
+}
+
+// CurrencyConfig lists the ISO 4217 codes subscriptions may be priced in.
+// Default is used when a new subscription does not name its currency.
+type CurrencyConfig struct {
+	Default string   `mapstructure:"default"`
+	Allowed []string `mapstructure:"allowed"`
+}
+
+// CatalogConfig controls the service catalog. With AutoCreate, creating a
+// subscription to a service missing from the catalog adds it; otherwise
+// such subscriptions are stored without a catalog entry.
+type CatalogConfig struct {
+	AutoCreate bool `mapstructure:"auto_create"`
+}
+
+// UserServiceConfig points at the company-wide user service that user IDs
+// are checked against. Without a BaseURL every user is accepted. FailOpen
+// accepts users while the service is unreachable instead of rejecting the
+// request; CacheSize bounds how many confirmed users are remembered.
+type UserServiceConfig struct {
+	BaseURL   string        `mapstructure:"base_url"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	FailOpen  bool          `mapstructure:"fail_open"`
+	CacheSize int           `mapstructure:"cache_size"`
+}
+
+// QuotaConfig caps the number of active subscriptions a user may have.
+// Tenants may set a limit of their own in the tenant_quotas table. A
+// MaxActivePerUser of zero or less disables the default limit.
+type QuotaConfig struct {
+	MaxActivePerUser int `mapstructure:"max_active_per_user"`
+}
+
+// BudgetConfig controls how budgets are evaluated. A user whose spend in
+// the current month reaches WarningThresholdPercent of their budget gets
+// the warning status.
+type BudgetConfig struct {
+	WarningThresholdPercent int `mapstructure:"warning_threshold_percent"`
+}
+
+// ExchangeRatesConfig controls the optional job fetching exchange rates.
+// Without a SourceURL rates are only set through the admin API.
+type ExchangeRatesConfig struct {
+	SourceURL       string        `mapstructure:"source_url"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+}
+
+// RetryConfig controls how often idempotent database operations are tried
+// when they fail with a transient error. MaxAttempts of 1 or less disables
+// retries; the delay doubles from BaseDelay with every attempt.
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	BaseDelay   time.Duration `mapstructure:"base_delay"`
+}
+
 type DatabaseConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
@@ -21,6 +140,12 @@ type DatabaseConfig struct {
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
+	// QueryExecMode is how pgx runs queries, one of cache_statement,
+	// cache_describe, describe_exec, exec and simple_protocol.
+	// cache_statement prepares every query once per connection; behind a
+	// pooler in transaction mode, such as PgBouncer, that does not keep
+	// prepared statements use describe_exec or exec.
+	QueryExecMode string `mapstructure:"query_exec_mode"`
 }
 
 func (d *DatabaseConfig) DSN() string {
@@ -28,6 +153,16 @@ func (d *DatabaseConfig) DSN() string {
 		d.User, d.Password, d.Host, d.Port, d.DBName, d.SSLMode)
 }
 
+// PoolDSN is DSN with the settings only pgx understands, for the
+// connection pool. Migrations connect with DSN.
+func (d *DatabaseConfig) PoolDSN() string {
+	dsn := d.DSN()
+	if d.QueryExecMode != "" {
+		dsn += "&default_query_exec_mode=" + url.QueryEscape(d.QueryExecMode)
+	}
+	return dsn
+}
+
 func LoadConfig() (*Config, error) {
 	viper.AutomaticEnv()
 
@@ -52,11 +187,118 @@ func LoadConfig() (*Config, error) {
 	if err := viper.BindEnv("database.sslmode", "DB_SSLMODE"); err != nil {
 		return nil, fmt.Errorf("failed to bind database sslmode: %w", err)
 	}
+	if err := viper.BindEnv("database.query_exec_mode", "DB_QUERY_EXEC_MODE"); err != nil {
+		return nil, fmt.Errorf("failed to bind database query exec mode: %w", err)
+	}
+	if err := viper.BindEnv("retry.max_attempts", "DB_RETRY_MAX_ATTEMPTS"); err != nil {
+		return nil, fmt.Errorf("failed to bind retry max attempts: %w", err)
+	}
+	if err := viper.BindEnv("retry.base_delay", "DB_RETRY_BASE_DELAY"); err != nil {
+		return nil, fmt.Errorf("failed to bind retry base delay: %w", err)
+	}
+	if err := viper.BindEnv("pagination.default_limit", "PAGINATION_DEFAULT_LIMIT"); err != nil {
+		return nil, fmt.Errorf("failed to bind pagination default limit: %w", err)
+	}
+	if err := viper.BindEnv("pagination.max_limit", "PAGINATION_MAX_LIMIT"); err != nil {
+		return nil, fmt.Errorf("failed to bind pagination max limit: %w", err)
+	}
+
+	if err := viper.BindEnv("cache.total_cost_ttl", "CACHE_TOTAL_COST_TTL"); err != nil {
+		return nil, fmt.Errorf("failed to bind cache total cost ttl: %w", err)
+	}
+	if err := viper.BindEnv("cost.expand_in_go", "COST_EXPAND_IN_GO"); err != nil {
+		return nil, fmt.Errorf("failed to bind cost expand in go: %w", err)
+	}
+	if err := viper.BindEnv("idempotency.key_ttl", "IDEMPOTENCY_KEY_TTL"); err != nil {
+		return nil, fmt.Errorf("failed to bind idempotency key ttl: %w", err)
+	}
+	if err := viper.BindEnv("purge.retention", "PURGE_RETENTION"); err != nil {
+		return nil, fmt.Errorf("failed to bind purge retention: %w", err)
+	}
+	if err := viper.BindEnv("archive.batch_size", "ARCHIVE_BATCH_SIZE"); err != nil {
+		return nil, fmt.Errorf("failed to bind archive batch size: %w", err)
+	}
+	if err := viper.BindEnv("concurrency.require_if_match", "REQUIRE_IF_MATCH"); err != nil {
+		return nil, fmt.Errorf("failed to bind concurrency require if match: %w", err)
+	}
+	if err := viper.BindEnv("currency.default", "CURRENCY_DEFAULT"); err != nil {
+		return nil, fmt.Errorf("failed to bind currency default: %w", err)
+	}
+	if err := viper.BindEnv("currency.allowed", "CURRENCY_ALLOWED"); err != nil {
+		return nil, fmt.Errorf("failed to bind currency allowed: %w", err)
+	}
+	if err := viper.BindEnv("catalog.auto_create", "CATALOG_AUTO_CREATE"); err != nil {
+		return nil, fmt.Errorf("failed to bind catalog auto create: %w", err)
+	}
+	if err := viper.BindEnv("userservice.base_url", "USER_SERVICE_URL"); err != nil {
+		return nil, fmt.Errorf("failed to bind user service url: %w", err)
+	}
+	if err := viper.BindEnv("userservice.timeout", "USER_SERVICE_TIMEOUT"); err != nil {
+		return nil, fmt.Errorf("failed to bind user service timeout: %w", err)
+	}
+	if err := viper.BindEnv("userservice.fail_open", "USER_SERVICE_FAIL_OPEN"); err != nil {
+		return nil, fmt.Errorf("failed to bind user service fail open: %w", err)
+	}
+	if err := viper.BindEnv("userservice.cache_size", "USER_SERVICE_CACHE_SIZE"); err != nil {
+		return nil, fmt.Errorf("failed to bind user service cache size: %w", err)
+	}
+	if err := viper.BindEnv("quota.max_active_per_user", "QUOTA_MAX_ACTIVE_PER_USER"); err != nil {
+		return nil, fmt.Errorf("failed to bind quota max active per user: %w", err)
+	}
+	if err := viper.BindEnv("budget.warning_threshold_percent", "BUDGET_WARNING_THRESHOLD_PERCENT"); err != nil {
+		return nil, fmt.Errorf("failed to bind budget warning threshold percent: %w", err)
+	}
+	if err := viper.BindEnv("rates.source_url", "EXCHANGE_RATES_SOURCE_URL"); err != nil {
+		return nil, fmt.Errorf("failed to bind exchange rates source url: %w", err)
+	}
+	if err := viper.BindEnv("rates.refresh_interval", "EXCHANGE_RATES_REFRESH_INTERVAL"); err != nil {
+		return nil, fmt.Errorf("failed to bind exchange rates refresh interval: %w", err)
+	}
+	if err := viper.BindEnv("rates.timeout", "EXCHANGE_RATES_TIMEOUT"); err != nil {
+		return nil, fmt.Errorf("failed to bind exchange rates timeout: %w", err)
+	}
+
+	viper.SetDefault("database.query_exec_mode", "cache_statement")
+	viper.SetDefault("retry.max_attempts", 3)
+	viper.SetDefault("retry.base_delay", 100*time.Millisecond)
+	viper.SetDefault("pagination.default_limit", 10)
+	viper.SetDefault("pagination.max_limit", 100)
+	viper.SetDefault("cache.total_cost_ttl", 10*time.Second)
+	viper.SetDefault("cost.expand_in_go", false)
+	viper.SetDefault("idempotency.key_ttl", 24*time.Hour)
+	viper.SetDefault("purge.retention", 90*24*time.Hour)
+	viper.SetDefault("archive.batch_size", 1000)
+	viper.SetDefault("concurrency.require_if_match", false)
+	viper.SetDefault("currency.default", "RUB")
+	viper.SetDefault("currency.allowed", []string{"RUB", "USD", "EUR"})
+	viper.SetDefault("catalog.auto_create", false)
+	viper.SetDefault("userservice.timeout", 2*time.Second)
+	viper.SetDefault("userservice.fail_open", false)
+	viper.SetDefault("userservice.cache_size", 1000)
+	viper.SetDefault("quota.max_active_per_user", 200)
+	viper.SetDefault("budget.warning_threshold_percent", 80)
+	viper.SetDefault("rates.refresh_interval", 24*time.Hour)
+	viper.SetDefault("rates.timeout", 10*time.Second)
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := cfg.Pagination.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pagination config: %w", err)
+	}
 
 	return &cfg, nil
 }
+
+// validate rejects limits that would make every list come back empty, or
+// cap requests below the default page size.
+func (p PaginationConfig) validate() error {
+	if p.DefaultLimit < 1 {
+		return fmt.Errorf("default limit must be at least 1, got %d", p.DefaultLimit)
+	}
+	if p.MaxLimit < p.DefaultLimit {
+		return fmt.Errorf("max limit %d is below the default limit %d", p.MaxLimit, p.DefaultLimit)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestPaginationConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PaginationConfig
+		wantErr bool
+	}{
+		{name: "defaults", cfg: PaginationConfig{DefaultLimit: 10, MaxLimit: 100}},
+		{name: "default equals max", cfg: PaginationConfig{DefaultLimit: 50, MaxLimit: 50}},
+		{name: "zero default", cfg: PaginationConfig{DefaultLimit: 0, MaxLimit: 100}, wantErr: true},
+		{name: "negative default", cfg: PaginationConfig{DefaultLimit: -1, MaxLimit: 100}, wantErr: true},
+		{name: "max below default", cfg: PaginationConfig{DefaultLimit: 20, MaxLimit: 10}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package domain holds the errors shared by the repository, service and
+// handler layers, so that callers can match them without depending on the
+// storage package that returned them.
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidPagination = errors.New("limit and offset must not be negative")
+	ErrCostOverflow      = errors.New("cost overflows int64")
+	ErrConflict          = errors.New("user already has a conflicting subscription to this service")
+	ErrEmptyFilter       = errors.New("filter must name a user")
+	ErrVersionConflict   = errors.New("subscription was modified concurrently")
+	ErrCancelled         = errors.New("subscription is cancelled")
+	ErrUnknownUser       = errors.New("user does not exist")
+	ErrUserExists        = errors.New("a user with this id already exists")
+
+	// ErrNoTenant is returned by queries made without a tenant on the
+	// context, unless the context works across all tenants.
+	ErrNoTenant = errors.New("no tenant on the context")
+
+	ErrCatalogNameTaken = errors.New("a service with this name already exists")
+	ErrCatalogInUse     = errors.New("service is still referenced by subscriptions")
+
+	ErrMemberIsOwner  = errors.New("the owner of a subscription cannot be one of its members")
+	ErrSharesExceeded = errors.New("the shares of the members must not add up to more than 100%")
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed
+	// with a different request than the one it was first used for.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used with a different request")
+
+	ErrForeignKeyViolation = errors.New("a referenced record does not exist")
+	ErrInvalidInput        = errors.New("invalid input value")
+	ErrTimeout             = errors.New("database statement timed out")
+
+	// ErrValidation is matched by every model.ValidationError.
+	ErrValidation = errors.New("validation failed")
+)
+
+// ConflictError is returned when a write would give a user a second active
+// subscription to the same service, or a second one starting in the same
+// month. It matches ErrConflict.
+type ConflictError struct {
+	ExistingID uuid.UUID
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConflict, e.ExistingID)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// BulkItemError wraps the error that made the item at Index of a bulk write
+// fail.
+type BulkItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err)
+}
+
+func (e *BulkItemError) Unwrap() error {
+	return e.Err
+}
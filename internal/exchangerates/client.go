@@ -0,0 +1,61 @@
+// Package exchangerates fetches exchange rates from an HTTP source.
+package exchangerates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+)
+
+// Client fetches the rates published at a URL in the format of the common
+// free rate APIs:
+//
+//	{"base": "EUR", "date": "2024-03-01", "rates": {"USD": 1.0842, "RUB": 98.51}}
+//
+// Every entry of rates becomes a rate from base to its currency as of
+// date.
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+func NewClient(cfg config.ExchangeRatesConfig) *Client {
+	return &Client{url: cfg.SourceURL, http: &http.Client{Timeout: cfg.Timeout}}
+}
+
+type response struct {
+	Base  string                 `json:"base"`
+	Date  string                 `json:"date"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+func (c *Client) Fetch(ctx context.Context) ([]model.ExchangeRate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("exchangerates: failed to build request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchangerates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchangerates: unexpected status %d", resp.StatusCode)
+	}
+
+	var body response
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("exchangerates: invalid response: %w", err)
+	}
+	rates := make([]model.ExchangeRate, 0, len(body.Rates))
+	for quote, rate := range body.Rates {
+		if quote == body.Base {
+			continue
+		}
+		rates = append(rates, model.ExchangeRate{Base: body.Base, Quote: quote, Rate: rate.String(), AsOf: body.Date})
+	}
+	return rates, nil
+}
@@ -0,0 +1,16 @@
+package http
+
+import (
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordActor attributes the changes made by a request to its caller for
+// the audit log. Until authentication exists every caller is anonymous
+// and only told apart by the client IP.
+func recordActor(c *gin.Context) {
+	actor := model.Actor{Name: model.AnonymousActor, ClientIP: c.ClientIP()}
+	c.Request = c.Request.WithContext(model.WithActor(c.Request.Context(), actor))
+	c.Next()
+}
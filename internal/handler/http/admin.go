@@ -0,0 +1,335 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetCostReport godoc
+// @Summary      Get cost report for all users
+// @Description  Get what every user pays within a period, paginated over users. Every user is counted like their total_cost for the period: trial months are free, discounts and billing periods apply, and members of a shared subscription pay their share while its owner pays the rest.
+// @Tags         admin
+// @Produce      json
+// @Param        currency   query string true  "ISO 4217 currency to report"
+// @Param        start_date query string true  "Start Date (MM-YYYY)"
+// @Param        end_date   query string true  "End Date (MM-YYYY)"
+// @Param        limit      query int    false "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset     query int    false "Offset"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.UserCost
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/reports/costs [get]
+func (h *Handler) GetCostReport(c *gin.Context) {
+	h.log.Info("handler: getting cost report")
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := parseMonthYear("start_date", c.Query("start_date"))
+	if err != nil {
+		h.log.Error("invalid start_date", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseMonthYear("end_date", c.Query("end_date"))
+	if err != nil {
+		h.log.Error("invalid end_date", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return
+	}
+
+	limit, offset, err := h.parsePagination(c)
+	if err != nil {
+		h.log.Error("invalid pagination", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.GetCostReport(c.Request.Context(), currency, from, to, limit, offset)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPagination) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrInvalidPagination.Error()})
+			return
+		}
+		h.log.Error("failed to get cost report", "error", err)
+		h.serverError(c, err, "failed to get cost report")
+		return
+	}
+
+	h.log.Info("handler: got cost report", "users", len(report))
+	c.JSON(http.StatusOK, report)
+}
+
+// AdminList godoc
+// @Summary      List subscriptions including deleted ones
+// @Description  Get a list of subscriptions like GET /subscriptions. With include_deleted=true soft-deleted subscriptions are returned as well, carrying their deleted_at.
+// @Tags         admin
+// @Produce      json,text/csv
+// @Param        user_id query []string false "User IDs (repeated or comma-separated)" collectionFormat(multi)
+// @Param        service_name query string false "Service name (case-insensitive)"
+// @Param        status query string false "Status" Enums(active, trialing, paused, cancelled, expired)
+// @Param        plan query string false "Plan (case-insensitive)"
+// @Param        include_deleted query bool false "Include soft-deleted subscriptions"
+// @Param        limit query int false "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset query int false "Offset"
+// @Param        fields query string false "Comma-separated list of fields to return, or of fields to leave out when prefixed with -"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      406  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions [get]
+func (h *Handler) AdminList(c *gin.Context) {
+	h.log.Info("handler: listing subscriptions for admin")
+	userIDs, err := parseUserIDs(c.QueryArray("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.list(c, model.SubscriptionFilter{UserIDs: userIDs, IncludeDeleted: c.Query("include_deleted") == "true"})
+}
+
+// AdminGetByID godoc
+// @Summary      Get a subscription by ID including deleted ones
+// @Description  Get a single subscription like GET /subscriptions/{id}. With include_deleted=true a soft-deleted subscription is returned as well, carrying its deleted_at.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "Subscription ID"
+// @Param        include_deleted query bool false "Include soft-deleted subscriptions"
+// @Param        fields query   string  false "Comma-separated list of fields to return, or of fields to leave out when prefixed with -"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions/{id} [get]
+func (h *Handler) AdminGetByID(c *gin.Context) {
+	h.getByID(c, c.Query("include_deleted") == "true")
+}
+
+// defaultMinGroupSize is the smallest duplicate group reported unless
+// min_group_size says otherwise.
+const defaultMinGroupSize = 2
+
+// GetDuplicates godoc
+// @Summary      Find duplicate subscriptions
+// @Description  Find groups of subscriptions of the same user to the same service, compared case-insensitively, whose billed periods overlap. Members are chained: each overlaps at least one earlier member of its group. Soft-deleted subscriptions are ignored. Paginated over groups, ordered by user, service and start.
+// @Tags         admin
+// @Produce      json
+// @Param        min_group_size query int false "Smallest group to report, at least 2 (default 2)"
+// @Param        limit          query int false "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset         query int false "Offset"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.DuplicateGroup
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions/duplicates [get]
+func (h *Handler) GetDuplicates(c *gin.Context) {
+	h.log.Info("handler: finding duplicate subscriptions")
+	minGroupSize := defaultMinGroupSize
+	if raw := c.Query("min_group_size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_group_size: must be an integer of at least 2"})
+			return
+		}
+		minGroupSize = n
+	}
+
+	limit, offset, err := h.parsePagination(c)
+	if err != nil {
+		h.log.Error("invalid pagination", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	groups, err := h.service.GetDuplicateGroups(c.Request.Context(), minGroupSize, limit, offset)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPagination) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrInvalidPagination.Error()})
+			return
+		}
+		h.log.Error("failed to find duplicate subscriptions", "error", err)
+		h.serverError(c, err, "failed to find duplicate subscriptions")
+		return
+	}
+
+	h.log.Info("handler: found duplicate subscriptions", "groups", len(groups))
+	c.JSON(http.StatusOK, groups)
+}
+
+// Reprice godoc
+// @Summary      Change the price of a service
+// @Description  Set a new price on every subscription to a service priced in currency, optionally only those of one user. Subscriptions in other currencies keep their price. Unless effective_only_active is false, subscriptions that ended before the current month keep their price.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        input body model.RepriceRequest true "New price"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.RepriceResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions/reprice [post]
+func (h *Handler) Reprice(c *gin.Context) {
+	h.log.Info("handler: repricing subscriptions")
+	var req model.RepriceRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.ServiceName) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service_name must not be empty"})
+		return
+	}
+	if req.UserID != nil && *req.UserID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if strings.TrimSpace(req.Currency) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must not be empty"})
+		return
+	}
+	currency, err := h.parseCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	price, err := model.ResolvePrice(req.NewPriceMinor, req.NewPriceDecimal)
+	if err == nil && price == nil {
+		err = errors.New("new_price_minor or new_price_decimal is required")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	onlyActive := req.EffectiveOnlyActive == nil || *req.EffectiveOnlyActive
+
+	updated, err := h.service.Reprice(c.Request.Context(), req.ServiceName, currency, req.UserID, *price, onlyActive)
+	if err != nil {
+		h.log.Error("failed to reprice subscriptions", "error", err)
+		h.serverError(c, err, "failed to reprice subscriptions")
+		return
+	}
+
+	h.log.Info("handler: repriced subscriptions", "updated", updated)
+	c.JSON(http.StatusOK, model.RepriceResponse{Updated: updated})
+}
+
+// Purge godoc
+// @Summary      Purge deleted subscriptions
+// @Description  Permanently remove the subscriptions that were deleted more than older_than_days days ago. A background job does the same daily with the configured retention.
+// @Tags         admin
+// @Produce      json
+// @Param        older_than_days query int true "Minimum age of the deletion in days"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.PurgeResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions/purge [post]
+func (h *Handler) Purge(c *gin.Context) {
+	h.log.Info("handler: purging deleted subscriptions")
+	days, err := strconv.Atoi(c.Query("older_than_days"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "older_than_days must be a positive integer"})
+		return
+	}
+
+	purged, err := h.service.PurgeDeleted(c.Request.Context(), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		h.log.Error("failed to purge deleted subscriptions", "error", err)
+		h.serverError(c, err, "failed to purge deleted subscriptions")
+		return
+	}
+
+	h.log.Info("handler: purged deleted subscriptions", "purged", purged)
+	c.JSON(http.StatusOK, model.PurgeResponse{Purged: purged})
+}
+
+// Archive godoc
+// @Summary      Archive ended subscriptions
+// @Description  Move the subscriptions that ended before the given month to the archive, in batches of the configured size. Auto-renewing subscriptions that are still running are kept. Archived subscriptions are no longer listed and only count towards total costs requested with include_archived=true.
+// @Tags         admin
+// @Produce      json
+// @Param        older_than query string true "Month (MM-YYYY) the subscriptions must have ended before, at most the current month"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.ArchiveResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/subscriptions/archive [post]
+func (h *Handler) Archive(c *gin.Context) {
+	h.log.Info("handler: archiving subscriptions")
+	cutoff, err := parseMonthYear("older_than", c.Query("older_than"))
+	if err != nil {
+		h.log.Error("invalid older_than", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	archived, err := h.service.Archive(c.Request.Context(), cutoff)
+	if err != nil {
+		var validationErr model.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.log.Error("failed to archive subscriptions", "error", err, "archived", archived)
+		h.serverError(c, err, "failed to archive subscriptions")
+		return
+	}
+
+	h.log.Info("handler: archived subscriptions", "archived", archived)
+	c.JSON(http.StatusOK, model.ArchiveResponse{Archived: archived})
+}
+
+// GetAnonymization godoc
+// @Summary      Look up an anonymized user
+// @Description  Get the synthetic user the subscriptions of an anonymized user were moved to. Users anonymized irreversibly are not found.
+// @Tags         admin
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Anonymization
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/anonymizations/{user_id} [get]
+func (h *Handler) GetAnonymization(c *gin.Context) {
+	h.log.Info("handler: getting anonymization", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	anonymization, err := h.service.GetAnonymization(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "anonymization not found"})
+			return
+		}
+		h.log.Error("failed to get anonymization", "error", err)
+		h.serverError(c, err, "failed to get anonymization")
+		return
+	}
+
+	c.JSON(http.StatusOK, anonymization)
+}
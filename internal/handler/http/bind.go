@@ -0,0 +1,86 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindJSON decodes the JSON request body into obj and validates it like
+// ShouldBindJSON, but rejects members that obj does not declare. Every
+// handler taking a JSON body binds through here so that typos are reported
+// the same way everywhere. An empty body yields io.EOF.
+func bindJSON(c *gin.Context, obj any) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	return decodeJSON(body, obj)
+}
+
+// decodeJSON is bindJSON for a document that has been read already, such as
+// a single item of an array body.
+func decodeJSON(body []byte, obj any) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return io.EOF
+	}
+
+	// Collect every unknown member up front; the decoder below only
+	// reports the first one.
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) == nil {
+		known := jsonFieldNames(reflect.TypeOf(obj))
+		var unknown []string
+		for name := range members {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			return unknownFieldsError(unknown)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// jsonFieldNames returns the JSON member names of the struct t points to.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+func unknownFieldsError(names []string) error {
+	sort.Strings(names)
+	return fmt.Errorf("unknown fields: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// budgetNotFoundResponse is returned for users without a budget. Its code
+// tells clients apart from other 404s, so that they can offer to create
+// one.
+var budgetNotFoundResponse = gin.H{"error": "budget not found", "code": "budget_not_found"}
+
+// GetBudget godoc
+// @Summary      Get a user's budget
+// @Description  Get the monthly budget of a user along with what they spend in the current month, counted like total_cost for that month in the currency of the budget. remaining is negative and over_budget is set once the spend exceeds the budget.
+// @Tags         users
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.BudgetUsage
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/budget [get]
+func (h *Handler) GetBudget(c *gin.Context) {
+	h.log.Info("handler: getting budget", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	usage, err := h.service.GetBudget(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, budgetNotFoundResponse)
+			return
+		}
+		h.log.Error("failed to get budget", "error", err)
+		h.serverError(c, err, "failed to get budget")
+		return
+	}
+
+	h.log.Info("handler: got budget", "user_id", userID.String())
+	c.JSON(http.StatusOK, usage)
+}
+
+// SetBudget godoc
+// @Summary      Set a user's budget
+// @Description  Create the monthly budget of a user or replace the one they have. A budget below what the user spends in the current month is stored all the same and reported with over_budget set.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        user_id path string                 true "User ID"
+// @Param        input   body model.SetBudgetRequest true "Budget"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.BudgetUsage
+// @Success      201  {object}  model.BudgetUsage
+// @Failure      400  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /users/{user_id}/budget [put]
+func (h *Handler) SetBudget(c *gin.Context) {
+	h.log.Info("handler: setting budget", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var req model.SetBudgetRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	currency, err := h.parseCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Period != "" && req.Period != model.BudgetPeriodMonthly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period: only monthly budgets are supported"})
+		return
+	}
+
+	budget := &model.Budget{UserID: userID, AmountMinor: *req.AmountMinor, Currency: currency, Period: model.BudgetPeriodMonthly}
+	usage, created, err := h.service.SetBudget(c.Request.Context(), budget)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUnknownUser):
+			h.log.Warn("unknown user", "user_id", userID.String())
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": unknownUserMessage})
+		case errors.Is(err, service.ErrUserServiceUnavailable):
+			h.log.Error("failed to validate user", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+		default:
+			h.log.Error("failed to set budget", "error", err)
+			h.serverError(c, err, "failed to set budget")
+		}
+		return
+	}
+
+	h.log.Info("handler: set budget", "user_id", userID.String(), "created", created, "over_budget", usage.OverBudget)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, usage)
+}
+
+// GetBudgetStatus godoc
+// @Summary      Evaluate a user's budget
+// @Description  Compare what a user spends in the current month with their budget. The spend is counted exactly like total_cost for the current month in the currency of the budget. The status is exceeded once the spend is above the budget, warning once it reaches BUDGET_WARNING_THRESHOLD_PERCENT of it, and ok otherwise. Users without a budget get a 404 with code budget_not_found.
+// @Tags         users
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.BudgetStatus
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/budget/status [get]
+func (h *Handler) GetBudgetStatus(c *gin.Context) {
+	h.log.Info("handler: evaluating budget", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	status, err := h.service.GetBudgetStatus(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, budgetNotFoundResponse)
+			return
+		}
+		h.log.Error("failed to evaluate budget", "error", err)
+		h.serverError(c, err, "failed to evaluate budget")
+		return
+	}
+
+	h.log.Info("handler: evaluated budget", "user_id", userID.String(), "status", status.Status)
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxBulkItems caps the number of subscriptions accepted by a single bulk
+// creation.
+const maxBulkItems = 500
+
+// BulkCreate godoc
+// @Summary      Create subscriptions in bulk
+// @Description  Create up to 500 subscriptions at once. Every item is validated first and any invalid item rejects the whole request. By default the items are inserted in a single transaction, and a conflict fails all of them naming the index of the item when the database tells which one it was; with atomic=false each valid item is inserted on its own and failures are reported per index.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input  body  []model.CreateSubscriptionRequest true "Subscriptions"
+// @Param        atomic query bool false "Insert all items or none (default true)"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.BulkCreateResponse
+// @Success      201  {object}  model.BulkCreateResponse
+// @Failure      400  {object}  model.BulkCreateResponse
+// @Failure      409  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /subscriptions/bulk [post]
+func (h *Handler) BulkCreate(c *gin.Context) {
+	h.log.Info("handler: creating subscriptions in bulk")
+	atomic := c.Query("atomic") != "false"
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.log.Error("failed to read request body", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be a JSON array"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one subscription is required"})
+		return
+	}
+	if len(items) > maxBulkItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many subscriptions, maximum is %d", maxBulkItems)})
+		return
+	}
+
+	resp := model.BulkCreateResponse{IDs: make([]*uuid.UUID, len(items)), Errors: make([]model.BulkItemError, 0)}
+	subs := make([]model.Subscription, 0, len(items))
+	for i, item := range items {
+		var req model.CreateSubscriptionRequest
+		if err := decodeJSON(item, &req); err != nil {
+			resp.Errors = append(resp.Errors, model.BulkItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		currency, err := h.parseCurrency(req.Currency)
+		if err != nil {
+			resp.Errors = append(resp.Errors, model.BulkItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		price, err := requiredPrice(req.PriceMinor, req.PriceDecimal)
+		if err != nil {
+			resp.Errors = append(resp.Errors, model.BulkItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		sub := model.Subscription{
+			ServiceName:     req.ServiceName,
+			Plan:            req.Plan,
+			PriceMinor:      price,
+			Currency:        currency,
+			BillingPeriod:   req.BillingPeriod,
+			UserID:          req.UserID,
+			StartDate:       *req.StartDate,
+			EndDate:         req.EndDate,
+			AutoRenew:       model.AutoRenewOrDefault(req.AutoRenew),
+			TrialEndDate:    req.TrialEndDate,
+			DiscountPercent: req.DiscountPercent,
+			DiscountUntil:   req.DiscountUntil,
+			Metadata:        req.Metadata,
+			Notes:           req.Notes,
+			Status:          req.InitialStatus(),
+		}
+		if err := sub.Validate(); err != nil {
+			resp.Errors = append(resp.Errors, model.BulkItemError{Index: i, Error: err.Error()})
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	if len(resp.Errors) > 0 {
+		h.log.Warn("invalid subscriptions in bulk request", "rejected", len(resp.Errors))
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	ids, errs, err := h.service.BulkCreate(c.Request.Context(), subs, atomic)
+	if err != nil {
+		// The failing item is named when the database tells which one it
+		// was; deferred checks such as foreign keys do not.
+		var itemErr *domain.BulkItemError
+		if errors.Is(err, domain.ErrConflict) {
+			h.log.Warn("duplicate active subscription", "error", err)
+			body := conflictResponse(err)
+			if errors.As(err, &itemErr) {
+				body["index"] = itemErr.Index
+			}
+			c.JSON(http.StatusConflict, body)
+			return
+		}
+		if errors.Is(err, domain.ErrUnknownUser) {
+			h.log.Warn("unknown user in bulk request", "error", err)
+			body := gin.H{"error": unknownUserMessage}
+			if errors.As(err, &itemErr) {
+				body["index"] = itemErr.Index
+			}
+			c.JSON(http.StatusUnprocessableEntity, body)
+			return
+		}
+		var quotaErr *model.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.log.Warn("subscription quota exceeded", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, quotaResponse(quotaErr))
+			return
+		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			h.log.Error("failed to validate users", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+			return
+		}
+		h.log.Error("failed to create subscriptions", "error", err)
+		h.serverError(c, err, "failed to create subscriptions")
+		return
+	}
+
+	for i := range ids {
+		if errs != nil && errs[i] != nil {
+			resp.Errors = append(resp.Errors, model.BulkItemError{Index: i, Error: bulkItemErrorMessage(errs[i])})
+			continue
+		}
+		resp.IDs[i] = &ids[i]
+		resp.Created++
+	}
+
+	h.log.Info("handler: created subscriptions in bulk", "created", resp.Created, "failed", len(resp.Errors))
+	status := http.StatusCreated
+	if len(resp.Errors) > 0 {
+		status = http.StatusOK
+	}
+	c.JSON(status, resp)
+}
+
+// bulkItemErrorMessage renders the failure of a single item of a
+// non-atomic bulk creation without leaking database details.
+func bulkItemErrorMessage(err error) string {
+	var conflict *domain.ConflictError
+	var quota *model.QuotaExceededError
+	switch {
+	case errors.As(err, &conflict):
+		return conflict.Error()
+	case errors.Is(err, domain.ErrConflict):
+		return domain.ErrConflict.Error()
+	case errors.As(err, &quota):
+		return quota.Error()
+	case errors.Is(err, domain.ErrUnknownUser):
+		return unknownUserMessage
+	case errors.Is(err, service.ErrUserServiceUnavailable):
+		return service.ErrUserServiceUnavailable.Error()
+	default:
+		return "failed to create subscription"
+	}
+}
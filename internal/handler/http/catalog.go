@@ -0,0 +1,236 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CatalogService interface {
+	Create(ctx context.Context, entry *model.CatalogEntry) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.CatalogEntry, error)
+	List(ctx context.Context, limit, offset int) ([]model.CatalogEntry, error)
+	Update(ctx context.Context, entry *model.CatalogEntry) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// catalogEntryFromRequest validates req and converts it into a catalog
+// entry.
+func catalogEntryFromRequest(req model.CatalogEntryRequest) (*model.CatalogEntry, error) {
+	entry := &model.CatalogEntry{
+		Name:     strings.TrimSpace(req.Name),
+		URL:      req.URL,
+		Category: req.Category,
+	}
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// CreateCatalogEntry godoc
+// @Summary      Add a service to the catalog
+// @Description  Add a service to the catalog. Names are unique case-insensitively. New subscriptions to a service with this name are linked to the entry.
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        input body model.CatalogEntryRequest true "Service Info"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      201  {object}  model.CatalogEntry
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services [post]
+func (h *Handler) CreateCatalogEntry(c *gin.Context) {
+	h.log.Info("handler: creating catalog entry")
+	var req model.CatalogEntryRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := catalogEntryFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.catalog.Create(c.Request.Context(), entry); err != nil {
+		if errors.Is(err, domain.ErrCatalogNameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrCatalogNameTaken.Error()})
+			return
+		}
+		h.log.Error("failed to create catalog entry", "error", err)
+		h.serverError(c, err, "failed to create service")
+		return
+	}
+
+	h.log.Info("handler: created catalog entry", "id", entry.ID)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// ListCatalogEntries godoc
+// @Summary      List the service catalog
+// @Description  Get the services in the catalog ordered by name
+// @Tags         services
+// @Produce      json
+// @Param        limit query int false "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset query int false "Offset"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.CatalogEntry
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services [get]
+func (h *Handler) ListCatalogEntries(c *gin.Context) {
+	h.log.Info("handler: listing catalog entries")
+	limit, offset, err := h.parsePagination(c)
+	if err != nil {
+		h.log.Error("invalid pagination", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := h.catalog.List(c.Request.Context(), limit, offset)
+	if err != nil {
+		h.log.Error("failed to list catalog entries", "error", err)
+		h.serverError(c, err, "failed to list services")
+		return
+	}
+
+	h.log.Info("handler: listed catalog entries", "count", len(entries))
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetCatalogEntry godoc
+// @Summary      Get a service from the catalog
+// @Description  Get a single catalog entry by its ID
+// @Tags         services
+// @Produce      json
+// @Param        id   path      string  true  "Service ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.CatalogEntry
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services/{id} [get]
+func (h *Handler) GetCatalogEntry(c *gin.Context) {
+	h.log.Info("handler: getting catalog entry", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+
+	entry, err := h.catalog.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+			return
+		}
+		h.log.Error("failed to get catalog entry", "error", err)
+		h.serverError(c, err, "failed to get service")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// UpdateCatalogEntry godoc
+// @Summary      Update a service in the catalog
+// @Description  Replace the name, url and category of a catalog entry. Subscriptions linked to the entry take over the new name.
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Service ID"
+// @Param        input body model.CatalogEntryRequest true "Service Info"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.CatalogEntry
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services/{id} [put]
+func (h *Handler) UpdateCatalogEntry(c *gin.Context) {
+	h.log.Info("handler: updating catalog entry", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+	var req model.CatalogEntryRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := catalogEntryFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry.ID = id
+
+	if err := h.catalog.Update(c.Request.Context(), entry); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+		case errors.Is(err, domain.ErrCatalogNameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrCatalogNameTaken.Error()})
+		case errors.Is(err, domain.ErrConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "renaming the service would give a user conflicting subscriptions"})
+		default:
+			h.log.Error("failed to update catalog entry", "error", err)
+			h.serverError(c, err, "failed to update service")
+		}
+		return
+	}
+
+	h.log.Info("handler: updated catalog entry", "id", id.String())
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteCatalogEntry godoc
+// @Summary      Remove a service from the catalog
+// @Description  Remove a catalog entry. Entries still referenced by subscriptions, including deleted ones, cannot be removed.
+// @Tags         services
+// @Param        id   path      string  true  "Service ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services/{id} [delete]
+func (h *Handler) DeleteCatalogEntry(c *gin.Context) {
+	h.log.Info("handler: deleting catalog entry", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+
+	if err := h.catalog.Delete(c.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+		case errors.Is(err, domain.ErrCatalogInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrCatalogInUse.Error()})
+		default:
+			h.log.Error("failed to delete catalog entry", "error", err)
+			h.serverError(c, err, "failed to delete service")
+		}
+		return
+	}
+
+	h.log.Info("handler: deleted catalog entry", "id", id.String())
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"subscriptions-service/internal/model"
+	"time"
+	"unicode/utf8"
+)
+
+// mimeCSV is the media type of CSV responses.
+const mimeCSV = "text/csv"
+
+// csvColumns are the columns written by every CSV response and understood
+// by the import endpoint.
+var csvColumns = []string{"id", "service_name", "service_id", "plan", "price_minor", "price_decimal", "currency", "billing_period", "user_id", "start_date", "end_date", "auto_renew", "trial_end_date", "discount_percent", "discount_until", "metadata", "notes", "created_at", "updated_at"}
+
+// csvRecord renders the given columns of sub as a CSV record. Unknown
+// columns are rendered empty.
+func csvRecord(sub model.Subscription, columns []string) []string {
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			record[i] = sub.ID.String()
+		case "service_name":
+			record[i] = sub.ServiceName
+		case "service_id":
+			if sub.ServiceID != nil {
+				record[i] = sub.ServiceID.String()
+			}
+		case "plan":
+			if sub.Plan != nil {
+				record[i] = *sub.Plan
+			}
+		case "price_minor":
+			record[i] = strconv.Itoa(sub.PriceMinor)
+		case "price_decimal":
+			record[i] = model.FormatPriceDecimal(sub.PriceMinor)
+		case "currency":
+			record[i] = sub.Currency
+		case "billing_period":
+			record[i] = sub.BillingPeriod
+		case "user_id":
+			record[i] = sub.UserID.String()
+		case "start_date":
+			record[i] = sub.StartDate.String()
+		case "end_date":
+			if sub.EndDate != nil {
+				record[i] = sub.EndDate.String()
+			}
+		case "auto_renew":
+			record[i] = strconv.FormatBool(sub.AutoRenew)
+		case "trial_end_date":
+			if sub.TrialEndDate != nil {
+				record[i] = sub.TrialEndDate.String()
+			}
+		case "discount_percent":
+			if sub.DiscountPercent != nil {
+				record[i] = strconv.Itoa(*sub.DiscountPercent)
+			}
+		case "discount_until":
+			if sub.DiscountUntil != nil {
+				record[i] = sub.DiscountUntil.String()
+			}
+		case "metadata":
+			if len(sub.Metadata) > 0 {
+				data, _ := json.Marshal(sub.Metadata)
+				record[i] = string(data)
+			}
+		case "notes":
+			if sub.Notes != nil {
+				record[i] = *sub.Notes
+			}
+		case "created_at":
+			record[i] = sub.CreatedAt.Format(time.RFC3339)
+		case "updated_at":
+			record[i] = sub.UpdatedAt.Format(time.RFC3339)
+		case "deleted_at":
+			if sub.DeletedAt != nil {
+				record[i] = sub.DeletedAt.Format(time.RFC3339)
+			}
+		case "version":
+			record[i] = strconv.Itoa(sub.Version)
+		case "status":
+			record[i] = sub.Status
+		case "cancelled_at":
+			if sub.CancelledAt != nil {
+				record[i] = sub.CancelledAt.Format(time.RFC3339)
+			}
+		case "anonymized_at":
+			if sub.AnonymizedAt != nil {
+				record[i] = sub.AnonymizedAt.Format(time.RFC3339)
+			}
+		}
+	}
+	return record
+}
+
+// csvRequiredColumns must be present in an imported CSV header, along with
+// either price_minor or price_decimal.
+var csvRequiredColumns = []string{"service_name", "user_id", "start_date"}
+
+// csvHeader maps column names to their position in a CSV header row and
+// checks that every required column is present.
+func csvHeader(header []string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range csvRequiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing required column %q", name)
+		}
+	}
+	_, hasMinor := index["price_minor"]
+	_, hasDecimal := index["price_decimal"]
+	if !hasMinor && !hasDecimal {
+		return nil, errors.New(`missing required column "price_minor" or "price_decimal"`)
+	}
+	return index, nil
+}
+
+// subscriptionFromCSV validates a CSV record and converts it into a
+// subscription ready to be stored. Columns not used for creation, such as
+// id and created_at, are ignored. parseCurrency resolves the optional
+// currency column.
+func subscriptionFromCSV(index map[string]int, record []string, parseCurrency func(string) (string, error)) (model.Subscription, error) {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var sub model.Subscription
+
+	sub.ServiceName = field("service_name")
+	if sub.ServiceName == "" {
+		return sub, errors.New("service_name is required")
+	}
+	if raw := field("plan"); raw != "" {
+		if utf8.RuneCountInString(raw) > model.MaxPlanLength {
+			return sub, fmt.Errorf("plan must not exceed %d characters", model.MaxPlanLength)
+		}
+		sub.Plan = &raw
+	}
+
+	// A row may fill in either price column; when both are filled in,
+	// price_minor wins.
+	if raw := field("price_minor"); raw != "" {
+		price, err := strconv.Atoi(raw)
+		if err != nil || price < 0 {
+			return sub, fmt.Errorf("invalid price_minor %q: must be a non-negative integer", raw)
+		}
+		sub.PriceMinor = price
+	} else if raw := field("price_decimal"); raw != "" {
+		price, err := model.ParsePriceDecimal(raw)
+		if err != nil {
+			return sub, err
+		}
+		sub.PriceMinor = price
+	} else {
+		return sub, errors.New("price_minor or price_decimal is required")
+	}
+
+	var err error
+	if sub.Currency, err = parseCurrency(field("currency")); err != nil {
+		return sub, err
+	}
+	sub.BillingPeriod = strings.ToLower(field("billing_period"))
+	if sub.BillingPeriod != "" && !model.IsValidBillingPeriod(sub.BillingPeriod) {
+		return sub, fmt.Errorf("invalid billing_period %q: must be monthly, yearly or weekly", sub.BillingPeriod)
+	}
+
+	sub.UserID, err = parseUserID(field("user_id"))
+	if err != nil {
+		return sub, fmt.Errorf("invalid user_id %q", field("user_id"))
+	}
+
+	start, err := model.ParseMonthYear(field("start_date"))
+	if err != nil {
+		return sub, fmt.Errorf("invalid start_date %q: expected MM-YYYY or YYYY-MM", field("start_date"))
+	}
+	sub.StartDate = model.NewMonthYear(start)
+
+	if raw := field("end_date"); raw != "" {
+		end, err := model.ParseMonthYear(raw)
+		if err != nil {
+			return sub, fmt.Errorf("invalid end_date %q: expected MM-YYYY or YYYY-MM", raw)
+		}
+		if end.Before(start) {
+			return sub, errors.New("end_date must not be before start_date")
+		}
+		endDate := model.NewMonthYear(end)
+		sub.EndDate = &endDate
+	}
+
+	sub.AutoRenew = true
+	if raw := field("auto_renew"); raw != "" {
+		autoRenew, err := strconv.ParseBool(raw)
+		if err != nil {
+			return sub, fmt.Errorf("invalid auto_renew %q: expected true or false", raw)
+		}
+		sub.AutoRenew = autoRenew
+	}
+
+	if raw := field("trial_end_date"); raw != "" {
+		trialEnd, err := model.ParseMonthYear(raw)
+		if err != nil {
+			return sub, fmt.Errorf("invalid trial_end_date %q: expected MM-YYYY or YYYY-MM", raw)
+		}
+		if trialEnd.Before(start) {
+			return sub, errors.New("trial_end_date must not be before start_date")
+		}
+		if sub.EndDate != nil && sub.EndDate.Time().Before(trialEnd) {
+			return sub, errors.New("trial_end_date must not be after end_date")
+		}
+		trialEndDate := model.NewMonthYear(trialEnd)
+		sub.TrialEndDate = &trialEndDate
+		sub.Status = model.StatusTrialing
+	}
+
+	if raw := field("discount_percent"); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || percent < 0 || percent > 100 {
+			return sub, fmt.Errorf("invalid discount_percent %q: expected an integer between 0 and 100", raw)
+		}
+		sub.DiscountPercent = &percent
+	}
+	if raw := field("discount_until"); raw != "" {
+		until, err := model.ParseMonthYear(raw)
+		if err != nil {
+			return sub, fmt.Errorf("invalid discount_until %q: expected MM-YYYY or YYYY-MM", raw)
+		}
+		if until.Before(start) {
+			return sub, errors.New("discount_until must not be before start_date")
+		}
+		if sub.EndDate != nil && sub.EndDate.Time().Before(until) {
+			return sub, errors.New("discount_until must not be after end_date")
+		}
+		discountUntil := model.NewMonthYear(until)
+		sub.DiscountUntil = &discountUntil
+	}
+	if (sub.DiscountPercent == nil) != (sub.DiscountUntil == nil) {
+		return sub, errors.New("discount_percent and discount_until must be set together")
+	}
+
+	if raw := field("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &sub.Metadata); err != nil {
+			return sub, fmt.Errorf("invalid metadata %q: expected a JSON object with string values", raw)
+		}
+		if err := model.ValidateMetadata(sub.Metadata); err != nil {
+			return sub, err
+		}
+	}
+
+	if raw := field("notes"); raw != "" {
+		if utf8.RuneCountInString(raw) > model.MaxNotesLength {
+			return sub, fmt.Errorf("notes must not exceed %d characters", model.MaxNotesLength)
+		}
+		sub.Notes = &raw
+	}
+
+	return sub, nil
+}
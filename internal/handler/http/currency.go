@@ -0,0 +1,34 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseCurrency normalizes an ISO 4217 code taken from a request and checks
+// it against the configured allow-list. An empty code yields the default
+// currency.
+func (h *Handler) parseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return h.currency.Default, nil
+	}
+	if !slices.Contains(h.currency.Allowed, code) {
+		return "", fmt.Errorf("unsupported currency %q, supported values: %s", code, strings.Join(h.currency.Allowed, ", "))
+	}
+	return code, nil
+}
+
+// requiredCurrency parses the currency query parameter of the endpoints
+// reporting amounts in a single currency, which cannot fall back to the
+// default: prices in different currencies are never added up.
+func (h *Handler) requiredCurrency(c *gin.Context) (string, error) {
+	if strings.TrimSpace(c.Query("currency")) == "" {
+		return "", errors.New("currency is required, amounts in different currencies are never added up")
+	}
+	return h.parseCurrency(c.Query("currency"))
+}
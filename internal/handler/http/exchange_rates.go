@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExchangeRateService interface {
+	Upsert(ctx context.Context, rates []model.ExchangeRate) ([]model.ExchangeRate, error)
+	Convert(ctx context.Context, totals map[string]int64, target string) (*model.ConvertedTotal, error)
+}
+
+// UpsertExchangeRates godoc
+// @Summary      Store exchange rates
+// @Description  Store exchange rates, replacing the rates of the same pairs and days. A rate says that one unit of base is worth rate units of quote as of the day as_of. Of several rates for the same pair and day the last one wins. Rates are shared by all tenants.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        input body model.UpsertExchangeRatesRequest true "Exchange rates"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.ExchangeRate
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/exchange_rates [put]
+func (h *Handler) UpsertExchangeRates(c *gin.Context) {
+	h.log.Info("handler: upserting exchange rates")
+	var req model.UpsertExchangeRatesRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates, err := h.rates.Upsert(c.Request.Context(), req.Rates)
+	if err != nil {
+		var validationErr model.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		h.log.Error("failed to upsert exchange rates", "error", err)
+		h.serverError(c, err, "failed to upsert exchange rates")
+		return
+	}
+
+	h.log.Info("handler: upserted exchange rates", "count", len(rates))
+	c.JSON(http.StatusOK, rates)
+}
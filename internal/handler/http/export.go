@@ -0,0 +1,75 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushEvery is the number of rows written between flushes.
+const exportFlushEvery = 500
+
+// Export godoc
+// @Summary      Export subscriptions
+// @Description  Stream all subscriptions as newline-delimited JSON (one subscription per line) or as CSV
+// @Tags         subscriptions
+// @Produce      application/x-ndjson,text/csv
+// @Param        format query string false "Export format" Enums(ndjson, csv)
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/export [get]
+func (h *Handler) Export(c *gin.Context) {
+	h.log.Info("handler: exporting subscriptions")
+	var write func(sub model.Subscription) error
+	var flush func()
+
+	switch c.DefaultQuery("format", "ndjson") {
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(sub model.Subscription) error { return enc.Encode(sub) }
+		flush = c.Writer.Flush
+	case "csv":
+		c.Header("Content-Type", mimeCSV)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(csvColumns); err != nil {
+			h.log.Error("failed to write csv header", "error", err)
+			return
+		}
+		write = func(sub model.Subscription) error { return w.Write(csvRecord(sub, csvColumns)) }
+		flush = func() {
+			w.Flush()
+			c.Writer.Flush()
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, supported formats: ndjson, csv"})
+		return
+	}
+	c.Status(http.StatusOK)
+
+	written := 0
+	err := h.service.Export(c.Request.Context(), func(sub model.Subscription) error {
+		if err := write(sub); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so the best we can do is stop the
+		// stream; consumers detect the truncation by the missing rows.
+		h.log.Error("failed to export subscriptions", "error", err, "written", written)
+		return
+	}
+
+	flush()
+	h.log.Info("handler: exported subscriptions", "count", written)
+}
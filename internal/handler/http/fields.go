@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// selectableFields lists, in rendering order, the subscription JSON keys
+// that may be requested through the fields query parameter.
+var selectableFields = []string{
+	"id",
+	"service_name",
+	"service_id",
+	"plan",
+	"price_minor",
+	"price_decimal",
+	"currency",
+	"billing_period",
+	"user_id",
+	"start_date",
+	"end_date",
+	"auto_renew",
+	"trial_end_date",
+	"discount_percent",
+	"discount_until",
+	"effective_price",
+	"next_billing_date",
+	"metadata",
+	"notes",
+	"created_at",
+	"updated_at",
+	"deleted_at",
+	"version",
+	"status",
+	"cancelled_at",
+	"anonymized_at",
+}
+
+// parseFields parses a comma-separated fields parameter. An empty value
+// yields nil, meaning the full object should be returned. Fields prefixed
+// with "-" are excluded instead, e.g. "-notes" selects every field but
+// notes; included and excluded fields cannot be mixed.
+func parseFields(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields, excluded []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, exclude := strings.CutPrefix(field, "-")
+		if !slices.Contains(selectableFields, name) {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if exclude {
+			excluded = append(excluded, name)
+		} else {
+			fields = append(fields, name)
+		}
+	}
+	if len(excluded) == 0 {
+		return fields, nil
+	}
+	if len(fields) > 0 {
+		return nil, errors.New("fields cannot both include and exclude fields")
+	}
+
+	for _, field := range selectableFields {
+		if !slices.Contains(excluded, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// selectFields returns v as a JSON object containing only the given keys.
+// Requested keys that v omits (e.g. an empty end_date) are left out.
+func selectFields(v any, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
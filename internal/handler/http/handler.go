@@ -2,72 +2,230 @@ package http
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
 	"subscriptions-service/internal/model"
-	"subscriptions-service/internal/repository/postgres"
+	"subscriptions-service/internal/service"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
 )
 
 type SubscriptionService interface {
-	Create(ctx context.Context, sub *model.Subscription) (uuid.UUID, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
-	List(ctx context.Context, limit, offset int) ([]model.Subscription, error)
-	Update(ctx context.Context, sub *model.Subscription) error
+	Create(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error)
+	BulkCreate(ctx context.Context, subs []model.Subscription, atomic bool) ([]uuid.UUID, []error, error)
+	CreateIfNotExists(ctx context.Context, sub *model.Subscription, allowOverlap bool) (bool, error)
+	CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, allowOverlap bool) (uuid.UUID, bool, error)
+	GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*model.Subscription, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Subscription, []uuid.UUID, error)
+	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	List(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error)
+	Update(ctx context.Context, sub *model.Subscription, allowOverlap bool) error
+	UpdateFields(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error
 	Delete(ctx context.Context, id uuid.UUID) error
-	GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName, startDate, endDate string) (int, error)
+	Cancel(ctx context.Context, id uuid.UUID, setEndDate bool) (*model.Subscription, bool, error)
+	Renew(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error)
+	GetHistory(ctx context.Context, id uuid.UUID, limit, offset int) ([]model.HistoryEntry, error)
+	ListMembers(ctx context.Context, id uuid.UUID) ([]model.SubscriptionMember, error)
+	AddMember(ctx context.Context, member *model.SubscriptionMember) (bool, error)
+	RemoveMember(ctx context.Context, id, userID uuid.UUID) error
+	DeleteMatching(ctx context.Context, filter model.DeleteFilter, dryRun bool) (int64, []uuid.UUID, error)
+	PurgeDeleted(ctx context.Context, olderThan time.Duration) (int64, error)
+	Archive(ctx context.Context, cutoff time.Time) (int64, error)
+	Reprice(ctx context.Context, serviceName, currency string, userID *uuid.UUID, price int, onlyActive bool) (int64, error)
+	GetTotalCost(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error)
+	GetAverageMonthlyCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to time.Time, basis string) (*model.AverageCostResponse, error)
+	GetForecast(ctx context.Context, userID uuid.UUID, currency string, months int, amortize bool) (*model.ForecastResponse, error)
+	CompareCosts(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to time.Time) (*model.CostComparisonResponse, error)
+	GetCostBreakdown(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, byMonth, byService, amortize bool) (*model.TotalCostResponse, error)
+	GetStats(ctx context.Context, userID *uuid.UUID, currency string, grouping model.StatsGrouping) (*model.StatsResponse, error)
+	GetSpendSeries(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time) ([]model.MonthlySpend, error)
+	GetTopServices(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time, limit int, byPlan bool) ([]model.ServiceSpend, error)
+	GetUserSummary(ctx context.Context, userID uuid.UUID, currency string) (*model.SummaryResponse, error)
+	GetBudget(ctx context.Context, userID uuid.UUID) (*model.BudgetUsage, error)
+	SetBudget(ctx context.Context, budget *model.Budget) (*model.BudgetUsage, bool, error)
+	GetBudgetStatus(ctx context.Context, userID uuid.UUID) (*model.BudgetStatus, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+	SetNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) (bool, error)
+	EraseUserData(ctx context.Context, userID uuid.UUID) (*model.ErasureSummary, error)
+	Anonymize(ctx context.Context, userID uuid.UUID, irreversible bool) (*model.AnonymizationResult, error)
+	GetAnonymization(ctx context.Context, userID uuid.UUID) (*model.Anonymization, error)
+	GetCostReport(ctx context.Context, currency string, from, to time.Time, limit, offset int) ([]model.UserCost, error)
+	GetDuplicateGroups(ctx context.Context, minGroupSize, limit, offset int) ([]model.DuplicateGroup, error)
+	Export(ctx context.Context, fn func(sub model.Subscription) error) error
+	Import(ctx context.Context, subs []model.Subscription) error
 }
 
+// maxFilterUserIDs caps the number of user_id values accepted by List.
+const maxFilterUserIDs = 100
+
 type Handler struct {
-	service SubscriptionService
-	log     *slog.Logger
+	service     SubscriptionService
+	catalog     CatalogService
+	users       UserService
+	rates       ExchangeRateService
+	pagination  config.PaginationConfig
+	concurrency config.ConcurrencyConfig
+	currency    config.CurrencyConfig
+	log         *slog.Logger
 }
 
-func NewHandler(service SubscriptionService, log *slog.Logger) *Handler {
-	return &Handler{service: service, log: log}
+func NewHandler(service SubscriptionService, catalog CatalogService, users UserService, rates ExchangeRateService, pagination config.PaginationConfig, concurrency config.ConcurrencyConfig, currency config.CurrencyConfig, log *slog.Logger) *Handler {
+	return &Handler{service: service, catalog: catalog, users: users, rates: rates, pagination: pagination, concurrency: concurrency, currency: currency, log: log}
 }
 
 // Create godoc
 // @Summary      Create a subscription
-// @Description  Create a new subscription
+// @Description  Create a new subscription and respond with it as stored, including the fields set by the server, along with a Location header pointing at it. Requests carrying an Idempotency-Key are applied once; replaying the key returns the subscription created the first time. With if_not_exists=true an existing subscription of the user to the same service starting in the same month is returned instead. Set trial=true to start the subscription out as trialing. A service_name matching a catalog entry links the subscription to it and is replaced by the canonical name; unknown names are added to the catalog when CATALOG_AUTO_CREATE is enabled. The user must be known to the user service; unknown users are rejected with 422, and 503 is returned when the user service cannot be reached. Users may have at most QUOTA_MAX_ACTIVE_PER_USER active subscriptions, unless their tenant sets a limit of its own; creating more is rejected with 422 naming the current count and the limit.
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
+// @Param        Idempotency-Key header string false "Unique key making retries safe"
+// @Param        allow_overlap query bool false "Allow the period to overlap another subscription of the same user and service"
+// @Param        if_not_exists query bool false "Return the existing subscription starting in the same month instead of creating one"
 // @Param        input body model.CreateSubscriptionRequest true "Subscription Info"
-// @Success      201  {object}  map[string]string
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Subscription
+// @Success      201  {object}  model.Subscription
+// @Header       201  {string}  Location "URL of the created subscription"
+// @Header       201  {string}  ETag "Version of the created subscription"
 // @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
 // @Router       /subscriptions [post]
 func (h *Handler) Create(c *gin.Context) {
 	h.log.Info("handler: creating subscription")
 	var req model.CreateSubscriptionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		h.log.Error("failed to bind json", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	currency, err := h.parseCurrency(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	price, err := requiredPrice(req.PriceMinor, req.PriceDecimal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	sub := &model.Subscription{
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      req.UserID,
-		StartDate:   req.StartDate,
-		EndDate:     req.EndDate,
+		ServiceName:     req.ServiceName,
+		Plan:            req.Plan,
+		PriceMinor:      price,
+		Currency:        currency,
+		BillingPeriod:   req.BillingPeriod,
+		UserID:          req.UserID,
+		StartDate:       *req.StartDate,
+		EndDate:         req.EndDate,
+		AutoRenew:       model.AutoRenewOrDefault(req.AutoRenew),
+		TrialEndDate:    req.TrialEndDate,
+		DiscountPercent: req.DiscountPercent,
+		DiscountUntil:   req.DiscountUntil,
+		Metadata:        req.Metadata,
+		Notes:           req.Notes,
+		Status:          req.InitialStatus(),
+	}
+	if err := sub.Validate(); err != nil {
+		h.log.Error("invalid subscription", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	id, err := h.service.Create(c.Request.Context(), sub)
+	allowOverlap := c.Query("allow_overlap") == "true"
+	ifNotExists := c.Query("if_not_exists") == "true"
+	key := c.GetHeader(idempotencyKeyHeader)
+	switch {
+	case key != "" && ifNotExists:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be combined with if_not_exists", idempotencyKeyHeader)})
+		return
+	case key != "":
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)})
+			return
+		}
+		var id uuid.UUID
+		var replayed bool
+		id, replayed, err = h.service.CreateIdempotent(c.Request.Context(), sub, key, requestHash(sub), allowOverlap)
+		if replayed {
+			h.log.Info("handler: replayed subscription creation", "id", id.String())
+		}
+	case ifNotExists:
+		var created bool
+		created, err = h.service.CreateIfNotExists(c.Request.Context(), sub, allowOverlap)
+		if err == nil && !created {
+			h.log.Info("handler: subscription already exists", "id", sub.ID.String())
+			c.JSON(http.StatusOK, sub)
+			return
+		}
+	default:
+		// sub is populated in place, and left as it is when creation fails.
+		_, err = h.service.Create(c.Request.Context(), sub, allowOverlap)
+	}
 	if err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			h.log.Warn("duplicate active subscription", "error", err)
+			c.JSON(http.StatusConflict, conflictResponse(err))
+			return
+		}
+		var overlapErr *model.OverlapError
+		if errors.As(err, &overlapErr) {
+			h.log.Warn("subscription overlaps", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, overlapResponse(overlapErr))
+			return
+		}
+		var quotaErr *model.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			h.log.Warn("subscription quota exceeded", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, quotaResponse(quotaErr))
+			return
+		}
+		if errors.Is(err, domain.ErrUnknownUser) {
+			h.log.Warn("unknown user", "user_id", sub.UserID.String())
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": unknownUserMessage})
+			return
+		}
+		if errors.Is(err, service.ErrUserServiceUnavailable) {
+			h.log.Error("failed to validate user", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+			return
+		}
+		if errors.Is(err, domain.ErrIdempotencyKeyReused) {
+			h.log.Warn("idempotency key reused", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s was already used with a different request", idempotencyKeyHeader)})
+			return
+		}
 		h.log.Error("failed to create subscription", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create subscription"})
+		h.serverError(c, err, "failed to create subscription")
 		return
 	}
 
-	h.log.Info("handler: subscription created", "id", id.String())
-	c.JSON(http.StatusCreated, gin.H{"id": id})
+	h.log.Info("handler: subscription created", "id", sub.ID.String())
+	c.Header("Location", subscriptionLocation(sub.ID))
+	c.Header("ETag", etag(sub.Version))
+	c.JSON(http.StatusCreated, sub)
+}
+
+// subscriptionLocation is the URL of the subscription with id, as sent in
+// the Location header of created subscriptions.
+func subscriptionLocation(id uuid.UUID) string {
+	return "/api/v1/subscriptions/" + id.String()
 }
 
 // GetByID godoc
@@ -76,12 +234,23 @@ func (h *Handler) Create(c *gin.Context) {
 // @Tags         subscriptions
 // @Produce      json
 // @Param        id   path      string  true  "Subscription ID"
+// @Param        fields query   string  false "Comma-separated list of fields to return, or of fields to leave out when prefixed with -"
+// @Param        X-Tenant-ID header string true "Tenant ID"
 // @Success      200  {object}  model.Subscription
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/{id} [get]
 func (h *Handler) GetByID(c *gin.Context) {
+	if rejectIncludeDeleted(c) {
+		return
+	}
+	h.getByID(c, false)
+}
+
+// getByID serves GetByID and its admin counterpart, which may include
+// soft-deleted subscriptions.
+func (h *Handler) getByID(c *gin.Context, includeDeleted bool) {
 	id, err := uuid.Parse(c.Param("id"))
 	h.log.Info("handler: getting subscription by id", "id", c.Param("id"))
 	if err != nil {
@@ -90,58 +259,248 @@ func (h *Handler) GetByID(c *gin.Context) {
 		return
 	}
 
-	sub, err := h.service.GetByID(c.Request.Context(), id)
+	fields, err := parseFields(c.Query("fields"))
+	if err != nil {
+		h.log.Error("invalid fields", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.service.GetByID(c.Request.Context(), id, includeDeleted)
 	if err != nil {
-		if errors.Is(err, postgres.ErrNotFound) {
+		if errors.Is(err, domain.ErrNotFound) {
 			h.log.Warn("subscription not found", "id", id.String())
 			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
 			return
 		}
 		h.log.Error("failed to get subscription", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get subscription"})
+		h.serverError(c, err, "failed to get subscription")
 		return
 	}
 
 	h.log.Info("handler: got subscription by id", "id", id.String())
-	c.JSON(http.StatusOK, sub)
+	c.Header("ETag", etag(sub.Version))
+	if fields == nil {
+		c.JSON(http.StatusOK, sub)
+		return
+	}
+
+	selected, err := selectFields(sub, fields)
+	if err != nil {
+		h.log.Error("failed to select fields", "error", err)
+		h.serverError(c, err, "failed to get subscription")
+		return
+	}
+	c.JSON(http.StatusOK, selected)
+}
+
+// Head godoc
+// @Summary      Check a subscription exists
+// @Description  Check whether a subscription with the given ID exists without returning it
+// @Tags         subscriptions
+// @Param        id   path      string  true  "Subscription ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200
+// @Failure      400
+// @Failure      404
+// @Failure      500
+// @Router       /subscriptions/{id} [head]
+func (h *Handler) Head(c *gin.Context) {
+	h.log.Info("handler: checking subscription exists", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.service.Exists(c.Request.Context(), id)
+	if err != nil {
+		h.log.Error("failed to check subscription exists", "error", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	h.log.Info("handler: subscription exists", "id", id.String())
+	c.Status(http.StatusOK)
+}
+
+// BatchGet godoc
+// @Summary      Get subscriptions by IDs
+// @Description  Get up to 200 subscriptions by their IDs in a single request
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input body model.BatchGetRequest true "Subscription IDs"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.BatchGetResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/batch_get [post]
+func (h *Handler) BatchGet(c *gin.Context) {
+	h.log.Info("handler: batch getting subscriptions")
+	var req model.BatchGetRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subs, notFound, err := h.service.GetByIDs(c.Request.Context(), req.IDs)
+	if err != nil {
+		h.log.Error("failed to batch get subscriptions", "error", err)
+		h.serverError(c, err, "failed to get subscriptions")
+		return
+	}
+
+	h.log.Info("handler: batch got subscriptions", "found", len(subs), "not_found", len(notFound))
+	c.JSON(http.StatusOK, model.BatchGetResponse{Subscriptions: subs, NotFound: notFound})
 }
 
 // List godoc
 // @Summary      List subscriptions
-// @Description  Get a list of all subscriptions
+// @Description  Get a list of all subscriptions. Send Accept: text/csv to receive CSV instead of JSON. Filter by metadata with metadata.<key>=<value> query parameters; a subscription matches when its metadata contains all of them.
 // @Tags         subscriptions
-// @Produce      json
-// @Param        limit query int false "Limit"
+// @Produce      json,text/csv
+// @Param        user_id query []string false "User IDs (repeated or comma-separated)" collectionFormat(multi)
+// @Param        service_name query string false "Service name (case-insensitive)"
+// @Param        status query string false "Status" Enums(active, trialing, paused, cancelled, expired)
+// @Param        plan query string false "Plan (case-insensitive)"
+// @Param        limit query int false "Limit (0 or absent means default, values above the maximum are clamped)"
 // @Param        offset query int false "Offset"
+// @Param        fields query string false "Comma-separated list of fields to return, or of fields to leave out when prefixed with -"
+// @Param        X-Tenant-ID header string true "Tenant ID"
 // @Success      200  {array}   model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      406  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions [get]
 func (h *Handler) List(c *gin.Context) {
 	h.log.Info("handler: listing subscriptions")
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if rejectIncludeDeleted(c) {
+		return
+	}
+	userIDs, err := parseUserIDs(c.QueryArray("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.list(c, model.SubscriptionFilter{UserIDs: userIDs})
+}
+
+// list serves a paginated subscription collection narrowed down by filter.
+// It is shared by the flat and the user-scoped collection routes.
+func (h *Handler) list(c *gin.Context, filter model.SubscriptionFilter) {
+	filter.ServiceName = c.Query("service_name")
+	filter.Status = c.Query("status")
+	filter.Plan = c.Query("plan")
+	filter.Metadata = metadataFilter(c.Request.URL.Query())
+	if filter.Status != "" && !model.IsValidStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q, supported values: %s", filter.Status, strings.Join(model.Statuses, ", "))})
+		return
+	}
+
+	limit, offset, err := h.parsePagination(c)
+	if err != nil {
+		h.log.Error("invalid pagination", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	subs, err := h.service.List(c.Request.Context(), limit, offset)
+	fields, err := parseFields(c.Query("fields"))
 	if err != nil {
+		h.log.Error("invalid fields", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.NegotiateFormat(binding.MIMEJSON, mimeCSV)
+	if format == "" {
+		h.log.Warn("unsupported accept header", "accept", c.GetHeader("Accept"))
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": fmt.Sprintf("supported media types: %s, %s", binding.MIMEJSON, mimeCSV)})
+		return
+	}
+
+	subs, err := h.service.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPagination) {
+			h.log.Warn("invalid pagination", "limit", limit, "offset", offset)
+			c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrInvalidPagination.Error()})
+			return
+		}
 		h.log.Error("failed to list subscriptions", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list subscriptions"})
+		h.serverError(c, err, "failed to list subscriptions")
 		return
 	}
 
 	h.log.Info("handler: listed subscriptions", "count", len(subs))
-	c.JSON(http.StatusOK, subs)
+	if format == mimeCSV {
+		columns := csvColumns
+		if fields != nil {
+			columns = fields
+		}
+		c.Header("Content-Type", mimeCSV)
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(columns); err != nil {
+			h.log.Error("failed to write csv", "error", err)
+			return
+		}
+		for _, sub := range subs {
+			if err := w.Write(csvRecord(sub, columns)); err != nil {
+				h.log.Error("failed to write csv", "error", err)
+				return
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			h.log.Error("failed to write csv", "error", err)
+		}
+		return
+	}
+
+	if fields == nil {
+		c.JSON(http.StatusOK, subs)
+		return
+	}
+
+	selected := make([]map[string]json.RawMessage, 0, len(subs))
+	for _, sub := range subs {
+		item, err := selectFields(sub, fields)
+		if err != nil {
+			h.log.Error("failed to select fields", "error", err)
+			h.serverError(c, err, "failed to list subscriptions")
+			return
+		}
+		selected = append(selected, item)
+	}
+	c.JSON(http.StatusOK, selected)
 }
 
 // Update godoc
-// @Summary      Update a subscription
-// @Description  Update an existing subscription
+// @Summary      Replace a subscription
+// @Description  Replace every mutable field of an existing subscription. An omitted end_date makes it open-ended. Send If-Match with the ETag from GET (or the version in the body) to fail with 412 if the subscription changed in the meantime.
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Subscription ID"
-// @Param        input body model.UpdateSubscriptionRequest true "Subscription Info"
+// @Param        If-Match header string false "ETag of the version being replaced"
+// @Param        input body model.ReplaceSubscriptionRequest true "Subscription Info"
+// @Param        allow_overlap query bool false "Allow the period to overlap another subscription of the same user and service"
+// @Param        X-Tenant-ID header string true "Tenant ID"
 // @Success      204  {object}  nil
 // @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      412  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      428  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/{id} [put]
 func (h *Handler) Update(c *gin.Context) {
@@ -153,45 +512,223 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
-	var req model.UpdateSubscriptionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	var req model.ReplaceSubscriptionRequest
+	if err := bindJSON(c, &req); err != nil {
 		h.log.Error("failed to bind json", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	sub, err := h.service.GetByID(c.Request.Context(), id)
+	version, err := h.expectedVersion(c.GetHeader("If-Match"), req.Version)
 	if err != nil {
-		if errors.Is(err, postgres.ErrNotFound) {
+		h.writeVersionError(c, err)
+		return
+	}
+	price, err := requiredPrice(req.PriceMinor, req.PriceDecimal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub := &model.Subscription{
+		ID:              id,
+		ServiceName:     req.ServiceName,
+		Plan:            req.Plan,
+		PriceMinor:      price,
+		BillingPeriod:   req.BillingPeriod,
+		StartDate:       *req.StartDate,
+		EndDate:         req.EndDate,
+		AutoRenew:       model.AutoRenewOrDefault(req.AutoRenew),
+		TrialEndDate:    req.TrialEndDate,
+		DiscountPercent: req.DiscountPercent,
+		DiscountUntil:   req.DiscountUntil,
+		Metadata:        req.Metadata,
+		Notes:           req.Notes,
+		Version:         version,
+	}
+	if req.Currency != "" {
+		if sub.Currency, err = h.parseCurrency(req.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := sub.Validate(); err != nil {
+		h.log.Error("invalid subscription", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Update(c.Request.Context(), sub, c.Query("allow_overlap") == "true"); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
 			h.log.Warn("subscription not found", "id", id.String())
 			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
 			return
 		}
-		h.log.Error("failed to get subscription", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get subscription"})
+		var overlapErr *model.OverlapError
+		if errors.As(err, &overlapErr) {
+			h.log.Warn("subscription overlaps", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, overlapResponse(overlapErr))
+			return
+		}
+		if errors.Is(err, domain.ErrConflict) {
+			h.log.Warn("duplicate active subscription", "error", err)
+			c.JSON(http.StatusConflict, conflictResponse(err))
+			return
+		}
+		if errors.Is(err, domain.ErrVersionConflict) {
+			h.log.Warn("stale subscription version", "id", id.String())
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": domain.ErrVersionConflict.Error()})
+			return
+		}
+		h.log.Error("failed to update subscription", "error", err)
+		h.serverError(c, err, "failed to update subscription")
 		return
 	}
 
-	if req.ServiceName != nil {
-		sub.ServiceName = *req.ServiceName
+	h.log.Info("handler: updated subscription", "id", id.String())
+	c.Header("ETag", etag(sub.Version))
+	c.Status(http.StatusNoContent)
+}
+
+// Patch godoc
+// @Summary      Partially update a subscription
+// @Description  Update only the provided fields of an existing subscription. Send Content-Type application/merge-patch+json to apply an RFC 7386 merge patch, where "end_date": null makes the subscription open-ended and metadata keys are merged into the stored ones. Send If-Match with the ETag from GET (or the version in the body) to fail with 412 if the subscription changed in the meantime. A status change the lifecycle does not allow is rejected with 409; cancelling goes through the cancel endpoint.
+// @Tags         subscriptions
+// @Accept       json,application/merge-patch+json
+// @Produce      json
+// @Param        id   path      string  true  "Subscription ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        input body model.UpdateSubscriptionRequest true "Fields to update"
+// @Param        allow_overlap query bool false "Allow the period to overlap another subscription of the same user and service"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      204  {object}  nil
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      412  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      428  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id} [patch]
+func (h *Handler) Patch(c *gin.Context) {
+	h.log.Info("handler: patching subscription", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var patch model.SubscriptionPatch
+	if c.ContentType() == mimeMergePatch {
+		patch, err = decodeMergePatch(c.Request.Body)
+		if errors.Is(err, io.EOF) {
+			patch, err = model.SubscriptionPatch{}, nil
+		}
+		if err != nil {
+			h.log.Error("failed to decode merge patch", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		// An empty body is treated like {} so that it is rejected by the
+		// service as an empty patch.
+		var req model.UpdateSubscriptionRequest
+		if err := bindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+			h.log.Error("failed to bind json", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		price, err := model.ResolvePrice(req.PriceMinor, req.PriceDecimal)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		patch = model.SubscriptionPatch{
+			ServiceName:       req.ServiceName,
+			Plan:              req.Plan.Value,
+			ClearPlan:         req.Plan.IsNull(),
+			PriceMinor:        price,
+			Currency:          req.Currency,
+			BillingPeriod:     req.BillingPeriod,
+			StartDate:         req.StartDate,
+			EndDate:           req.EndDate.Value,
+			ClearEndDate:      req.EndDate.IsNull(),
+			AutoRenew:         req.AutoRenew,
+			TrialEndDate:      req.TrialEndDate.Value,
+			ClearTrialEndDate: req.TrialEndDate.IsNull(),
+			DiscountPercent:   req.DiscountPercent.Value,
+			DiscountUntil:     req.DiscountUntil.Value,
+			ClearDiscount:     req.DiscountPercent.IsNull() || req.DiscountUntil.IsNull(),
+			ClearMetadata:     req.Metadata.Present,
+			Notes:             req.Notes.Value,
+			ClearNotes:        req.Notes.IsNull(),
+			Status:            req.Status,
+		}
+		if req.Metadata.Value != nil {
+			patch.Metadata = make(map[string]*string, len(*req.Metadata.Value))
+			for key, value := range *req.Metadata.Value {
+				patch.Metadata[key] = &value
+			}
+		}
+		if req.Version != nil {
+			patch.Version = *req.Version
+		}
 	}
-	if req.Price != nil {
-		sub.Price = *req.Price
+
+	if patch.Currency != nil {
+		if strings.TrimSpace(*patch.Currency) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "currency must not be empty"})
+			return
+		}
+		currency, err := h.parseCurrency(*patch.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		patch.Currency = &currency
 	}
-	if req.StartDate != nil {
-		sub.StartDate = *req.StartDate
+
+	var bodyVersion *int
+	if patch.Version != 0 {
+		bodyVersion = &patch.Version
 	}
-	if req.EndDate != nil {
-		sub.EndDate = req.EndDate
+	if patch.Version, err = h.expectedVersion(c.GetHeader("If-Match"), bodyVersion); err != nil {
+		h.writeVersionError(c, err)
+		return
 	}
 
-	if err := h.service.Update(c.Request.Context(), sub); err != nil {
-		h.log.Error("failed to update subscription", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update subscription"})
+	if err := h.service.UpdateFields(c.Request.Context(), id, patch, c.Query("allow_overlap") == "true"); err != nil {
+		var validationErr model.ValidationError
+		var overlapErr *model.OverlapError
+		var transitionErr *model.TransitionError
+		switch {
+		case errors.As(err, &validationErr):
+			h.log.Warn("invalid patch", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		case errors.As(err, &transitionErr):
+			h.log.Warn("invalid status transition", "error", err)
+			c.JSON(http.StatusConflict, gin.H{"error": transitionErr.Error()})
+		case errors.Is(err, domain.ErrNotFound):
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		case errors.Is(err, domain.ErrConflict):
+			h.log.Warn("duplicate active subscription", "error", err)
+			c.JSON(http.StatusConflict, conflictResponse(err))
+		case errors.As(err, &overlapErr):
+			h.log.Warn("subscription overlaps", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, overlapResponse(overlapErr))
+		case errors.Is(err, domain.ErrVersionConflict):
+			h.log.Warn("stale subscription version", "id", id.String())
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": domain.ErrVersionConflict.Error()})
+		default:
+			h.log.Error("failed to patch subscription", "error", err)
+			h.serverError(c, err, "failed to update subscription")
+		}
 		return
 	}
 
-	h.log.Info("handler: updated subscription", "id", id.String())
+	h.log.Info("handler: patched subscription", "id", id.String())
 	c.Status(http.StatusNoContent)
 }
 
@@ -200,8 +737,10 @@ func (h *Handler) Update(c *gin.Context) {
 // @Description  Delete a subscription by its ID
 // @Tags         subscriptions
 // @Param        id   path      string  true  "Subscription ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
 // @Success      204  {object}  nil
 // @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/{id} [delete]
 func (h *Handler) Delete(c *gin.Context) {
@@ -214,8 +753,13 @@ func (h *Handler) Delete(c *gin.Context) {
 	}
 
 	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
 		h.log.Error("failed to delete subscription", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete subscription"})
+		h.serverError(c, err, "failed to delete subscription")
 		return
 	}
 
@@ -223,39 +767,515 @@ func (h *Handler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// Cancel godoc
+// @Summary      Cancel a subscription
+// @Description  Mark a subscription as cancelled. It stops accruing cost after the current month. With set_end_date=true an open-ended subscription also gets the current month as its end date. Cancelling a cancelled subscription returns it unchanged.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      string  true  "Subscription ID"
+// @Param        set_end_date query bool false "Set the end date of an open-ended subscription to the current month"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/cancel [post]
+func (h *Handler) Cancel(c *gin.Context) {
+	h.log.Info("handler: cancelling subscription", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	sub, cancelled, err := h.service.Cancel(c.Request.Context(), id, c.Query("set_end_date") == "true")
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		h.log.Error("failed to cancel subscription", "error", err)
+		h.serverError(c, err, "failed to cancel subscription")
+		return
+	}
+
+	h.log.Info("handler: cancelled subscription", "id", id.String(), "changed", cancelled)
+	c.Header("ETag", etag(sub.Version))
+	c.JSON(http.StatusOK, sub)
+}
+
+// Renew godoc
+// @Summary      Renew a subscription
+// @Description  Extend the end date of a subscription by the given number of months (default 1, maximum 60), counted from its current end date, or from the current month when it is open-ended or has already ended. The response carries the new end_date.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id    path  string             true  "Subscription ID"
+// @Param        input body  model.RenewRequest false "Renewal"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/renew [post]
+func (h *Handler) Renew(c *gin.Context) {
+	h.log.Info("handler: renewing subscription", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	var req model.RenewRequest
+	if err := bindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	months := 1
+	if req.Months != nil {
+		months = *req.Months
+	}
+
+	sub, err := h.service.Renew(c.Request.Context(), id, months)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		if errors.Is(err, domain.ErrCancelled) {
+			h.log.Warn("cannot renew cancelled subscription", "id", id.String())
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrCancelled.Error()})
+			return
+		}
+		h.log.Error("failed to renew subscription", "error", err)
+		h.serverError(c, err, "failed to renew subscription")
+		return
+	}
+
+	h.log.Info("handler: renewed subscription", "id", id.String(), "end_date", sub.EndDate.String())
+	c.Header("ETag", etag(sub.Version))
+	c.JSON(http.StatusOK, sub)
+}
+
+// GetHistory godoc
+// @Summary      Get the history of a subscription
+// @Description  Get the changes recorded for a subscription, oldest first. Each entry lists the fields the change touched with their old and new values; derived fields such as price_decimal, effective_price, updated_at and version are left out. Soft-deleted subscriptions keep their history.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id     path   string  true   "Subscription ID"
+// @Param        limit  query  int     false  "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset query  int     false  "Offset"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.HistoryEntry
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/history [get]
+func (h *Handler) GetHistory(c *gin.Context) {
+	h.log.Info("handler: getting subscription history", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+	limit, offset, err := h.parsePagination(c)
+	if err != nil {
+		h.log.Error("invalid pagination", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.service.GetHistory(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		h.log.Error("failed to get subscription history", "error", err)
+		h.serverError(c, err, "failed to get subscription history")
+		return
+	}
+
+	h.log.Info("handler: got subscription history", "id", id.String(), "count", len(history))
+	c.JSON(http.StatusOK, history)
+}
+
+// DeleteMatching godoc
+// @Summary      Delete subscriptions by filter
+// @Description  Delete every subscription of a user, optionally narrowed down to a service and a range of start dates (inclusive, MM-YYYY). With dry_run=true nothing is deleted; the response carries the number of matches and up to 100 of their IDs.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input   body  model.DeleteSubscriptionsRequest true "Filter"
+// @Param        dry_run query bool false "Count the matching subscriptions without deleting them"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.DeleteSubscriptionsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions [delete]
+func (h *Handler) DeleteMatching(c *gin.Context) {
+	h.log.Info("handler: deleting subscriptions by filter")
+	dryRun := c.Query("dry_run") == "true"
+
+	var req model.DeleteSubscriptionsRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		if errors.Is(err, io.EOF) {
+			err = errors.New("a filter is required")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	if req.StartFrom != nil && req.StartTo != nil && req.StartTo.Before(*req.StartFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_to must not be before start_from"})
+		return
+	}
+
+	filter := model.DeleteFilter{
+		UserID:      req.UserID,
+		ServiceName: req.ServiceName,
+		StartFrom:   req.StartFrom,
+		StartTo:     req.StartTo,
+	}
+	count, sample, err := h.service.DeleteMatching(c.Request.Context(), filter, dryRun)
+	if err != nil {
+		h.log.Error("failed to delete subscriptions", "error", err)
+		h.serverError(c, err, "failed to delete subscriptions")
+		return
+	}
+
+	resp := model.DeleteSubscriptionsResponse{DryRun: dryRun, Matched: count, SampleIDs: sample}
+	if !dryRun {
+		resp.Deleted = count
+	}
+	h.log.Info("handler: deleted subscriptions by filter", "matched", resp.Matched, "deleted", resp.Deleted)
+	c.JSON(http.StatusOK, resp)
+}
+
 // GetTotalCost godoc
 // @Summary      Get total cost of subscriptions
-// @Description  Get total cost of subscriptions for a user, with optional filters
+// @Description  Get total cost of subscriptions for a user, with optional filters. Omit user_id and pass scope=all for the total across all users. Prices in different currencies are never added up: without a currency filter the cost is reported per currency in totals, and total_cost is only present when a single currency is involved. Yearly subscriptions count their price in every 12th month from their start month and weekly ones for every charge in the period; with amortize=true both are spread evenly over the months instead. A user's total includes their share of the subscriptions shared with them, and the owner's total only the remainder; breakdowns, forecasts and top services count the same parts, so they add up to the total. With convert_to the per-currency totals are also converted into that currency with the latest exchange rate of every pair, stored in either direction, and returned in converted along with the rates used; a missing rate is reported with 422 listing the pairs.
 // @Tags         subscriptions
 // @Produce      json
-// @Param        user_id      query     string  true  "User ID"
-// @Param        service_name query     string  false "Service Name"
+// @Param        user_id      query     string  false "User ID, required unless scope=all"
+// @Param        scope        query     string  false "Set to all to aggregate across all users instead of user_id" Enums(all)
+// @Param        service_name query     string  false "Service Name (case-insensitive)"
+// @Param        currency     query     string  false "Only count subscriptions in this ISO 4217 currency"
 // @Param        start_date   query     string  false "Start Date (MM-YYYY)"
 // @Param        end_date     query     string  false "End Date (MM-YYYY)"
-// @Success      200  {object}  map[string]int
+// @Param        period       query     string  false "Period preset, mutually exclusive with start_date/end_date" Enums(current_month, last_3_months, last_12_months, ytd)
+// @Param        breakdown    query     string  false "Include a per-month breakdown" Enums(month)
+// @Param        group_by     query     string  false "Include a per-service breakdown" Enums(service)
+// @Param        amortize     query     bool    false "Spread yearly and weekly prices evenly over the months"
+// @Param        include_archived query bool    false "Count archived subscriptions as well; cannot be combined with breakdown or group_by"
+// @Param        fresh        query     bool    false "Bypass the total cost cache"
+// @Param        convert_to   query     string  false "Also convert the total into this ISO 4217 currency with the latest exchange rates"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.TotalCostResponse
 // @Failure      400  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/total_cost [get]
 func (h *Handler) GetTotalCost(c *gin.Context) {
 	h.log.Info("handler: getting total cost")
-	userID, err := uuid.Parse(c.Query("user_id"))
-	if err != nil {
-		h.log.Error("invalid user_id", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+	var userID *uuid.UUID
+	scope := c.Query("scope")
+	switch {
+	case scope != "" && scope != model.TotalCostScopeAll:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported scope, supported values: all"})
+		return
+	case scope == model.TotalCostScopeAll && c.Query("user_id") != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id cannot be combined with scope=all"})
 		return
+	case scope != model.TotalCostScopeAll:
+		id, err := parseUserID(c.Query("user_id"))
+		if err != nil {
+			h.log.Error("invalid user_id", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id, pass scope=all to aggregate across all users"})
+			return
+		}
+		userID = &id
 	}
 
 	serviceName := c.Query("service_name")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
+	var currency string
+	if c.Query("currency") != "" {
+		var err error
+		if currency, err = h.parseCurrency(c.Query("currency")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	from, to, err := parseCostPeriod(c)
+	if err != nil {
+		h.log.Error("invalid period", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	breakdown := c.Query("breakdown")
+	if breakdown != "" && breakdown != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported breakdown, supported values: month"})
+		return
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "service" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported group_by, supported values: service"})
+		return
+	}
 
-	totalCost, err := h.service.GetTotalCost(c.Request.Context(), userID, serviceName, startDate, endDate)
+	convertTo := strings.ToUpper(strings.TrimSpace(c.Query("convert_to")))
+	if convertTo != "" && !model.IsCurrencyCode(convertTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid convert_to, expected an ISO 4217 code"})
+		return
+	}
+
+	amortize := c.Query("amortize") == "true"
+	includeArchived := c.Query("include_archived") == "true"
+	var resp *model.TotalCostResponse
+	if breakdown != "" || groupBy != "" {
+		if userID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "breakdown and group_by require user_id"})
+			return
+		}
+		if includeArchived {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_archived cannot be combined with breakdown or group_by"})
+			return
+		}
+		resp, err = h.service.GetCostBreakdown(c.Request.Context(), *userID, serviceName, currency, from, to, breakdown == "month", groupBy == "service", amortize)
+		if err == nil && resp.TotalCost == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "subscriptions are priced in several currencies, pass currency to break down the cost"})
+			return
+		}
+	} else {
+		resp, err = h.service.GetTotalCost(c.Request.Context(), userID, serviceName, currency, from, to, amortize, includeArchived, c.Query("fresh") == "true")
+	}
 	if err != nil {
+		if errors.Is(err, domain.ErrCostOverflow) {
+			h.log.Error("total cost overflows", "error", err)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "total cost is too large to compute"})
+			return
+		}
 		h.log.Error("failed to get total cost", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get total cost"})
+		h.serverError(c, err, "failed to get total cost")
 		return
 	}
 
-	h.log.Info("handler: got total cost", "total_cost", totalCost)
-	c.JSON(http.StatusOK, gin.H{"total_cost": totalCost})
+	if convertTo != "" {
+		converted, err := h.rates.Convert(c.Request.Context(), resp.CurrencyTotals(), convertTo)
+		if err != nil {
+			var missingErr *model.MissingRatesError
+			switch {
+			case errors.As(err, &missingErr):
+				h.log.Warn("missing exchange rates", "pairs", missingErr.Pairs)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": missingErr.Error(), "missing_pairs": missingErr.Pairs})
+			case errors.Is(err, service.ErrConversionOverflow):
+				h.log.Error("converted total overflows", "error", err)
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "converted total is too large to compute"})
+			default:
+				h.log.Error("failed to convert total cost", "error", err)
+				h.serverError(c, err, "failed to convert total cost")
+			}
+			return
+		}
+		// resp may be shared with the total cost cache.
+		withConversion := *resp
+		withConversion.Converted = converted
+		resp = &withConversion
+	}
+
+	h.log.Info("handler: got total cost", "currencies", len(resp.Totals), "currency", resp.Currency)
+	c.JSON(http.StatusOK, resp)
+}
+
+// unknownUserMessage is the error reported for subscriptions of a user
+// that the user service does not know.
+const unknownUserMessage = "user_id does not name a known user"
+
+// conflictResponse renders an error matching domain.ErrConflict, naming
+// the existing active subscription when it is known.
+func conflictResponse(err error) gin.H {
+	body := gin.H{"error": domain.ErrConflict.Error()}
+	var conflict *domain.ConflictError
+	if errors.As(err, &conflict) {
+		body["existing_id"] = conflict.ExistingID
+	}
+	return body
+}
+
+// serverError answers a failure the handler has no specific response for.
+// Typed database errors get their own status and a stable code; anything
+// else is a 500 with message.
+func (h *Handler) serverError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, domain.ErrTimeout):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": domain.ErrTimeout.Error(), "code": "timeout"})
+	case errors.Is(err, domain.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "the request conflicts with an existing record", "code": "conflict"})
+	case errors.Is(err, domain.ErrForeignKeyViolation):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": domain.ErrForeignKeyViolation.Error(), "code": "foreign_key_violation"})
+	case errors.Is(err, domain.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrInvalidInput.Error(), "code": "invalid_input"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// rejectIncludeDeleted answers 400 and returns true when a public route is
+// asked for soft-deleted subscriptions, which only admin routes return.
+func rejectIncludeDeleted(c *gin.Context) bool {
+	if _, ok := c.GetQuery("include_deleted"); !ok {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "include_deleted is only supported on admin routes"})
+	return true
+}
+
+// overlapResponse lists the subscriptions an overlapping write collided
+// with.
+func overlapResponse(err *model.OverlapError) gin.H {
+	return gin.H{"error": err.Error(), "conflicting_ids": err.IDs}
+}
+
+// quotaResponse renders a *model.QuotaExceededError with the number of
+// active subscriptions of the user and their limit.
+func quotaResponse(err *model.QuotaExceededError) gin.H {
+	return gin.H{"error": model.ErrQuotaExceeded.Error(), "user_id": err.UserID, "count": err.Count, "limit": err.Limit}
+}
+
+// parseUserID parses a user ID, rejecting the nil UUID so that no request
+// can act on behalf of a non-existent user.
+func parseUserID(value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if id == uuid.Nil {
+		return uuid.Nil, errors.New("user_id must not be the nil UUID")
+	}
+	return id, nil
+}
+
+// parseUserIDs parses user_id query values, each of which may hold
+// several comma-separated UUIDs.
+func parseUserIDs(values []string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, value := range values {
+		for _, raw := range strings.Split(value, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			id, err := parseUserID(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid user_id %q", raw)
+			}
+			ids = append(ids, id)
+			if len(ids) > maxFilterUserIDs {
+				return nil, fmt.Errorf("too many user_id values, maximum is %d", maxFilterUserIDs)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// parsePagination reads limit and offset from the query string. A missing
+// or zero limit falls back to the configured default and a limit above the
+// configured maximum is clamped to it.
+func (h *Handler) parsePagination(c *gin.Context) (int, int, error) {
+	limit := h.pagination.DefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q: must be a non-negative integer", raw)
+		}
+		if value > 0 {
+			limit = value
+		}
+	}
+	if h.pagination.MaxLimit > 0 && limit > h.pagination.MaxLimit {
+		limit = h.pagination.MaxLimit
+	}
+
+	offset := 0
+	if raw := c.Query("offset"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q: must be a non-negative integer", raw)
+		}
+		offset = value
+	}
+
+	return limit, offset, nil
+}
+
+// parseMonthYear parses a required MM-YYYY query parameter.
+func parseMonthYear(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%s is required", name)
+	}
+	t, err := model.ParseMonthYear(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected MM-YYYY or YYYY-MM", name, value)
+	}
+	return t, nil
+}
+
+// parseOptionalMonthYear parses an optional MM-YYYY query parameter. An
+// empty value yields nil, meaning no bound.
+func parseOptionalMonthYear(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := parseMonthYear(name, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseCostPeriod reads the period of a cost query, given either as a
+// period preset or as optional start_date and end_date bounds.
+func parseCostPeriod(c *gin.Context) (*time.Time, *time.Time, error) {
+	if preset := c.Query("period"); preset != "" {
+		if c.Query("start_date") != "" || c.Query("end_date") != "" {
+			return nil, nil, errors.New("period cannot be combined with start_date or end_date")
+		}
+		from, to, err := presetPeriod(preset, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		return &from, &to, nil
+	}
+
+	from, err := parseOptionalMonthYear("start_date", c.Query("start_date"))
+	if err != nil {
+		return nil, nil, err
+	}
+	to, err := parseOptionalMonthYear("end_date", c.Query("end_date"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, errors.New("start_date must not be after end_date")
+	}
+	return from, to, nil
+}
+
+// monthsBetween returns the number of whole calendar months from a to b.
+func monthsBetween(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
 }
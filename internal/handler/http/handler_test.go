@@ -0,0 +1,822 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+}
+
+// testLog discards the logs of the handlers under test.
+var testLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testPagination is the pagination the handlers under test are configured
+// with.
+var testPagination = config.PaginationConfig{DefaultLimit: 10, MaxLimit: 100}
+
+// testCurrency is the currency configuration of the handlers under test.
+var testCurrency = config.CurrencyConfig{Default: "RUB", Allowed: []string{"RUB", "USD"}}
+
+// testTenant is the tenant the requests under test are made for.
+var testTenant = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+
+// fakeService implements the methods of SubscriptionService the tests set
+// a func for; the others panic.
+type fakeService struct {
+	SubscriptionService
+
+	create           func(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error)
+	createIdempotent func(ctx context.Context, sub *model.Subscription, key, requestHash string, allowOverlap bool) (uuid.UUID, bool, error)
+	list             func(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error)
+	update           func(ctx context.Context, sub *model.Subscription, allowOverlap bool) error
+	updateFields     func(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error
+	addMember        func(ctx context.Context, member *model.SubscriptionMember) (bool, error)
+	renew            func(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error)
+	getTotalCost     func(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error)
+}
+
+func (f *fakeService) Create(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error) {
+	return f.create(ctx, sub, allowOverlap)
+}
+
+func (f *fakeService) CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, allowOverlap bool) (uuid.UUID, bool, error) {
+	return f.createIdempotent(ctx, sub, key, requestHash, allowOverlap)
+}
+
+func (f *fakeService) List(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error) {
+	return f.list(ctx, filter, limit, offset)
+}
+
+func (f *fakeService) Update(ctx context.Context, sub *model.Subscription, allowOverlap bool) error {
+	return f.update(ctx, sub, allowOverlap)
+}
+
+func (f *fakeService) UpdateFields(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error {
+	return f.updateFields(ctx, id, patch, allowOverlap)
+}
+
+func (f *fakeService) AddMember(ctx context.Context, member *model.SubscriptionMember) (bool, error) {
+	return f.addMember(ctx, member)
+}
+
+func (f *fakeService) Renew(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error) {
+	return f.renew(ctx, id, months)
+}
+
+func (f *fakeService) GetTotalCost(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error) {
+	return f.getTotalCost(ctx, userID, serviceName, currency, from, to, amortize, includeArchived, fresh)
+}
+
+// serve runs a request for testTenant through the routes of a handler
+// backed by svc. A body is sent as JSON unless header sets another
+// Content-Type.
+func serve(svc SubscriptionService, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	h := NewHandler(svc, nil, nil, nil, testPagination, config.ConcurrencyConfig{}, testCurrency, testLog)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set(tenantHeader, testTenant.String())
+	if body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.InitRoutes().ServeHTTP(w, req)
+	return w
+}
+
+// errorOf returns the error message of a JSON error response.
+func errorOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode error response %q: %v", w.Body.String(), err)
+	}
+	return body.Error
+}
+
+func TestParsePagination(t *testing.T) {
+	h := &Handler{pagination: testPagination, log: testLog}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{name: "defaults", query: "", wantLimit: 10},
+		{name: "explicit", query: "limit=25&offset=50", wantLimit: 25, wantOffset: 50},
+		{name: "zero limit falls back to the default", query: "limit=0", wantLimit: 10},
+		{name: "limit at the maximum", query: "limit=100", wantLimit: 100},
+		{name: "limit above the maximum is clamped", query: "limit=1000", wantLimit: 100},
+		{name: "negative limit", query: "limit=-1", wantErr: true},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: true},
+		{name: "fractional limit", query: "limit=1.5", wantErr: true},
+		{name: "negative offset", query: "offset=-5", wantErr: true},
+		{name: "non-numeric offset", query: "offset=x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			limit, offset, err := h.parsePagination(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePagination() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Errorf("parsePagination() = %d, %d, want %d, %d", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestListRejectsNegativePagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantParam string
+	}{
+		{name: "negative offset", query: "offset=-5", wantParam: "offset"},
+		{name: "offset wrapping around as uint64", query: "offset=-1", wantParam: "offset"},
+		{name: "negative limit", query: "limit=-5", wantParam: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{list: func(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error) {
+				t.Fatalf("List() called with limit %d, offset %d", limit, offset)
+				return nil, nil
+			}}
+
+			w := serve(svc, http.MethodGet, "/api/v1/subscriptions?"+tt.query, "", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if msg := errorOf(t, w); !strings.Contains(msg, tt.wantParam) {
+				t.Errorf("error = %q, want it to name %s", msg, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestParseUserID(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "valid", value: "60601fee-2bf1-4721-ae6f-7636e79a0cba"},
+		{name: "nil UUID", value: "00000000-0000-0000-0000-000000000000", wantErr: true},
+		{name: "malformed", value: "not-a-uuid", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := parseUserID(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUserID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && id.String() != tt.value {
+				t.Errorf("parseUserID() = %s, want %s", id, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseUserIDs(t *testing.T) {
+	ids := func(n int) []string {
+		values := make([]string, n)
+		for i := range values {
+			values[i] = uuid.New().String()
+		}
+		return values
+	}
+	a, b := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		values  []string
+		want    []uuid.UUID
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "repeated", values: []string{a.String(), b.String()}, want: []uuid.UUID{a, b}},
+		{name: "comma-separated", values: []string{a.String() + ", " + b.String()}, want: []uuid.UUID{a, b}},
+		{name: "empty items are skipped", values: []string{a.String() + ",,", ""}, want: []uuid.UUID{a}},
+		{name: "at the cap", values: ids(maxFilterUserIDs)},
+		{name: "above the cap", values: ids(maxFilterUserIDs + 1), wantErr: true},
+		{name: "above the cap when split", values: []string{strings.Join(ids(maxFilterUserIDs+1), ",")}, wantErr: true},
+		{name: "malformed", values: []string{a.String() + ",nope"}, wantErr: true},
+		{name: "nil UUID", values: []string{uuid.Nil.String()}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUserIDs(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUserIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil || tt.want == nil {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseUserIDs() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseUserIDs()[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListNegotiatesFormat(t *testing.T) {
+	sub := model.Subscription{ID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", UserID: uuid.New()}
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "no preference", accept: "", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "any", accept: "*/*", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "json", accept: "application/json", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "csv", accept: "text/csv", wantStatus: http.StatusOK, wantContentType: "text/csv"},
+		{name: "csv preferred", accept: "text/csv, application/json;q=0.5", wantStatus: http.StatusOK, wantContentType: "text/csv"},
+		{name: "unsupported", accept: "application/xml", wantStatus: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			svc := &fakeService{list: func(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error) {
+				listed = true
+				return []model.Subscription{sub}, nil
+			}}
+			header := http.Header{}
+			if tt.accept != "" {
+				header.Set("Accept", tt.accept)
+			}
+
+			w := serve(svc, http.MethodGet, "/api/v1/subscriptions", "", header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotAcceptable {
+				if listed {
+					t.Error("List() called for an unsupported media type")
+				}
+				return
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantContentType)
+			}
+			if tt.wantContentType == "text/csv" {
+				lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+				if len(lines) != 2 || lines[0] != strings.Join(csvColumns, ",") || !strings.HasPrefix(lines[1], sub.ID.String()+",Netflix,") {
+					t.Errorf("body = %q, want the header and one record", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestGetTotalCostFresh(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantFresh bool
+	}{
+		{name: "cached by default", query: "", wantFresh: false},
+		{name: "fresh", query: "&fresh=true", wantFresh: true},
+		{name: "fresh only when true", query: "&fresh=1", wantFresh: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFresh bool
+			svc := &fakeService{getTotalCost: func(ctx context.Context, id *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error) {
+				if id == nil || *id != userID {
+					t.Errorf("GetTotalCost() user = %v, want %s", id, userID)
+				}
+				gotFresh = fresh
+				return &model.TotalCostResponse{Scope: model.TotalCostScopeUser}, nil
+			}}
+
+			w := serve(svc, http.MethodGet, "/api/v1/subscriptions/total_cost?user_id="+userID.String()+tt.query, "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			if gotFresh != tt.wantFresh {
+				t.Errorf("fresh = %t, want %t", gotFresh, tt.wantFresh)
+			}
+		})
+	}
+}
+
+func TestGetTotalCostPeriod(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "start after end", query: "&start_date=06-2025&end_date=01-2025", wantStatus: http.StatusBadRequest},
+		{name: "start and end in one month", query: "&start_date=06-2025&end_date=06-2025", wantStatus: http.StatusOK},
+		{name: "start before end", query: "&start_date=01-2025&end_date=06-2025", wantStatus: http.StatusOK},
+		{name: "open-ended", query: "&start_date=06-2025", wantStatus: http.StatusOK},
+		{name: "invalid start", query: "&start_date=2025/06", wantStatus: http.StatusBadRequest},
+		{name: "preset with dates", query: "&period=ytd&start_date=01-2025", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			svc := &fakeService{getTotalCost: func(ctx context.Context, id *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error) {
+				called = true
+				return &model.TotalCostResponse{Scope: model.TotalCostScopeUser}, nil
+			}}
+
+			w := serve(svc, http.MethodGet, "/api/v1/subscriptions/total_cost?user_id="+userID.String()+tt.query, "", nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("service called = %t on status %d", called, w.Code)
+			}
+		})
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	const body = `{"service_name":"Netflix","price_minor":500,"start_date":"01-2025"}`
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "updated", err: nil, wantStatus: http.StatusNoContent},
+		{name: "missing or deleted", err: fmt.Errorf("repository.Update: %w", domain.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "stale version", err: fmt.Errorf("repository.Update: %w", domain.ErrVersionConflict), wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.New()
+			svc := &fakeService{update: func(ctx context.Context, sub *model.Subscription, allowOverlap bool) error {
+				if sub.ID != id {
+					t.Errorf("Update() id = %s, want %s", sub.ID, id)
+				}
+				return tt.err
+			}}
+
+			w := serve(svc, http.MethodPut, "/api/v1/subscriptions/"+id.String(), body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// idempotentService answers CreateIdempotent like the service does: the
+// first request with a key creates the subscription, replays with the
+// same request hash return it and replays with another one fail.
+func idempotentService() *fakeService {
+	type stored struct {
+		hash string
+		sub  model.Subscription
+	}
+	keys := make(map[string]stored)
+	return &fakeService{createIdempotent: func(ctx context.Context, sub *model.Subscription, key, requestHash string, allowOverlap bool) (uuid.UUID, bool, error) {
+		if first, ok := keys[key]; ok {
+			if first.hash != requestHash {
+				return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: %w", domain.ErrIdempotencyKeyReused)
+			}
+			*sub = first.sub
+			return sub.ID, true, nil
+		}
+		sub.ID, sub.Version = uuid.New(), 1
+		keys[key] = stored{hash: requestHash, sub: *sub}
+		return sub.ID, false, nil
+	}}
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	userID := uuid.New()
+	body := func(price int) string {
+		return fmt.Sprintf(`{"service_name":"Netflix","price_minor":%d,"user_id":"%s","start_date":"01-2025"}`, price, userID)
+	}
+	withKey := func(key string) http.Header {
+		return http.Header{idempotencyKeyHeader: []string{key}}
+	}
+
+	t.Run("replay returns the original subscription", func(t *testing.T) {
+		svc := idempotentService()
+		first := serve(svc, http.MethodPost, "/api/v1/subscriptions", body(500), withKey("key-1"))
+		replay := serve(svc, http.MethodPost, "/api/v1/subscriptions", body(500), withKey("key-1"))
+		for _, w := range []*httptest.ResponseRecorder{first, replay} {
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+			}
+		}
+		if first.Body.String() != replay.Body.String() {
+			t.Errorf("replayed body = %s, want %s", replay.Body.String(), first.Body.String())
+		}
+		if first.Header().Get("Location") != replay.Header().Get("Location") {
+			t.Errorf("replayed Location = %q, want %q", replay.Header().Get("Location"), first.Header().Get("Location"))
+		}
+	})
+
+	t.Run("reuse with another body", func(t *testing.T) {
+		svc := idempotentService()
+		if w := serve(svc, http.MethodPost, "/api/v1/subscriptions", body(500), withKey("key-1")); w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+		}
+		w := serve(svc, http.MethodPost, "/api/v1/subscriptions", body(700), withKey("key-1"))
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+		}
+		if msg := errorOf(t, w); !strings.Contains(msg, idempotencyKeyHeader) {
+			t.Errorf("error = %q, want it to name %s", msg, idempotencyKeyHeader)
+		}
+	})
+
+	t.Run("same body formatted differently", func(t *testing.T) {
+		svc := idempotentService()
+		first := serve(svc, http.MethodPost, "/api/v1/subscriptions", body(500), withKey("key-1"))
+		reformatted := strings.Replace(body(500), "01-2025", "2025-01", 1)
+		replay := serve(svc, http.MethodPost, "/api/v1/subscriptions", reformatted, withKey("key-1"))
+		if first.Code != http.StatusCreated || replay.Code != http.StatusCreated {
+			t.Errorf("statuses = %d, %d, want %d twice", first.Code, replay.Code, http.StatusCreated)
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		target string
+		key    string
+	}{
+		{name: "key too long", target: "/api/v1/subscriptions", key: strings.Repeat("k", maxIdempotencyKeyLength+1)},
+		{name: "key with if_not_exists", target: "/api/v1/subscriptions?if_not_exists=true", key: "key-1"},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{}
+			if w := serve(svc, http.MethodPost, tt.target, body(500), withKey(tt.key)); w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCreateOverlap(t *testing.T) {
+	conflicting := uuid.New()
+	body := fmt.Sprintf(`{"service_name":"Netflix","price_minor":500,"user_id":"%s","start_date":"01-2025"}`, uuid.New())
+
+	tests := []struct {
+		name       string
+		query      string
+		wantAllow  bool
+		wantStatus int
+	}{
+		{name: "overlapping", query: "", wantStatus: http.StatusUnprocessableEntity},
+		{name: "overlap allowed", query: "?allow_overlap=true", wantAllow: true, wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{create: func(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error) {
+				if allowOverlap != tt.wantAllow {
+					t.Errorf("Create() allowOverlap = %t, want %t", allowOverlap, tt.wantAllow)
+				}
+				if !allowOverlap {
+					return nil, &model.OverlapError{IDs: []uuid.UUID{conflicting}}
+				}
+				sub.ID = uuid.New()
+				return sub, nil
+			}}
+
+			w := serve(svc, http.MethodPost, "/api/v1/subscriptions"+tt.query, body, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusUnprocessableEntity {
+				return
+			}
+			var resp struct {
+				ConflictingIDs []uuid.UUID `json:"conflicting_ids"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.ConflictingIDs) != 1 || resp.ConflictingIDs[0] != conflicting {
+				t.Errorf("conflicting_ids = %v, want [%s]", resp.ConflictingIDs, conflicting)
+			}
+		})
+	}
+}
+
+func TestRenewMonths(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantMonths int
+		wantStatus int
+	}{
+		{name: "no body", body: "", wantMonths: 1, wantStatus: http.StatusOK},
+		{name: "months omitted", body: `{}`, wantMonths: 1, wantStatus: http.StatusOK},
+		{name: "a year", body: `{"months":12}`, wantMonths: 12, wantStatus: http.StatusOK},
+		{name: "the maximum", body: `{"months":60}`, wantMonths: 60, wantStatus: http.StatusOK},
+		{name: "zero", body: `{"months":0}`, wantStatus: http.StatusBadRequest},
+		{name: "negative", body: `{"months":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "above the maximum", body: `{"months":61}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := model.NewMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))
+			svc := &fakeService{renew: func(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error) {
+				if months != tt.wantMonths {
+					t.Errorf("Renew() months = %d, want %d", months, tt.wantMonths)
+				}
+				return &model.Subscription{ID: id, EndDate: &end, Version: 2}, nil
+			}}
+
+			w := serve(svc, http.MethodPost, "/api/v1/subscriptions/"+uuid.New().String()+"/renew", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				EndDate string `json:"end_date"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.EndDate != end.String() {
+				t.Errorf("end_date = %q, want %q", resp.EndDate, end.String())
+			}
+		})
+	}
+}
+
+func TestRenewErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "missing", err: fmt.Errorf("repository.Renew: %w", domain.ErrNotFound), wantStatus: http.StatusNotFound},
+		{name: "cancelled", err: fmt.Errorf("repository.Renew: %w", domain.ErrCancelled), wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeService{renew: func(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error) {
+				return nil, tt.err
+			}}
+			if w := serve(svc, http.MethodPost, "/api/v1/subscriptions/"+uuid.New().String()+"/renew", "", nil); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	memberID := uuid.New()
+	body := func(share int) string {
+		return fmt.Sprintf(`{"user_id":"%s","share_percent":%d}`, memberID, share)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		added      bool
+		err        error
+		wantStatus int
+	}{
+		{name: "added", body: body(33), added: true, wantStatus: http.StatusCreated},
+		{name: "share changed", body: body(34), wantStatus: http.StatusOK},
+		{name: "whole price", body: body(100), added: true, wantStatus: http.StatusCreated},
+		{name: "zero share", body: body(0), wantStatus: http.StatusBadRequest},
+		{name: "share above 100", body: body(101), wantStatus: http.StatusBadRequest},
+		{name: "shares above 100 together", body: body(50), err: fmt.Errorf("repository.SetMember: %w", domain.ErrSharesExceeded), wantStatus: http.StatusBadRequest},
+		{name: "owner", body: body(33), err: fmt.Errorf("repository.SetMember: %w", domain.ErrMemberIsOwner), wantStatus: http.StatusBadRequest},
+		{name: "missing subscription", body: body(33), err: fmt.Errorf("repository.SetMember: %w", domain.ErrNotFound), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.New()
+			svc := &fakeService{addMember: func(ctx context.Context, member *model.SubscriptionMember) (bool, error) {
+				if member.SubscriptionID != id || member.UserID != memberID {
+					t.Errorf("AddMember() member = %+v, want user %s of %s", member, memberID, id)
+				}
+				return tt.added, tt.err
+			}}
+
+			w := serve(svc, http.MethodPost, "/api/v1/subscriptions/"+id.String()+"/members", tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReportsRequireCurrency(t *testing.T) {
+	userID := uuid.New().String()
+	period := "start_date=01-2025&end_date=03-2025"
+	tests := []struct {
+		path, query string
+	}{
+		{path: "/api/v1/users/" + userID + "/summary"},
+		{path: "/api/v1/subscriptions/stats"},
+		{path: "/api/v1/subscriptions/top_services", query: "user_id=" + userID + "&period=01-2025:03-2025"},
+		{path: "/api/v1/subscriptions/cost_comparison", query: "user_id=" + userID + "&" + period},
+		{path: "/api/v1/subscriptions/average_monthly_cost", query: "user_id=" + userID + "&" + period},
+		{path: "/api/v1/subscriptions/forecast", query: "user_id=" + userID},
+		{path: "/api/v1/admin/reports/costs", query: period},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Both requests are rejected before the service, which
+			// implements nothing, is reached.
+			for _, currency := range []string{"", "EUR"} {
+				w := serve(&fakeService{}, http.MethodGet, tt.path+"?"+tt.query+"&currency="+currency, "", nil)
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("currency %q: status = %d, want %d: %s", currency, w.Code, http.StatusBadRequest, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPatchOptionalFields(t *testing.T) {
+	contentTypes := []string{"application/json", mimeMergePatch}
+	tests := []struct {
+		name      string
+		body      string
+		wantEnd   string // MM-YYYY, empty when end_date stays as it is
+		wantClear bool
+	}{
+		{name: "absent", body: `{"notes":"n"}`},
+		{name: "null", body: `{"end_date":null}`, wantClear: true},
+		{name: "value", body: `{"end_date":"12-2025"}`, wantEnd: "12-2025"},
+	}
+
+	for _, contentType := range contentTypes {
+		for _, tt := range tests {
+			t.Run(contentType+"/"+tt.name, func(t *testing.T) {
+				var got *model.SubscriptionPatch
+				svc := &fakeService{updateFields: func(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error {
+					got = &patch
+					return nil
+				}}
+
+				w := serve(svc, http.MethodPatch, "/api/v1/subscriptions/"+uuid.New().String(), tt.body, http.Header{"Content-Type": {contentType}})
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+				}
+				if got.ClearEndDate != tt.wantClear {
+					t.Errorf("ClearEndDate = %v, want %v", got.ClearEndDate, tt.wantClear)
+				}
+				var end string
+				if got.EndDate != nil {
+					end = got.EndDate.String()
+				}
+				if end != tt.wantEnd {
+					t.Errorf("EndDate = %q, want %q", end, tt.wantEnd)
+				}
+			})
+		}
+	}
+}
+
+func TestPatchEmptyBody(t *testing.T) {
+	contentTypes := []string{"application/json", mimeMergePatch}
+	bodies := []struct {
+		name string
+		body string
+	}{
+		{name: "empty object", body: `{}`},
+		{name: "whitespace", body: " \n\t"},
+		{name: "no body", body: ""},
+	}
+
+	for _, contentType := range contentTypes {
+		for _, tt := range bodies {
+			t.Run(contentType+"/"+tt.name, func(t *testing.T) {
+				var called bool
+				// Like the service, reject a patch that changes nothing.
+				svc := &fakeService{updateFields: func(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error {
+					called = true
+					if patch.IsEmpty() {
+						return model.ValidationError("at least one field must be provided")
+					}
+					return nil
+				}}
+
+				w := serve(svc, http.MethodPatch, "/api/v1/subscriptions/"+uuid.New().String(), tt.body, http.Header{"Content-Type": {contentType}})
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+				}
+				if !called {
+					t.Error("the empty patch did not reach the service")
+				}
+			})
+		}
+	}
+}
+
+func TestCreateAndReplaceValidatePrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		price     string // members spliced into the body
+		valid     bool
+		wantPrice int
+	}{
+		{name: "zero", price: `"price_minor":0,`, valid: true, wantPrice: 0},
+		{name: "zero decimal", price: `"price_decimal":"0.00",`, valid: true, wantPrice: 0},
+		{name: "positive", price: `"price_minor":999,`, valid: true, wantPrice: 999},
+		{name: "missing", price: ``},
+		{name: "negative", price: `"price_minor":-1,`},
+		{name: "negative decimal", price: `"price_decimal":"-1.00",`},
+	}
+	requests := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{method: http.MethodPost, path: "/api/v1/subscriptions", body: fmt.Sprintf(`{"service_name":"Netflix",%%s"user_id":"%s","start_date":"01-2025"}`, uuid.New()), wantStatus: http.StatusCreated},
+		{method: http.MethodPut, path: "/api/v1/subscriptions/" + uuid.New().String(), body: `{"service_name":"Netflix",%s"start_date":"01-2025"}`, wantStatus: http.StatusNoContent},
+	}
+
+	for _, req := range requests {
+		for _, tt := range tests {
+			t.Run(req.method+"/"+tt.name, func(t *testing.T) {
+				var got *int
+				svc := &fakeService{
+					create: func(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error) {
+						got, sub.ID = &sub.PriceMinor, uuid.New()
+						return sub, nil
+					},
+					update: func(ctx context.Context, sub *model.Subscription, allowOverlap bool) error {
+						got = &sub.PriceMinor
+						return nil
+					},
+				}
+
+				wantStatus := http.StatusBadRequest
+				if tt.valid {
+					wantStatus = req.wantStatus
+				}
+				w := serve(svc, req.method, req.path, fmt.Sprintf(req.body, tt.price), nil)
+				if w.Code != wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, wantStatus, w.Body.String())
+				}
+				switch {
+				case !tt.valid && got != nil:
+					t.Error("an invalid price reached the service")
+				case tt.valid && (got == nil || *got != tt.wantPrice):
+					t.Errorf("price = %v, want %d", got, tt.wantPrice)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"subscriptions-service/internal/model"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+)
+
+// requestHash fingerprints a validated subscription so that a replayed
+// idempotency key can be matched against the request it was first used
+// with. Hashing the normalized value makes formatting differences, such as
+// 2024-03 versus 03-2024, irrelevant.
+func requestHash(sub *model.Subscription) string {
+	// Marshaling a Subscription cannot fail.
+	data, _ := json.Marshal(sub)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportRows caps the number of data rows accepted in a single import.
+const maxImportRows = 10000
+
+// Import godoc
+// @Summary      Import subscriptions from CSV
+// @Description  Import subscriptions from an uploaded CSV file with a header row (service_name, price_minor or price_decimal, user_id, start_date and optionally plan, currency, billing_period, end_date, trial_end_date, discount_percent, discount_until, metadata as a JSON object and notes, dates in MM-YYYY). Valid rows are inserted in a single transaction; invalid rows are reported with their line numbers. A row the database rejects fails the whole import, and the error names its line when the database tells which row it was.
+// @Tags         subscriptions
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file    formData file true  "CSV file"
+// @Param        dry_run query    bool false "Validate without inserting"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.ImportResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /subscriptions/import [post]
+func (h *Handler) Import(c *gin.Context) {
+	h.log.Info("handler: importing subscriptions")
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		h.log.Error("failed to read uploaded file", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.log.Error("failed to open uploaded file", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		h.log.Error("failed to read csv header", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read csv header"})
+		return
+	}
+	index, err := csvHeader(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := model.ImportResponse{DryRun: dryRun, Errors: make([]model.ImportRowError, 0)}
+	var subs []model.Subscription
+	var lines []int // the line of each of subs, to point at rejected rows
+	for rows := 0; ; rows++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				resp.Errors = append(resp.Errors, model.ImportRowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+				continue
+			}
+			h.log.Error("failed to read csv", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read csv"})
+			return
+		}
+		if rows >= maxImportRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many rows, maximum is %d", maxImportRows)})
+			return
+		}
+
+		line, _ := reader.FieldPos(0)
+		sub, err := subscriptionFromCSV(index, record, h.parseCurrency)
+		if err != nil {
+			resp.Errors = append(resp.Errors, model.ImportRowError{Line: line, Error: err.Error()})
+			continue
+		}
+		subs = append(subs, sub)
+		lines = append(lines, line)
+	}
+	resp.Valid = len(subs)
+
+	if !dryRun {
+		if err := h.service.Import(c.Request.Context(), subs); err != nil {
+			var itemErr *domain.BulkItemError
+			if errors.Is(err, domain.ErrConflict) {
+				h.log.Warn("import conflicts with an active subscription", "error", err)
+				body := conflictResponse(err)
+				if errors.As(err, &itemErr) {
+					body["line"] = lines[itemErr.Index]
+				}
+				c.JSON(http.StatusConflict, body)
+				return
+			}
+			if errors.Is(err, domain.ErrUnknownUser) {
+				h.log.Warn("import names an unknown user", "error", err)
+				body := gin.H{"error": unknownUserMessage}
+				if errors.As(err, &itemErr) {
+					body["line"] = lines[itemErr.Index]
+				}
+				c.JSON(http.StatusUnprocessableEntity, body)
+				return
+			}
+			var quotaErr *model.QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				h.log.Warn("subscription quota exceeded", "error", err)
+				c.JSON(http.StatusUnprocessableEntity, quotaResponse(quotaErr))
+				return
+			}
+			if errors.Is(err, service.ErrUserServiceUnavailable) {
+				h.log.Error("failed to validate users", "error", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+				return
+			}
+			h.log.Error("failed to import subscriptions", "error", err)
+			h.serverError(c, err, "failed to import subscriptions")
+			return
+		}
+		resp.Inserted = len(subs)
+	}
+
+	h.log.Info("handler: imported subscriptions", "valid", resp.Valid, "inserted", resp.Inserted, "rejected", len(resp.Errors))
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListMembers godoc
+// @Summary      List the members of a subscription
+// @Description  Get the users sharing a subscription with its owner, oldest first. The owner pays whatever the members' shares leave.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      string  true  "Subscription ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.SubscriptionMember
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/members [get]
+func (h *Handler) ListMembers(c *gin.Context) {
+	h.log.Info("handler: listing subscription members", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+
+	members, err := h.service.ListMembers(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+			return
+		}
+		h.log.Error("failed to list subscription members", "error", err)
+		h.serverError(c, err, "failed to list subscription members")
+		return
+	}
+
+	h.log.Info("handler: listed subscription members", "id", id.String(), "count", len(members))
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember godoc
+// @Summary      Share a subscription with a user
+// @Description  Add a user to a subscription with a share of its price in percent, or change the share of a user who is a member already. The shares of all members must not add up to more than 100%; the owner keeps user_id and pays the remainder. A user's total cost includes their share, rounded down, of the subscriptions shared with them; the owner's part absorbs the rounding, so the parts always add up to the full price.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id    path string                 true "Subscription ID"
+// @Param        input body model.AddMemberRequest true "Member"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.SubscriptionMember
+// @Success      201  {object}  model.SubscriptionMember
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/members [post]
+func (h *Handler) AddMember(c *gin.Context) {
+	h.log.Info("handler: adding subscription member", "id", c.Param("id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+	var req model.AddMemberRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member := &model.SubscriptionMember{SubscriptionID: id, UserID: req.UserID, SharePercent: req.SharePercent}
+	added, err := h.service.AddMember(c.Request.Context(), member)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			h.log.Warn("subscription not found", "id", id.String())
+			c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+		case errors.Is(err, domain.ErrMemberIsOwner):
+			c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrMemberIsOwner.Error()})
+		case errors.Is(err, domain.ErrSharesExceeded):
+			c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrSharesExceeded.Error()})
+		default:
+			h.log.Error("failed to add subscription member", "error", err)
+			h.serverError(c, err, "failed to add subscription member")
+		}
+		return
+	}
+
+	h.log.Info("handler: added subscription member", "id", id.String(), "user_id", member.UserID.String(), "added", added)
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.JSON(status, member)
+}
+
+// RemoveMember godoc
+// @Summary      Stop sharing a subscription with a user
+// @Description  Remove a user from the members of a subscription. Their share falls back to the owner.
+// @Tags         subscriptions
+// @Param        id      path string true "Subscription ID"
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveMember(c *gin.Context) {
+	h.log.Info("handler: removing subscription member", "id", c.Param("id"), "user_id", c.Param("user_id"))
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.log.Error("invalid id format", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+		return
+	}
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), id, userID); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+			return
+		}
+		h.log.Error("failed to remove subscription member", "error", err)
+		h.serverError(c, err, "failed to remove subscription member")
+		return
+	}
+
+	h.log.Info("handler: removed subscription member", "id", id.String(), "user_id", userID.String())
+	c.Status(http.StatusNoContent)
+}
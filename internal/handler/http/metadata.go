@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+)
+
+// metadataFilterPrefix marks the query parameters that filter subscriptions
+// by metadata, e.g. metadata.cost_center=42.
+const metadataFilterPrefix = "metadata."
+
+// metadataFilter collects the metadata.<key>=<value> query parameters. Like
+// c.Query, a repeated parameter uses its first value. It returns nil when
+// there are none.
+func metadataFilter(query url.Values) map[string]string {
+	var filter map[string]string
+	for name, values := range query {
+		key, ok := strings.CutPrefix(name, metadataFilterPrefix)
+		if !ok || key == "" || len(values) == 0 {
+			continue
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[key] = values[0]
+	}
+	return filter
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationPreferences godoc
+// @Summary      Get a user's notification preferences
+// @Description  Get how a user wants to be reminded of upcoming renewals. Users who have not stored preferences get the defaults, with is_default set: a reminder 7 days before a renewal, by neither email nor webhook.
+// @Tags         users
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.NotificationPreferences
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/notification_preferences [get]
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	h.log.Info("handler: getting notification preferences", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	prefs, err := h.service.GetNotificationPreferences(c.Request.Context(), userID)
+	if err != nil {
+		h.log.Error("failed to get notification preferences", "error", err)
+		h.serverError(c, err, "failed to get notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// SetNotificationPreferences godoc
+// @Summary      Set a user's notification preferences
+// @Description  Replace how a user wants to be reminded of upcoming renewals. Fields left out take their default. remind_before_renewal_days must be between 1 and 90 and webhook_url an absolute http or https URL.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        user_id path string                                  true "User ID"
+// @Param        input   body model.SetNotificationPreferencesRequest true "Notification preferences"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.NotificationPreferences
+// @Success      201  {object}  model.NotificationPreferences
+// @Failure      400  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /users/{user_id}/notification_preferences [put]
+func (h *Handler) SetNotificationPreferences(c *gin.Context) {
+	h.log.Info("handler: setting notification preferences", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	var req model.SetNotificationPreferencesRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs := model.DefaultNotificationPreferences(userID)
+	if req.RemindBeforeRenewalDays != nil {
+		prefs.RemindBeforeRenewalDays = *req.RemindBeforeRenewalDays
+	}
+	if req.EmailEnabled != nil {
+		prefs.EmailEnabled = *req.EmailEnabled
+	}
+	prefs.WebhookURL = req.WebhookURL
+
+	created, err := h.service.SetNotificationPreferences(c.Request.Context(), prefs)
+	if err != nil {
+		var validationErr model.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			h.log.Warn("invalid notification preferences", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		case errors.Is(err, domain.ErrUnknownUser):
+			h.log.Warn("unknown user", "user_id", userID.String())
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": unknownUserMessage})
+		case errors.Is(err, service.ErrUserServiceUnavailable):
+			h.log.Error("failed to validate user", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrUserServiceUnavailable.Error()})
+		default:
+			h.log.Error("failed to set notification preferences", "error", err)
+			h.serverError(c, err, "failed to set notification preferences")
+		}
+		return
+	}
+
+	h.log.Info("handler: set notification preferences", "user_id", userID.String(), "created", created)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, prefs)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"subscriptions-service/internal/model"
+)
+
+// mimeMergePatch is the media type of JSON Merge Patch (RFC 7386) bodies.
+const mimeMergePatch = "application/merge-patch+json"
+
+// decodeMergePatch decodes a JSON Merge Patch document into a subscription
+// patch. Members set to null remove the field, which only plan, end_date,
+// trial_end_date, the discount fields, metadata and notes allow; a null
+// discount_percent or discount_until removes the whole discount. Metadata
+// keys are merged into the stored ones, with null removing a key. Members
+// that are not mutable subscription fields or the expected version are
+// rejected.
+func decodeMergePatch(r io.Reader) (model.SubscriptionPatch, error) {
+	var patch model.SubscriptionPatch
+
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return patch, fmt.Errorf("invalid merge patch: %w", err)
+	}
+	if doc == nil {
+		return patch, errors.New("merge patch must be a JSON object")
+	}
+
+	var unknown []string
+	var priceDecimal *string
+	for name, raw := range doc {
+		isNull := string(raw) == "null"
+		var err error
+		switch name {
+		case "service_name":
+			if isNull {
+				return patch, errors.New("service_name cannot be removed")
+			}
+			patch.ServiceName = new(string)
+			err = json.Unmarshal(raw, patch.ServiceName)
+		case "plan":
+			if isNull {
+				patch.ClearPlan = true
+				continue
+			}
+			patch.Plan = new(string)
+			err = json.Unmarshal(raw, patch.Plan)
+		case "price_minor":
+			if isNull {
+				return patch, errors.New("price_minor cannot be removed")
+			}
+			patch.PriceMinor = new(int)
+			err = json.Unmarshal(raw, patch.PriceMinor)
+		case "price_decimal":
+			if isNull {
+				return patch, errors.New("price_decimal cannot be removed")
+			}
+			priceDecimal = new(string)
+			err = json.Unmarshal(raw, priceDecimal)
+		case "currency":
+			if isNull {
+				return patch, errors.New("currency cannot be removed")
+			}
+			patch.Currency = new(string)
+			err = json.Unmarshal(raw, patch.Currency)
+		case "billing_period":
+			if isNull {
+				return patch, errors.New("billing_period cannot be removed")
+			}
+			patch.BillingPeriod = new(string)
+			err = json.Unmarshal(raw, patch.BillingPeriod)
+		case "start_date":
+			if isNull {
+				return patch, errors.New("start_date cannot be removed")
+			}
+			patch.StartDate = new(model.MonthYear)
+			err = json.Unmarshal(raw, patch.StartDate)
+		case "end_date":
+			if isNull {
+				patch.ClearEndDate = true
+				continue
+			}
+			patch.EndDate = new(model.MonthYear)
+			err = json.Unmarshal(raw, patch.EndDate)
+		case "auto_renew":
+			if isNull {
+				return patch, errors.New("auto_renew cannot be removed")
+			}
+			patch.AutoRenew = new(bool)
+			err = json.Unmarshal(raw, patch.AutoRenew)
+		case "trial_end_date":
+			if isNull {
+				patch.ClearTrialEndDate = true
+				continue
+			}
+			patch.TrialEndDate = new(model.MonthYear)
+			err = json.Unmarshal(raw, patch.TrialEndDate)
+		case "discount_percent":
+			if isNull {
+				patch.ClearDiscount = true
+				continue
+			}
+			patch.DiscountPercent = new(int)
+			err = json.Unmarshal(raw, patch.DiscountPercent)
+		case "discount_until":
+			if isNull {
+				patch.ClearDiscount = true
+				continue
+			}
+			patch.DiscountUntil = new(model.MonthYear)
+			err = json.Unmarshal(raw, patch.DiscountUntil)
+		case "metadata":
+			if isNull {
+				patch.ClearMetadata = true
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.Metadata)
+		case "notes":
+			if isNull {
+				patch.ClearNotes = true
+				continue
+			}
+			patch.Notes = new(string)
+			err = json.Unmarshal(raw, patch.Notes)
+		case "status":
+			if isNull {
+				return patch, errors.New("status cannot be removed")
+			}
+			patch.Status = new(string)
+			err = json.Unmarshal(raw, patch.Status)
+		case "version":
+			if isNull {
+				return patch, errors.New("version cannot be removed")
+			}
+			err = json.Unmarshal(raw, &patch.Version)
+			if err == nil && patch.Version < 1 {
+				err = errors.New("must be at least 1")
+			}
+		default:
+			unknown = append(unknown, name)
+		}
+		if err != nil {
+			return patch, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	if len(unknown) > 0 {
+		return patch, unknownFieldsError(unknown)
+	}
+	price, err := model.ResolvePrice(patch.PriceMinor, priceDecimal)
+	if err != nil {
+		return patch, err
+	}
+	patch.PriceMinor = price
+	return patch, nil
+}
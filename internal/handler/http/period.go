@@ -0,0 +1,28 @@
+package http
+
+import (
+	"fmt"
+	"time"
+)
+
+// periodPresets lists the values accepted by the period query parameter.
+const periodPresets = "current_month, last_3_months, last_12_months, ytd"
+
+// presetPeriod resolves a named period relative to now into its first and
+// last month, both inclusive. Every preset ends with the current month.
+func presetPeriod(name string, now time.Time) (time.Time, time.Time, error) {
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	switch name {
+	case "current_month":
+		return to, to, nil
+	case "last_3_months":
+		return to.AddDate(0, -2, 0), to, nil
+	case "last_12_months":
+		return to.AddDate(0, -11, 0), to, nil
+	case "ytd":
+		return time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC), to, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, supported values: %s", name, periodPresets)
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+)
+
+func TestPresetPeriod(t *testing.T) {
+	january := time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+	december := time.Date(2025, time.December, 31, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		preset   string
+		now      time.Time
+		wantFrom string
+		wantTo   string
+		wantErr  bool
+	}{
+		{name: "current month in January", preset: "current_month", now: january, wantFrom: "01-2025", wantTo: "01-2025"},
+		{name: "last 3 months from January", preset: "last_3_months", now: january, wantFrom: "11-2024", wantTo: "01-2025"},
+		{name: "last 12 months from January", preset: "last_12_months", now: january, wantFrom: "02-2024", wantTo: "01-2025"},
+		{name: "ytd in January", preset: "ytd", now: january, wantFrom: "01-2025", wantTo: "01-2025"},
+		{name: "current month in December", preset: "current_month", now: december, wantFrom: "12-2025", wantTo: "12-2025"},
+		{name: "last 3 months from December", preset: "last_3_months", now: december, wantFrom: "10-2025", wantTo: "12-2025"},
+		{name: "last 12 months from December", preset: "last_12_months", now: december, wantFrom: "01-2025", wantTo: "12-2025"},
+		{name: "ytd in December", preset: "ytd", now: december, wantFrom: "01-2025", wantTo: "12-2025"},
+		{name: "unknown", preset: "last_week", now: january, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := presetPeriod(tt.preset, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("presetPeriod() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := model.FormatMonthYear(from); got != tt.wantFrom {
+				t.Errorf("presetPeriod() from = %s, want %s", got, tt.wantFrom)
+			}
+			if got := model.FormatMonthYear(to); got != tt.wantTo {
+				t.Errorf("presetPeriod() to = %s, want %s", got, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package http
+
+import (
+	"errors"
+	"subscriptions-service/internal/model"
+)
+
+// requiredPrice resolves the price of a request that must carry one,
+// given either in minor units or as a decimal in major units.
+func requiredPrice(minor *int, decimal *string) (int, error) {
+	price, err := model.ResolvePrice(minor, decimal)
+	if err != nil {
+		return 0, err
+	}
+	if price == nil {
+		return 0, errors.New("price_minor or price_decimal is required")
+	}
+	return *price, nil
+}
@@ -16,15 +16,72 @@ func (h *Handler) InitRoutes() *gin.Engine {
 
 	// API
 	api := router.Group("/api/v1")
+	api.Use(requireTenant, recordActor)
 	{
 		subscriptions := api.Group("/subscriptions")
 		{
 			subscriptions.POST("", h.Create)
 			subscriptions.GET("", h.List)
+			subscriptions.DELETE("", h.DeleteMatching)
 			subscriptions.GET("/total_cost", h.GetTotalCost)
+			subscriptions.GET("/cost_comparison", h.GetCostComparison)
+			subscriptions.GET("/average_monthly_cost", h.GetAverageMonthlyCost)
+			subscriptions.GET("/forecast", h.GetForecast)
+			subscriptions.GET("/stats", h.GetStats)
+			subscriptions.GET("/spend_series", h.GetSpendSeries)
+			subscriptions.GET("/top_services", h.GetTopServices)
+			subscriptions.GET("/export", h.Export)
+			subscriptions.POST("/bulk", h.BulkCreate)
+			subscriptions.POST("/import", h.Import)
+			subscriptions.POST("/batch_get", h.BatchGet)
 			subscriptions.GET("/:id", h.GetByID)
+			subscriptions.HEAD("/:id", h.Head)
 			subscriptions.PUT("/:id", h.Update)
+			subscriptions.PATCH("/:id", h.Patch)
 			subscriptions.DELETE("/:id", h.Delete)
+			subscriptions.POST("/:id/cancel", h.Cancel)
+			subscriptions.POST("/:id/renew", h.Renew)
+			subscriptions.GET("/:id/history", h.GetHistory)
+			subscriptions.GET("/:id/members", h.ListMembers)
+			subscriptions.POST("/:id/members", h.AddMember)
+			subscriptions.DELETE("/:id/members/:user_id", h.RemoveMember)
+		}
+
+		services := api.Group("/services")
+		{
+			services.POST("", h.CreateCatalogEntry)
+			services.GET("", h.ListCatalogEntries)
+			services.GET("/:id", h.GetCatalogEntry)
+			services.PUT("/:id", h.UpdateCatalogEntry)
+			services.DELETE("/:id", h.DeleteCatalogEntry)
+		}
+
+		users := api.Group("/users")
+		{
+			users.POST("", h.CreateUser)
+			users.GET("/:user_id", h.GetUser)
+			users.GET("/:user_id/subscriptions", h.ListByUser)
+			users.GET("/:user_id/summary", h.GetSummary)
+			users.GET("/:user_id/budget", h.GetBudget)
+			users.PUT("/:user_id/budget", h.SetBudget)
+			users.GET("/:user_id/budget/status", h.GetBudgetStatus)
+			users.GET("/:user_id/notification_preferences", h.GetNotificationPreferences)
+			users.PUT("/:user_id/notification_preferences", h.SetNotificationPreferences)
+			users.DELETE("/:user_id/data", h.EraseUserData)
+			users.POST("/:user_id/anonymize", h.Anonymize)
+		}
+
+		admin := api.Group("/admin")
+		{
+			admin.GET("/reports/costs", h.GetCostReport)
+			admin.GET("/subscriptions", h.AdminList)
+			admin.GET("/subscriptions/:id", h.AdminGetByID)
+			admin.GET("/subscriptions/duplicates", h.GetDuplicates)
+			admin.POST("/subscriptions/reprice", h.Reprice)
+			admin.POST("/subscriptions/purge", h.Purge)
+			admin.POST("/subscriptions/archive", h.Archive)
+			admin.GET("/anonymizations/:user_id", h.GetAnonymization)
+			admin.PUT("/exchange_rates", h.UpsertExchangeRates)
 		}
 	}
 
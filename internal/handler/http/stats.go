@@ -0,0 +1,409 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetStats godoc
+// @Summary      Get subscription statistics
+// @Description  Get per-service subscription count, total and average price, plus overall totals. Omit user_id for global stats. With group_by=catalog, subscriptions linked to the service catalog are grouped by catalog entry instead of by their service name. Appending ",plan", as in group_by=service,plan, breaks every service down further by plan.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id query string false "User ID"
+// @Param        currency query string true "ISO 4217 currency to report"
+// @Param        group_by query string false "Grouping: service_name (default), service or catalog, optionally followed by ,plan (e.g. service,plan)"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.StatsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/stats [get]
+func (h *Handler) GetStats(c *gin.Context) {
+	h.log.Info("handler: getting stats")
+	var userID *uuid.UUID
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := parseUserID(raw)
+		if err != nil {
+			h.log.Error("invalid user_id", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		userID = &id
+	}
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	grouping, err := parseStatsGrouping(c.Query("group_by"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.service.GetStats(c.Request.Context(), userID, currency, grouping)
+	if err != nil {
+		h.log.Error("failed to get stats", "error", err)
+		h.serverError(c, err, "failed to get stats")
+		return
+	}
+
+	h.log.Info("handler: got stats", "count", stats.Count)
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseStatsGrouping parses a group_by parameter: service_name (or its alias
+// service) or catalog, optionally followed by ",plan". An empty value groups
+// by service name.
+func parseStatsGrouping(raw string) (model.StatsGrouping, error) {
+	var grouping model.StatsGrouping
+	if raw == "" {
+		return grouping, nil
+	}
+	invalid := fmt.Errorf("invalid group_by %q: expected service_name, service or catalog, optionally followed by \",plan\"", raw)
+
+	first, rest, hasRest := strings.Cut(raw, ",")
+	switch strings.TrimSpace(first) {
+	case "service_name", "service":
+	case "catalog":
+		grouping.ByCatalog = true
+	default:
+		return grouping, invalid
+	}
+	if hasRest {
+		if strings.TrimSpace(rest) != "plan" {
+			return grouping, invalid
+		}
+		grouping.ByPlan = true
+	}
+	return grouping, nil
+}
+
+// maxSeriesMonths caps the number of months a spend series may span.
+const maxSeriesMonths = 120
+
+// GetSpendSeries godoc
+// @Summary      Get monthly spend series
+// @Description  Get what a user pays in one currency for every calendar month in a range, including months without spend. Every month is counted like total_cost, so the series adds up to the total_cost of the range.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id query string true "User ID"
+// @Param        currency query string true "ISO 4217 currency to report"
+// @Param        from    query string true "First month (MM-YYYY)"
+// @Param        to      query string true "Last month (MM-YYYY)"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.MonthlySpend
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/spend_series [get]
+func (h *Handler) GetSpendSeries(c *gin.Context) {
+	h.log.Info("handler: getting spend series")
+	userID, err := parseUserID(c.Query("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := parseMonthYear("from", c.Query("from"))
+	if err != nil {
+		h.log.Error("invalid from", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseMonthYear("to", c.Query("to"))
+	if err != nil {
+		h.log.Error("invalid to", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+	if months := monthsBetween(from, to) + 1; months > maxSeriesMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range must not exceed %d months", maxSeriesMonths)})
+		return
+	}
+
+	series, err := h.service.GetSpendSeries(c.Request.Context(), userID, currency, from, to)
+	if err != nil {
+		h.log.Error("failed to get spend series", "error", err)
+		h.serverError(c, err, "failed to get spend series")
+		return
+	}
+
+	h.log.Info("handler: got spend series", "months", len(series))
+	c.JSON(http.StatusOK, series)
+}
+
+const (
+	defaultTopServicesLimit = 10
+	maxTopServicesLimit     = 50
+)
+
+// GetTopServices godoc
+// @Summary      Get top services by spend
+// @Description  Get the user's services ranked by total monthly spend within a period. With group_by=service,plan, every plan of a service is ranked on its own.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id query string true  "User ID"
+// @Param        currency query string true "ISO 4217 currency to report"
+// @Param        period  query string true  "Period (MM-YYYY:MM-YYYY)"
+// @Param        limit   query int    false "Limit (default 10, max 50)"
+// @Param        group_by query string false "Grouping: service (default) or service,plan"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.ServiceSpend
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/top_services [get]
+func (h *Handler) GetTopServices(c *gin.Context) {
+	h.log.Info("handler: getting top services")
+	userID, err := parseUserID(c.Query("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rawFrom, rawTo, ok := strings.Cut(c.Query("period"), ":")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be in MM-YYYY:MM-YYYY format"})
+		return
+	}
+	from, err := parseMonthYear("period start", rawFrom)
+	if err != nil {
+		h.log.Error("invalid period", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseMonthYear("period end", rawTo)
+	if err != nil {
+		h.log.Error("invalid period", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period start must not be after period end"})
+		return
+	}
+
+	limit := defaultTopServicesLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit %q: must be a positive integer", raw)})
+			return
+		}
+	}
+	if limit > maxTopServicesLimit {
+		limit = maxTopServicesLimit
+	}
+	grouping, err := parseStatsGrouping(c.Query("group_by"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if grouping.ByCatalog {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "top services cannot be grouped by catalog"})
+		return
+	}
+
+	top, err := h.service.GetTopServices(c.Request.Context(), userID, currency, from, to, limit, grouping.ByPlan)
+	if err != nil {
+		h.log.Error("failed to get top services", "error", err)
+		h.serverError(c, err, "failed to get top services")
+		return
+	}
+
+	h.log.Info("handler: got top services", "count", len(top))
+	c.JSON(http.StatusOK, top)
+}
+
+// GetCostComparison godoc
+// @Summary      Compare cost with the previous period
+// @Description  Compare the total cost within a period with the immediately preceding period of equal length
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id      query string true  "User ID"
+// @Param        currency     query string true  "ISO 4217 currency to report"
+// @Param        service_name query string false "Service Name"
+// @Param        period       query string false "Period preset, mutually exclusive with start_date/end_date" Enums(current_month, last_3_months, last_12_months, ytd)
+// @Param        start_date   query string false "Start Date (MM-YYYY)"
+// @Param        end_date     query string false "End Date (MM-YYYY)"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.CostComparisonResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/cost_comparison [get]
+func (h *Handler) GetCostComparison(c *gin.Context) {
+	h.log.Info("handler: getting cost comparison")
+	userID, err := parseUserID(c.Query("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := parseCostPeriod(c)
+	if err != nil {
+		h.log.Error("invalid period", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from == nil || to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period or both start_date and end_date are required"})
+		return
+	}
+
+	comparison, err := h.service.CompareCosts(c.Request.Context(), userID, c.Query("service_name"), currency, *from, *to)
+	if err != nil {
+		h.log.Error("failed to compare costs", "error", err)
+		h.serverError(c, err, "failed to compare costs")
+		return
+	}
+
+	h.log.Info("handler: got cost comparison", "delta", comparison.Delta)
+	c.JSON(http.StatusOK, comparison)
+}
+
+// GetAverageMonthlyCost godoc
+// @Summary      Get average monthly cost
+// @Description  Get the mean monthly cost within a period, averaged over every month of the window or only over months with spend
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id      query string true  "User ID"
+// @Param        currency     query string true  "ISO 4217 currency to report"
+// @Param        service_name query string false "Service Name"
+// @Param        period       query string false "Period preset, mutually exclusive with start_date/end_date" Enums(current_month, last_3_months, last_12_months, ytd)
+// @Param        start_date   query string false "Start Date (MM-YYYY)"
+// @Param        end_date     query string false "End Date (MM-YYYY)"
+// @Param        basis        query string false "Months to average over (default window)" Enums(window, active_months)
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.AverageCostResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/average_monthly_cost [get]
+func (h *Handler) GetAverageMonthlyCost(c *gin.Context) {
+	h.log.Info("handler: getting average monthly cost")
+	userID, err := parseUserID(c.Query("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := parseCostPeriod(c)
+	if err != nil {
+		h.log.Error("invalid period", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from == nil || to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period or both start_date and end_date are required"})
+		return
+	}
+
+	basis := c.DefaultQuery("basis", model.AverageBasisWindow)
+	if basis != model.AverageBasisWindow && basis != model.AverageBasisActiveMonths {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid basis, supported values: window, active_months"})
+		return
+	}
+
+	average, err := h.service.GetAverageMonthlyCost(c.Request.Context(), userID, c.Query("service_name"), currency, *from, *to, basis)
+	if err != nil {
+		h.log.Error("failed to get average monthly cost", "error", err)
+		h.serverError(c, err, "failed to get average monthly cost")
+		return
+	}
+
+	h.log.Info("handler: got average monthly cost", "average", average.AverageMonthlyCost)
+	c.JSON(http.StatusOK, average)
+}
+
+const (
+	defaultForecastMonths = 12
+	maxForecastMonths     = 60
+)
+
+// GetForecast godoc
+// @Summary      Get spending forecast
+// @Description  Project the currently active subscriptions over the coming months, starting with next month. Subscriptions with auto_renew=false stop at their end_date; auto-renewing ones run through the whole forecast unless cancelled.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id query string true  "User ID"
+// @Param        currency query string true "ISO 4217 currency to report"
+// @Param        months  query int    false "Number of months (default 12, max 60)"
+// @Param        amortize query bool  false "Spread yearly and weekly prices evenly over the months"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.ForecastResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/forecast [get]
+func (h *Handler) GetForecast(c *gin.Context) {
+	h.log.Info("handler: getting forecast")
+	userID, err := parseUserID(c.Query("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	months := defaultForecastMonths
+	if raw := c.Query("months"); raw != "" {
+		months, err = strconv.Atoi(raw)
+		if err != nil || months <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid months %q: must be a positive integer", raw)})
+			return
+		}
+	}
+	if months > maxForecastMonths {
+		months = maxForecastMonths
+	}
+
+	forecast, err := h.service.GetForecast(c.Request.Context(), userID, currency, months, c.Query("amortize") == "true")
+	if err != nil {
+		h.log.Error("failed to get forecast", "error", err)
+		h.serverError(c, err, "failed to get forecast")
+		return
+	}
+
+	h.log.Info("handler: got forecast", "total_cost", forecast.TotalCost)
+	c.JSON(http.StatusOK, forecast)
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"net/http"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tenantHeader names the tenant a request is made for.
+const tenantHeader = "X-Tenant-ID"
+
+// requireTenant scopes a request to the tenant named by its X-Tenant-ID
+// header. Requests without a valid tenant are rejected.
+func requireTenant(c *gin.Context) {
+	raw := c.GetHeader(tenantHeader)
+	if raw == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": tenantHeader + " header is required"})
+		return
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil || id == uuid.Nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + tenantHeader + " header"})
+		return
+	}
+	c.Request = c.Request.WithContext(model.WithTenant(c.Request.Context(), id))
+	c.Next()
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UserService interface {
+	Create(ctx context.Context, user *model.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+}
+
+// CreateUser godoc
+// @Summary      Register a user
+// @Description  Register a user subscriptions can belong to. Without an id one is generated. Subscriptions can only be created for registered users.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        input body model.CreateUserRequest true "User Info"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      201  {object}  model.User
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users [post]
+func (h *Handler) CreateUser(c *gin.Context) {
+	h.log.Info("handler: creating user")
+	var req model.CreateUserRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.log.Error("failed to bind json", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := &model.User{Email: req.Email}
+	if req.ID != nil {
+		user.ID = *req.ID
+	}
+	if err := h.users.Create(c.Request.Context(), user); err != nil {
+		if errors.Is(err, domain.ErrUserExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrUserExists.Error()})
+			return
+		}
+		h.log.Error("failed to create user", "error", err)
+		h.serverError(c, err, "failed to create user")
+		return
+	}
+
+	h.log.Info("handler: created user", "id", user.ID.String())
+	c.JSON(http.StatusCreated, user)
+}
+
+// GetUser godoc
+// @Summary      Get a user
+// @Description  Get a single registered user by their ID
+// @Tags         users
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.User
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id} [get]
+func (h *Handler) GetUser(c *gin.Context) {
+	h.log.Info("handler: getting user", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	user, err := h.users.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		h.log.Error("failed to get user", "error", err)
+		h.serverError(c, err, "failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ListByUser godoc
+// @Summary      List a user's subscriptions
+// @Description  Get the subscriptions that belong to a single user. Filter by metadata with metadata.<key>=<value> query parameters.
+// @Tags         users
+// @Produce      json,text/csv
+// @Param        user_id path string true "User ID"
+// @Param        service_name query string false "Service name (case-insensitive)"
+// @Param        status query string false "Status" Enums(active, trialing, paused, cancelled, expired)
+// @Param        plan query string false "Plan (case-insensitive)"
+// @Param        limit query int false "Limit (0 or absent means default, values above the maximum are clamped)"
+// @Param        offset query int false "Offset"
+// @Param        fields query string false "Comma-separated list of fields to return, or of fields to leave out when prefixed with -"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {array}   model.Subscription
+// @Failure      400  {object}  map[string]string
+// @Failure      406  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/subscriptions [get]
+func (h *Handler) ListByUser(c *gin.Context) {
+	h.log.Info("handler: listing user subscriptions", "user_id", c.Param("user_id"))
+	if rejectIncludeDeleted(c) {
+		return
+	}
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	h.list(c, model.SubscriptionFilter{UserIDs: []uuid.UUID{userID}})
+}
+
+// GetSummary godoc
+// @Summary      Get a user's subscription summary
+// @Description  Get the number of active subscriptions, the total monthly cost, the most expensive subscription and the earliest start date for a user. Users with a budget also get it along with spent_this_month, remaining and over_budget, as returned by GET /users/{user_id}/budget.
+// @Tags         users
+// @Produce      json
+// @Param        user_id path string true "User ID"
+// @Param        currency query string true "ISO 4217 currency to report"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.SummaryResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/summary [get]
+func (h *Handler) GetSummary(c *gin.Context) {
+	h.log.Info("handler: getting user summary", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	currency, err := h.requiredCurrency(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.service.GetUserSummary(c.Request.Context(), userID, currency)
+	if err != nil {
+		h.log.Error("failed to get user summary", "error", err)
+		h.serverError(c, err, "failed to get user summary")
+		return
+	}
+
+	h.log.Info("handler: got user summary", "user_id", userID.String())
+	c.JSON(http.StatusOK, summary)
+}
+
+// EraseUserData godoc
+// @Summary      Erase a user's data
+// @Description  Remove everything stored about a user for good, in one transaction: their subscriptions, including soft-deleted and archived ones, their shares in the subscriptions of others, the user itself along with their budget and notification preferences, the audit entries recorded for them, the idempotency keys pointing at them and any cached aggregates. The confirm parameter must repeat the user_id. The erasure itself is recorded in the audit log with the number of rows removed, but none of their content.
+// @Tags         users
+// @Produce      json
+// @Param        user_id path  string true "User ID"
+// @Param        confirm query string true "The user_id again, to confirm the erasure"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.ErasureSummary
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/data [delete]
+func (h *Handler) EraseUserData(c *gin.Context) {
+	h.log.Info("handler: erasing user data", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	confirm, err := parseUserID(c.Query("confirm"))
+	if err != nil || confirm != userID {
+		h.log.Warn("erasure not confirmed", "user_id", userID.String())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm must repeat the user_id"})
+		return
+	}
+
+	summary, err := h.service.EraseUserData(c.Request.Context(), userID)
+	if err != nil {
+		h.log.Error("failed to erase user data", "error", err)
+		h.serverError(c, err, "failed to erase user data")
+		return
+	}
+
+	h.log.Info("handler: erased user data", "user_id", userID.String())
+	c.JSON(http.StatusOK, summary)
+}
+
+// Anonymize godoc
+// @Summary      Anonymize a user
+// @Description  Move every subscription of a user, including soft-deleted and archived ones, to a newly generated synthetic user, clear their notes and metadata and mark them anonymized. Prices and dates are kept, so costs stay the same under the synthetic user. Audit entries naming the user are removed. The link to the synthetic user can be looked up by admins unless irreversible=true, in which case it is not stored at all. Anonymizing a user again reuses their synthetic user.
+// @Tags         users
+// @Produce      json
+// @Param        user_id      path  string true  "User ID"
+// @Param        irreversible query bool   false "Do not keep the link to the synthetic user"
+// @Param        X-Tenant-ID header string true "Tenant ID"
+// @Success      200  {object}  model.AnonymizationResult
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/anonymize [post]
+func (h *Handler) Anonymize(c *gin.Context) {
+	h.log.Info("handler: anonymizing user", "user_id", c.Param("user_id"))
+	userID, err := parseUserID(c.Param("user_id"))
+	if err != nil {
+		h.log.Error("invalid user_id", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	irreversible := c.Query("irreversible") == "true"
+
+	result, err := h.service.Anonymize(c.Request.Context(), userID, irreversible)
+	if err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			h.log.Warn("anonymized subscriptions collide", "error", err)
+			c.JSON(http.StatusConflict, gin.H{"error": "the user's subscriptions collide with subscriptions of their synthetic user"})
+			return
+		}
+		h.log.Error("failed to anonymize user", "error", err)
+		h.serverError(c, err, "failed to anonymize user")
+		return
+	}
+
+	h.log.Info("handler: anonymized user", "user_id", userID.String(), "subscriptions", result.Subscriptions)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errVersionMismatch = errors.New("If-Match and version do not match")
+	errIfMatchRequired = errors.New("If-Match header or version is required")
+)
+
+// etag renders a subscription version as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseETag parses an entity tag produced by etag. Weak tags are accepted
+// since the version identifies the representation either way.
+func parseETag(value string) (int, error) {
+	tag := strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	version, err := strconv.Atoi(strings.Trim(tag, `"`))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match %q", value)
+	}
+	return version, nil
+}
+
+// expectedVersion returns the version a write is conditional on, taken from
+// the If-Match header or the version member of the body. Zero means the
+// write is unconditional, which is only allowed unless the handler is
+// configured to require If-Match. "If-Match: *" makes a write unconditional
+// as well.
+func (h *Handler) expectedVersion(ifMatch string, bodyVersion *int) (int, error) {
+	var version int
+	if ifMatch != "" && ifMatch != "*" {
+		var err error
+		if version, err = parseETag(ifMatch); err != nil {
+			return 0, err
+		}
+	}
+	if bodyVersion != nil {
+		if version != 0 && version != *bodyVersion {
+			return 0, errVersionMismatch
+		}
+		version = *bodyVersion
+	}
+	if version == 0 && ifMatch == "" && h.concurrency.RequireIfMatch {
+		return 0, errIfMatchRequired
+	}
+	return version, nil
+}
+
+// writeVersionError answers a request whose expected version could not be
+// determined.
+func (h *Handler) writeVersionError(c *gin.Context, err error) {
+	h.log.Warn("invalid precondition", "error", err)
+	status := http.StatusBadRequest
+	if errors.Is(err, errIfMatchRequired) {
+		status = http.StatusPreconditionRequired
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Anonymization links an anonymized user to the synthetic user their
+// subscriptions now belong to.
+type Anonymization struct {
+	UserID          uuid.UUID `json:"user_id"`
+	SyntheticUserID uuid.UUID `json:"synthetic_user_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// AnonymizationResult reports the anonymization of a user's
+// subscriptions. SyntheticUserID is left out when no link to it is kept:
+// for irreversible anonymizations and for users without subscriptions.
+type AnonymizationResult struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	SyntheticUserID *uuid.UUID `json:"synthetic_user_id,omitempty"`
+	Irreversible    bool       `json:"irreversible"`
+	Subscriptions   int64      `json:"subscriptions" example:"3"` // Subscriptions moved to the synthetic user
+	AuditEntries    int64      `json:"audit_log" example:"7"`     // Audit entries removed, as they name the user
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionDelete    = "delete"
+	AuditActionCancel    = "cancel"
+	AuditActionRenew     = "renew"
+	AuditActionArchive   = "archive"
+	AuditActionErase     = "erase"
+	AuditActionAnonymize = "anonymize"
+)
+
+// AuditEntry records one change to a subscription. OldValue and NewValue
+// hold the subscription as JSON before and after the change; OldValue is
+// null for creations and NewValue for deletions. Erasure and anonymization
+// entries have no SubscriptionID and carry a summary of the operation as
+// NewValue. Entries are only ever removed by an erasure or anonymization
+// of the user they belong to.
+type AuditEntry struct {
+	ID             int64           `json:"id"`
+	SubscriptionID *uuid.UUID      `json:"subscription_id,omitempty"`
+	Action         string          `json:"action" enums:"create,update,delete,cancel,renew,archive,erase,anonymize"`
+	OldValue       json.RawMessage `json:"old_value,omitempty" swaggertype:"object"`
+	NewValue       json.RawMessage `json:"new_value,omitempty" swaggertype:"object"`
+	Actor          string          `json:"actor" example:"anonymous"`
+	ClientIP       *string         `json:"client_ip,omitempty" example:"203.0.113.7"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// SubscriptionChange is a subscription changed by a write to many rows at
+// once, as it was before and after the write. After is nil when the write
+// removed it. TenantID is the tenant the subscription belongs to, which
+// jobs working across tenants record the change for.
+type SubscriptionChange struct {
+	TenantID uuid.UUID
+	Before   Subscription
+	After    *Subscription
+}
+
+// ErasureSummary reports how many rows the erasure of a user's data
+// removed from each table.
+type ErasureSummary struct {
+	UserID          uuid.UUID `json:"user_id"`
+	Subscriptions   int64     `json:"subscriptions" example:"3"`
+	AuditEntries    int64     `json:"audit_log" example:"7"`
+	IdempotencyKeys int64     `json:"idempotency_keys" example:"1"`
+}
+
+// HistoryEntry is an audit entry as shown in the history of a
+// subscription, with the subscription snapshots reduced to the fields that
+// changed.
+type HistoryEntry struct {
+	ID        int64         `json:"id"`
+	Action    string        `json:"action" enums:"create,update,delete,cancel,renew,archive"`
+	Changes   []FieldChange `json:"changes"`
+	Actor     string        `json:"actor" example:"anonymous"`
+	ClientIP  *string       `json:"client_ip,omitempty" example:"203.0.113.7"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// FieldChange is the change of a single subscription field. Old is null
+// for fields that were not set before and New for fields that were
+// cleared.
+type FieldChange struct {
+	Field string          `json:"field" example:"price_minor"`
+	Old   json.RawMessage `json:"old" swaggertype:"object"`
+	New   json.RawMessage `json:"new" swaggertype:"object"`
+}
+
+// derivedFields are left out of history diffs. They follow from other
+// fields or change with every write, so they would only add noise.
+var derivedFields = []string{"price_decimal", "effective_price", "next_billing_date", "updated_at", "version"}
+
+// History reduces e to the fields that changed between its old and new
+// value, ordered by field name.
+func (e AuditEntry) History() (HistoryEntry, error) {
+	entry := HistoryEntry{ID: e.ID, Action: e.Action, Actor: e.Actor, ClientIP: e.ClientIP, CreatedAt: e.CreatedAt}
+
+	var before, after map[string]json.RawMessage
+	if len(e.OldValue) > 0 {
+		if err := json.Unmarshal(e.OldValue, &before); err != nil {
+			return entry, fmt.Errorf("failed to decode audit entry %d: %w", e.ID, err)
+		}
+	}
+	if len(e.NewValue) > 0 {
+		if err := json.Unmarshal(e.NewValue, &after); err != nil {
+			return entry, fmt.Errorf("failed to decode audit entry %d: %w", e.ID, err)
+		}
+	}
+
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	entry.Changes = make([]FieldChange, 0)
+	for _, field := range fields {
+		if slices.Contains(derivedFields, field) {
+			continue
+		}
+		from, to := nonNull(before[field]), nonNull(after[field])
+		if bytes.Equal(from, to) {
+			continue
+		}
+		entry.Changes = append(entry.Changes, FieldChange{Field: field, Old: from, New: to})
+	}
+	return entry, nil
+}
+
+// nonNull returns nil for a JSON null, so that null and absent fields
+// compare equal.
+func nonNull(value json.RawMessage) json.RawMessage {
+	if bytes.Equal(value, []byte("null")) {
+		return nil
+	}
+	return value
+}
+
+// Actors recorded for changes without an authenticated user.
+const (
+	// AnonymousActor made a request without authentication, which is
+	// every request until authentication exists.
+	AnonymousActor = "anonymous"
+	// SystemActor is the service itself, such as a background job.
+	SystemActor = "system"
+)
+
+// Actor is who a change is attributed to. ClientIP is empty for changes
+// not made through the API.
+type Actor struct {
+	Name     string
+	ClientIP string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that attributes changes to actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored on ctx by WithActor, or
+// SystemActor when there is none.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: SystemActor}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BudgetPeriodMonthly is the only period budgets can be set for so far.
+const BudgetPeriodMonthly = "monthly"
+
+// Budget is what a user means to spend on subscriptions per period, in
+// minor units of Currency.
+type Budget struct {
+	UserID      uuid.UUID `json:"user_id"`
+	AmountMinor int64     `json:"amount_minor" example:"150000"`
+	Currency    string    `json:"currency" example:"RUB"`
+	Period      string    `json:"period" enums:"monthly"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SetBudgetRequest sets the budget of a user, replacing any budget they
+// have.
+type SetBudgetRequest struct {
+	AmountMinor *int64 `json:"amount_minor" binding:"required,gte=0" example:"150000"`
+	Currency    string `json:"currency,omitempty" example:"RUB"` // ISO 4217 code, defaults to the configured currency
+	Period      string `json:"period,omitempty" enums:"monthly"` // Defaults to monthly
+}
+
+// Budget statuses, from the spend of the current month compared with the
+// budget.
+const (
+	BudgetStatusOK       = "ok"
+	BudgetStatusWarning  = "warning"  // The spend reached the warning threshold
+	BudgetStatusExceeded = "exceeded" // The spend is above the budget
+)
+
+// BudgetUsage is a budget along with what the user spends against it in
+// the current month, in the currency of the budget.
+type BudgetUsage struct {
+	Budget         *Budget `json:"budget"`
+	SpentThisMonth int64   `json:"spent_this_month"`
+	Remaining      int64   `json:"remaining"` // Negative when over budget
+	OverBudget     bool    `json:"over_budget"`
+}
+
+// BudgetStatus evaluates the spend of a user in Month against their
+// budget.
+type BudgetStatus struct {
+	Status                  string `json:"status" enums:"ok,warning,exceeded"`
+	Month                   string `json:"month" example:"03-2024"` // Format: MM-YYYY
+	WarningThresholdPercent int    `json:"warning_threshold_percent" example:"80"`
+	*BudgetUsage
+}
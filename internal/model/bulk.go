@@ -0,0 +1,19 @@
+package model
+
+import "github.com/google/uuid"
+
+// BulkItemError describes why the item at Index of a bulk request was
+// rejected.
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BulkCreateResponse reports the outcome of a bulk creation. IDs follow the
+// order of the request and are null for rejected items.
+// @Description Bulk creation result
+type BulkCreateResponse struct {
+	IDs     []*uuid.UUID    `json:"ids"`
+	Created int             `json:"created"`
+	Errors  []BulkItemError `json:"errors"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CatalogEntry is a service in the service catalog. Subscriptions linked
+// to an entry carry its ID in ServiceID and its Name as their service name.
+// Names are unique case-insensitively.
+// @Description Service catalog entry
+type CatalogEntry struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name" example:"Netflix"`
+	URL       *string   `json:"url,omitempty" example:"https://www.netflix.com"`
+	Category  *string   `json:"category,omitempty" example:"streaming"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate applies the rules every stored catalog entry satisfies.
+func (e *CatalogEntry) Validate() error {
+	if e.Name == "" {
+		return ValidationError("name must not be empty")
+	}
+	return nil
+}
+
+// CatalogEntryRequest creates or replaces a catalog entry. Omitted url and
+// category are left empty.
+type CatalogEntryRequest struct {
+	Name     string  `json:"name" binding:"required" example:"Netflix"`
+	URL      *string `json:"url,omitempty" example:"https://www.netflix.com"`
+	Category *string `json:"category,omitempty" example:"streaming"`
+}
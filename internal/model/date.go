@@ -0,0 +1,125 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MonthYearLayout is the canonical MM-YYYY format subscription dates are
+// exchanged in. Dates are stored as the first day of that month.
+const MonthYearLayout = "01-2006"
+
+// isoMonthLayout is the ISO 8601 YYYY-MM format many date pickers emit.
+const isoMonthLayout = "2006-01"
+
+// legacyDateLayout is the YYYY-MM-DD format dates used to be accepted in.
+const legacyDateLayout = "2006-01-02"
+
+// ParseMonthYear parses a MM-YYYY or YYYY-MM date into the first day of
+// that month. Legacy YYYY-MM-DD dates are still accepted and truncated to
+// their month.
+func ParseMonthYear(value string) (time.Time, error) {
+	for _, layout := range []string{MonthYearLayout, isoMonthLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := time.Parse(legacyDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected MM-YYYY or YYYY-MM", value)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// FormatMonthYear formats t in the canonical MM-YYYY format.
+func FormatMonthYear(t time.Time) string {
+	return t.Format(MonthYearLayout)
+}
+
+// MonthYear is a calendar month. JSON carries it as a MM-YYYY string
+// (YYYY-MM is accepted on input) and DATE columns store the first day of
+// the month. The zero value is not a valid month.
+type MonthYear struct {
+	t time.Time
+}
+
+// NewMonthYear returns the month t falls in.
+func NewMonthYear(t time.Time) MonthYear {
+	return MonthYear{t: time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Time returns the first day of the month.
+func (m MonthYear) Time() time.Time {
+	return m.t
+}
+
+func (m MonthYear) IsZero() bool {
+	return m.t.IsZero()
+}
+
+// String formats m as MM-YYYY.
+func (m MonthYear) String() string {
+	return FormatMonthYear(m.t)
+}
+
+func (m MonthYear) Before(other MonthYear) bool {
+	return m.t.Before(other.t)
+}
+
+func (m MonthYear) After(other MonthYear) bool {
+	return m.t.After(other.t)
+}
+
+// AddMonths returns the month n months after m; n may be negative.
+func (m MonthYear) AddMonths(n int) MonthYear {
+	return MonthYear{t: m.t.AddDate(0, n, 0)}
+}
+
+// MonthsBetween returns the number of whole months from a to b, negative
+// when b is before a.
+func MonthsBetween(a, b MonthYear) int {
+	return (b.t.Year()-a.t.Year())*12 + int(b.t.Month()) - int(a.t.Month())
+}
+
+func (m MonthYear) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *MonthYear) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid date %s: expected a MM-YYYY string", data)
+	}
+	t, err := ParseMonthYear(value)
+	if err != nil {
+		return err
+	}
+	*m = NewMonthYear(t)
+	return nil
+}
+
+// Scan implements sql.Scanner for DATE columns.
+func (m *MonthYear) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*m = NewMonthYear(v)
+		return nil
+	case string:
+		t, err := time.Parse(legacyDateLayout, v)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", v, err)
+		}
+		*m = NewMonthYear(t)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into MonthYear", src)
+	}
+}
+
+// Value implements driver.Valuer, storing the first day of the month.
+func (m MonthYear) Value() (driver.Value, error) {
+	return m.t, nil
+}
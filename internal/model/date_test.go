@@ -0,0 +1,106 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseMonthYear(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "MM-YYYY", value: "03-2024", want: "03-2024"},
+		{name: "YYYY-MM", value: "2024-03", want: "03-2024"},
+		{name: "legacy YYYY-MM-DD", value: "2024-03-17", want: "03-2024"},
+		{name: "December", value: "12-2024", want: "12-2024"},
+		{name: "slash separator", value: "03/2024", wantErr: true},
+		{name: "month name", value: "March 2024", wantErr: true},
+		{name: "month zero", value: "00-2024", wantErr: true},
+		{name: "month thirteen", value: "13-2024", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMonthYear(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMonthYear(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if s := FormatMonthYear(got); s != tt.want {
+				t.Errorf("ParseMonthYear(%q) formats as %q, want %q", tt.value, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthYearJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "MM-YYYY", body: `"03-2024"`, want: `"03-2024"`},
+		{name: "YYYY-MM is written as MM-YYYY", body: `"2024-03"`, want: `"03-2024"`},
+		{name: "slash separator", body: `"03/2024"`, wantErr: true},
+		{name: "month name", body: `"March 2024"`, wantErr: true},
+		{name: "month zero", body: `"00-2024"`, wantErr: true},
+		{name: "month thirteen", body: `"13-2024"`, wantErr: true},
+		{name: "not a string", body: `202403`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m MonthYear
+			err := json.Unmarshal([]byte(tt.body), &m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, want error %v", tt.body, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := json.Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthYearScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    string
+		wantErr bool
+	}{
+		{name: "DATE column", src: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), want: "03-2024"},
+		{name: "legacy text mid-month", src: "2024-03-17", want: "03-2024"},
+		{name: "legacy text first of month", src: "2024-03-01", want: "03-2024"},
+		{name: "MM-YYYY text is not a stored format", src: "03-2024", wantErr: true},
+		{name: "unsupported type", src: int64(202403), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m MonthYear
+			err := m.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, want error %v", tt.src, err, tt.wantErr)
+			}
+			if !tt.wantErr && m.String() != tt.want {
+				t.Errorf("Scan(%v) = %s, want %s", tt.src, m, tt.want)
+			}
+		})
+	}
+}
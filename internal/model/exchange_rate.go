@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+// currencyCode matches ISO 4217 codes.
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExchangeRate says that one unit of Base is worth Rate units of Quote as
+// of the day AsOf.
+type ExchangeRate struct {
+	Base  string `json:"base" example:"USD"`
+	Quote string `json:"quote" example:"EUR"`
+	Rate  string `json:"rate" example:"0.9231"`      // Decimal number
+	AsOf  string `json:"as_of" example:"2024-03-01"` // Format: YYYY-MM-DD
+}
+
+// Validate checks the currency codes, the rate, which must be a positive
+// decimal number, and the day.
+func (r *ExchangeRate) Validate() error {
+	if !IsCurrencyCode(r.Base) || !IsCurrencyCode(r.Quote) {
+		return ValidationError(fmt.Sprintf("invalid pair %s/%s: base and quote must be ISO 4217 codes", r.Base, r.Quote))
+	}
+	if r.Base == r.Quote {
+		return ValidationError(fmt.Sprintf("invalid pair %s/%s: base and quote must differ", r.Base, r.Quote))
+	}
+	if rate, ok := new(big.Rat).SetString(r.Rate); !ok || rate.Sign() <= 0 {
+		return ValidationError(fmt.Sprintf("invalid rate %q for %s/%s: must be a positive decimal number", r.Rate, r.Base, r.Quote))
+	}
+	if _, err := time.Parse(time.DateOnly, r.AsOf); err != nil {
+		return ValidationError(fmt.Sprintf("invalid as_of %q for %s/%s: expected YYYY-MM-DD", r.AsOf, r.Base, r.Quote))
+	}
+	return nil
+}
+
+// UpsertExchangeRatesRequest stores exchange rates, replacing the rates of
+// the same pairs and days.
+type UpsertExchangeRatesRequest struct {
+	Rates []ExchangeRate `json:"rates" binding:"required,min=1,max=1000,dive"`
+}
+
+// ConvertedTotal is a total cost converted into one currency, along with
+// the rates used for every currency it was converted from.
+type ConvertedTotal struct {
+	Currency string         `json:"currency" example:"EUR"`
+	Total    int64          `json:"total"`
+	Rates    []ExchangeRate `json:"rates"`
+}
+
+// MissingRatesError reports currency pairs a conversion needs but no
+// exchange rate is known for.
+type MissingRatesError struct {
+	Pairs []string
+}
+
+func (e *MissingRatesError) Error() string {
+	return fmt.Sprintf("no exchange rate for %d currency pair(s)", len(e.Pairs))
+}
+
+// IsCurrencyCode reports whether code looks like an ISO 4217 code.
+func IsCurrencyCode(code string) bool {
+	return currencyCode.MatchString(code)
+}
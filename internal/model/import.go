@@ -0,0 +1,16 @@
+package model
+
+// ImportRowError describes why a single CSV row was rejected.
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResponse reports the outcome of a CSV import.
+// @Description CSV import result
+type ImportResponse struct {
+	DryRun   bool             `json:"dry_run"`
+	Valid    int              `json:"valid"`
+	Inserted int              `json:"inserted"`
+	Errors   []ImportRowError `json:"errors"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionMember is a user sharing a subscription with its owner. The
+// member pays SharePercent of the price; the owner pays the rest.
+type SubscriptionMember struct {
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	SharePercent   int       `json:"share_percent" example:"25"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AddMemberRequest adds a user to a subscription, or changes the share of
+// a user who is a member already.
+type AddMemberRequest struct {
+	UserID       uuid.UUID `json:"user_id" binding:"required"`
+	SharePercent int       `json:"share_percent" binding:"required,gte=1,lte=100" example:"25"`
+}
+
+// CostShare tells which part of a subscription's cost falls to one user.
+// Members pay SharePercent of the cost, rounded down; the owner pays what
+// is left after every member's share, so the parts always add up to the
+// full cost.
+type CostShare struct {
+	Owned        bool
+	SharePercent int   // The user's share, when they are a member
+	MemberShares []int // The shares of every member
+}
+
+// Part returns the part of cost the user pays.
+func (s CostShare) Part(cost int64) int64 {
+	if !s.Owned {
+		return percentOf(cost, s.SharePercent)
+	}
+	part := cost
+	for _, p := range s.MemberShares {
+		part -= percentOf(cost, p)
+	}
+	return part
+}
+
+// percentOf returns percent of the non-negative cost, rounded down, without
+// overflowing for costs close to the int64 limit.
+func percentOf(cost int64, percent int) int64 {
+	p := int64(percent)
+	return cost/100*p + cost%100*p/100
+}
+
+// SharedSubscription is a subscription a user owns or is a member of, along
+// with the part of its cost they pay.
+type SharedSubscription struct {
+	Subscription
+	Share CostShare
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostSharePart(t *testing.T) {
+	tests := []struct {
+		name        string
+		cost        int64
+		shares      []int
+		wantMembers []int64
+		wantOwner   int64
+	}{
+		{name: "thirds of 1000", cost: 1000, shares: []int{33, 33}, wantMembers: []int64{330, 330}, wantOwner: 340},
+		{name: "thirds of 1001", cost: 1001, shares: []int{33, 33}, wantMembers: []int64{330, 330}, wantOwner: 341},
+		{name: "thirds of 999", cost: 999, shares: []int{33, 33}, wantMembers: []int64{329, 329}, wantOwner: 341},
+		{name: "thirds of 1", cost: 1, shares: []int{33, 33}, wantMembers: []int64{0, 0}, wantOwner: 1},
+		{name: "uneven thirds", cost: 1099, shares: []int{34, 33}, wantMembers: []int64{373, 362}, wantOwner: 364},
+		{name: "members sharing everything", cost: 999, shares: []int{50, 50}, wantMembers: []int64{499, 499}, wantOwner: 1},
+		{name: "thirds close to the int64 limit", cost: math.MaxInt64, shares: []int{33, 33}, wantMembers: []int64{3043712772162076016, 3043712772162076016}, wantOwner: 3135946492530623775},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := CostShare{Owned: true, MemberShares: tt.shares}.Part(tt.cost)
+			if owner != tt.wantOwner {
+				t.Errorf("owner part = %d, want %d", owner, tt.wantOwner)
+			}
+
+			// The parts must add up to the cost exactly; subtracting them
+			// keeps the check clear of overflow near the int64 limit.
+			left := tt.cost - owner
+			for i, p := range tt.shares {
+				part := CostShare{SharePercent: p, MemberShares: tt.shares}.Part(tt.cost)
+				if part != tt.wantMembers[i] {
+					t.Errorf("member part at %d%% = %d, want %d", p, part, tt.wantMembers[i])
+				}
+				left -= part
+			}
+			if left != 0 {
+				t.Errorf("parts add up to %d off the cost", -left)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bounds and defaults of notification preferences. Users without stored
+// preferences are reminded DefaultRemindBeforeRenewalDays days before a
+// renewal, by neither email nor webhook.
+const (
+	MinRemindBeforeRenewalDays     = 1
+	MaxRemindBeforeRenewalDays     = 90
+	DefaultRemindBeforeRenewalDays = 7
+	MaxWebhookURLLength            = 2048
+)
+
+// NotificationPreferences tell the reminder worker whether and how to
+// notify a user of upcoming renewals. IsDefault is set when the user has
+// not stored preferences of their own; the timestamps are zero then.
+type NotificationPreferences struct {
+	UserID                  uuid.UUID `json:"user_id"`
+	RemindBeforeRenewalDays int       `json:"remind_before_renewal_days" example:"7"`
+	EmailEnabled            bool      `json:"email_enabled"`
+	WebhookURL              *string   `json:"webhook_url,omitempty" example:"https://example.com/hooks/renewals"`
+	IsDefault               bool      `json:"is_default"`
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who has
+// not stored any.
+func DefaultNotificationPreferences(userID uuid.UUID) *NotificationPreferences {
+	return &NotificationPreferences{
+		UserID:                  userID,
+		RemindBeforeRenewalDays: DefaultRemindBeforeRenewalDays,
+		IsDefault:               true,
+	}
+}
+
+// Validate checks the day range and the webhook URL, which must be an
+// absolute http or https URL.
+func (p *NotificationPreferences) Validate() error {
+	if p.RemindBeforeRenewalDays < MinRemindBeforeRenewalDays || p.RemindBeforeRenewalDays > MaxRemindBeforeRenewalDays {
+		return ValidationError(fmt.Sprintf("remind_before_renewal_days must be between %d and %d", MinRemindBeforeRenewalDays, MaxRemindBeforeRenewalDays))
+	}
+	if p.WebhookURL == nil {
+		return nil
+	}
+	if len(*p.WebhookURL) > MaxWebhookURLLength {
+		return ValidationError(fmt.Sprintf("webhook_url must not exceed %d characters", MaxWebhookURLLength))
+	}
+	u, err := url.Parse(*p.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationError("webhook_url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// SetNotificationPreferencesRequest replaces the notification preferences
+// of a user. Fields left out take their default.
+type SetNotificationPreferencesRequest struct {
+	RemindBeforeRenewalDays *int    `json:"remind_before_renewal_days,omitempty" example:"7"` // Between 1 and 90, defaults to 7
+	EmailEnabled            *bool   `json:"email_enabled,omitempty"`                          // Defaults to false
+	WebhookURL              *string `json:"webhook_url,omitempty" example:"https://example.com/hooks/renewals"`
+}
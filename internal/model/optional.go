@@ -0,0 +1,31 @@
+package model
+
+import "encoding/json"
+
+// Optional is a JSON field that tells an absent value apart from an
+// explicit null. Present is false when the field was omitted; Value is nil
+// when it was null.
+type Optional[T any] struct {
+	Present bool
+	Value   *T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Present = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
+
+// IsNull reports whether the field was set to an explicit null.
+func (o Optional[T]) IsNull() bool {
+	return o.Present && o.Value == nil
+}
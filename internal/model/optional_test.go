@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptionalUnmarshal(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantPresent bool
+		wantNull    bool
+		wantValue   *int
+		wantErr     bool
+	}{
+		{name: "absent", body: `{}`},
+		{name: "null", body: `{"field":null}`, wantPresent: true, wantNull: true},
+		{name: "value", body: `{"field":42}`, wantPresent: true, wantValue: ptrTo(42)},
+		{name: "zero value", body: `{"field":0}`, wantPresent: true, wantValue: ptrTo(0)},
+		{name: "wrong type", body: `{"field":"42"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc struct {
+				Field Optional[int] `json:"field"`
+			}
+			err := json.Unmarshal([]byte(tt.body), &doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if doc.Field.Present != tt.wantPresent || doc.Field.IsNull() != tt.wantNull {
+				t.Errorf("Present = %v, IsNull() = %v, want %v, %v", doc.Field.Present, doc.Field.IsNull(), tt.wantPresent, tt.wantNull)
+			}
+			switch {
+			case tt.wantValue == nil && doc.Field.Value != nil:
+				t.Errorf("Value = %d, want nil", *doc.Field.Value)
+			case tt.wantValue != nil && (doc.Field.Value == nil || *doc.Field.Value != *tt.wantValue):
+				t.Errorf("Value = %v, want %d", doc.Field.Value, *tt.wantValue)
+			}
+		})
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
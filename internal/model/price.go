@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minorUnitDigits is the number of fractional digits of a price in major
+// units. Every supported currency has 2 (kopecks, cents).
+const minorUnitDigits = 2
+
+// ParsePriceDecimal converts a non-negative decimal amount in major units,
+// such as "9.99", into minor units. Digits beyond the minor unit are
+// rounded half-up: "0.125" becomes 13 and "0.124" becomes 12.
+func ParsePriceDecimal(value string) (int, error) {
+	invalid := ValidationError(fmt.Sprintf("invalid decimal price %q: expected a non-negative amount such as 9.99", value))
+
+	whole, frac, _ := strings.Cut(strings.TrimSpace(value), ".")
+	if (whole == "" && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, invalid
+	}
+
+	roundUp := false
+	if len(frac) > minorUnitDigits {
+		roundUp = frac[minorUnitDigits] >= '5'
+		frac = frac[:minorUnitDigits]
+	}
+	frac += strings.Repeat("0", minorUnitDigits-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 0)
+	if err != nil || (roundUp && minor == math.MaxInt) {
+		return 0, ValidationError(fmt.Sprintf("decimal price %q is too large", value))
+	}
+	if roundUp {
+		minor++
+	}
+	return int(minor), nil
+}
+
+// FormatPriceDecimal renders a price in minor units as a decimal amount in
+// major units, e.g. 999 as "9.99".
+func FormatPriceDecimal(minor int) string {
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
+
+// DiscountedPrice returns price in minor units with percent taken off,
+// rounded half-up to a whole minor unit: 999 at 50% becomes 500.
+func DiscountedPrice(price, percent int) int {
+	keep := 100 - percent
+	return price/100*keep + (price%100*keep+50)/100
+}
+
+// ResolvePrice returns the price in minor units given either as minor units
+// or as a decimal amount in major units. It returns nil when neither is set
+// and rejects requests setting both.
+func ResolvePrice(minor *int, decimal *string) (*int, error) {
+	switch {
+	case minor != nil && decimal != nil:
+		return nil, ValidationError("set the price either in minor units or as a decimal, not both")
+	case decimal != nil:
+		price, err := ParsePriceDecimal(*decimal)
+		if err != nil {
+			return nil, err
+		}
+		return &price, nil
+	default:
+		return minor, nil
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Every price, cost and total in this file is in minor units, like
+// Subscription.PriceMinor.
+
+// ServiceStats holds aggregates for the subscriptions of a single service.
+type ServiceStats struct {
+	ServiceName string     `json:"service_name"`
+	ServiceID   *uuid.UUID `json:"service_id,omitempty"` // Catalog entry, only when grouped by catalog
+	Plan        *string    `json:"plan,omitempty"`       // Only when grouped by plan
+	Count       int        `json:"count"`
+	TotalPrice  int64      `json:"total_price"`
+	AvgPrice    float64    `json:"avg_price"`
+}
+
+// StatsGrouping selects how subscription stats are broken down. By default
+// they are grouped by service name only.
+type StatsGrouping struct {
+	ByCatalog bool // group linked subscriptions by catalog entry
+	ByPlan    bool // break every service down further by plan
+}
+
+// StatsResponse holds per-service aggregates along with overall totals,
+// all of them over the subscriptions priced in Currency.
+// @Description Subscription statistics
+type StatsResponse struct {
+	Currency   string         `json:"currency" example:"RUB"`
+	Services   []ServiceStats `json:"services"`
+	Count      int            `json:"count"`
+	TotalPrice int64          `json:"total_price"`
+	AvgPrice   float64        `json:"avg_price"`
+}
+
+// MonthlySpend is the total spend for one calendar month.
+type MonthlySpend struct {
+	Month string `json:"month"` // Format: MM-YYYY
+	Total int64  `json:"total"`
+}
+
+// ServiceSpend is the total spend on one service within a period.
+type ServiceSpend struct {
+	ServiceName string  `json:"service_name"`
+	Plan        *string `json:"plan,omitempty"` // Only when grouped by plan
+	Total       int64   `json:"total"`
+}
+
+// SummaryResponse describes what a user is paying for in the current month
+// in Currency.
+// @Description User subscription summary
+type SummaryResponse struct {
+	Currency          string        `json:"currency" example:"RUB"`
+	ActiveCount       int           `json:"active_count"`
+	MonthlyCost       int64         `json:"monthly_cost"`
+	MostExpensive     *Subscription `json:"most_expensive,omitempty"`
+	EarliestStartDate *MonthYear    `json:"earliest_start_date,omitempty" swaggertype:"string"`
+	// Present when the user has a budget
+	*BudgetUsage
+}
+
+// UserCost is the total cost of one user's subscriptions within a period.
+type UserCost struct {
+	UserID    uuid.UUID `json:"user_id"`
+	TotalCost int64     `json:"total_cost"`
+}
+
+// MonthlyCost is the cost of subscriptions billed in one month.
+type MonthlyCost struct {
+	Month string `json:"month"` // Format: MM-YYYY
+	Cost  int64  `json:"cost"`
+}
+
+// ServiceCost is the cost of one service's subscriptions.
+type ServiceCost struct {
+	ServiceName string `json:"service_name"`
+	Cost        int64  `json:"cost"`
+}
+
+// CostCell is what the subscriptions to one service priced in one currency
+// are charged in one month, the unit cost breakdowns are built from.
+type CostCell struct {
+	Month       time.Time
+	Currency    string
+	ServiceName string
+	Cost        int64
+}
+
+// TotalCostResponse is returned by the total cost endpoint. Prices in
+// different currencies are never added up: TotalCost and Currency are only
+// present when a single currency is involved, while Totals lists the cost
+// per currency whenever no currency was requested. The breakdowns are only
+// present when they were requested.
+// @Description Total cost of subscriptions
+type TotalCostResponse struct {
+	Scope                string           `json:"scope" enums:"user,all"`
+	TotalCost            *int64           `json:"total_cost,omitempty"`
+	Currency             string           `json:"currency,omitempty" example:"RUB"`
+	Totals               map[string]int64 `json:"totals,omitempty"`
+	SubscriptionsCounted int              `json:"subscriptions_counted"`
+	StartDate            *string          `json:"start_date,omitempty"` // Format: MM-YYYY, absent when unbounded
+	EndDate              *string          `json:"end_date,omitempty"`   // Format: MM-YYYY, absent when unbounded
+	Months               []MonthlyCost    `json:"months,omitempty"`
+	Services             []ServiceCost    `json:"services,omitempty"`
+	Converted            *ConvertedTotal  `json:"converted,omitempty"` // Present with convert_to
+}
+
+// Scopes a total cost can be computed for.
+const (
+	TotalCostScopeUser = "user"
+	TotalCostScopeAll  = "all"
+)
+
+// SetTotals reports the cost per currency. With a requested currency, or
+// when every cost is in the same currency, the total is set as well.
+func (r *TotalCostResponse) SetTotals(currency string, totals map[string]int64) {
+	r.TotalCost, r.Currency, r.Totals = nil, "", nil
+	if currency != "" {
+		total := totals[currency]
+		r.TotalCost, r.Currency = &total, currency
+		return
+	}
+
+	r.Totals = totals
+	if len(totals) > 1 {
+		return
+	}
+	var total int64
+	for code, cost := range totals {
+		r.Currency, total = code, cost
+	}
+	r.TotalCost = &total
+}
+
+// CurrencyTotals returns the total per currency, whether the response
+// holds one currency or several.
+func (r *TotalCostResponse) CurrencyTotals() map[string]int64 {
+	if r.Totals != nil {
+		return r.Totals
+	}
+	if r.TotalCost != nil && r.Currency != "" {
+		return map[string]int64{r.Currency: *r.TotalCost}
+	}
+	return map[string]int64{}
+}
+
+// SetPeriod echoes the normalized period bounds the total was computed for.
+func (r *TotalCostResponse) SetPeriod(from, to *time.Time) {
+	r.StartDate, r.EndDate = nil, nil
+	if from != nil {
+		startDate := FormatMonthYear(*from)
+		r.StartDate = &startDate
+	}
+	if to != nil {
+		endDate := FormatMonthYear(*to)
+		r.EndDate = &endDate
+	}
+}
+
+// PeriodCost is the total cost within one period.
+type PeriodCost struct {
+	StartDate string `json:"start_date"` // Format: MM-YYYY
+	EndDate   string `json:"end_date"`   // Format: MM-YYYY
+	TotalCost int64  `json:"total_cost"`
+}
+
+// CostComparisonResponse compares the cost in Currency of a period with the
+// preceding period of equal length. DeltaPercent is null when the previous
+// total is 0.
+// @Description Cost comparison between two periods
+type CostComparisonResponse struct {
+	Currency     string     `json:"currency" example:"RUB"`
+	Current      PeriodCost `json:"current"`
+	Previous     PeriodCost `json:"previous"`
+	Delta        int64      `json:"delta"`
+	DeltaPercent *float64   `json:"delta_percent"`
+}
+
+// Bases for averaging the monthly cost.
+const (
+	AverageBasisWindow       = "window"        // every month of the requested window
+	AverageBasisActiveMonths = "active_months" // only months with any spend
+)
+
+// AverageCostResponse is the mean monthly cost in Currency within a period.
+// @Description Average monthly cost
+type AverageCostResponse struct {
+	Currency           string  `json:"currency" example:"RUB"`
+	AverageMonthlyCost float64 `json:"average_monthly_cost"`
+	TotalCost          int64   `json:"total_cost"`
+	Months             int     `json:"months"`
+	Basis              string  `json:"basis"`
+	StartDate          string  `json:"start_date"` // Format: MM-YYYY
+	EndDate            string  `json:"end_date"`   // Format: MM-YYYY
+}
+
+// ForecastResponse projects the cost of the currently active subscriptions
+// priced in Currency over the coming months.
+// @Description Spending forecast
+type ForecastResponse struct {
+	Currency  string        `json:"currency" example:"RUB"`
+	Months    []MonthlyCost `json:"months"`
+	TotalCost int64         `json:"total_cost"`
+}
+
+// DuplicateGroup is a set of subscriptions of one user to the same service
+// whose billed periods overlap, likely created twice by accident.
+type DuplicateGroup struct {
+	UserID uuid.UUID `json:"user_id"`
+	// ServiceName is the service name in lower case, as it is compared.
+	ServiceName   string         `json:"service_name"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
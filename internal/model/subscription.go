@@ -1,29 +1,471 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Subscription statuses. A cancelled subscription is billed up to and
+// including the month it was cancelled in. The allowed transitions between
+// them are enforced by the service layer.
+const (
+	StatusActive    = "active"
+	StatusTrialing  = "trialing"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+)
+
+// Billing periods of a subscription.
+const (
+	BillingMonthly = "monthly"
+	BillingYearly  = "yearly"
+	BillingWeekly  = "weekly"
+)
+
+// IsValidBillingPeriod reports whether period is a supported billing
+// period.
+func IsValidBillingPeriod(period string) bool {
+	return period == BillingMonthly || period == BillingYearly || period == BillingWeekly
+}
+
+// Statuses lists every subscription status.
+var Statuses = []string{StatusActive, StatusTrialing, StatusPaused, StatusCancelled, StatusExpired}
+
+// MaxMetadataSize caps the size of the JSON-encoded metadata of a
+// subscription in bytes.
+const MaxMetadataSize = 4096
+
+// MaxPlanLength caps the number of characters in the plan of a
+// subscription.
+const MaxPlanLength = 100
+
+// MaxNotesLength caps the number of characters in the notes of a
+// subscription.
+const MaxNotesLength = 2000
+
+// IsValidStatus reports whether status is one of Statuses.
+func IsValidStatus(status string) bool {
+	for _, s := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
 
 // Subscription represents a user's subscription to a service.
+// A subscription is billed for every month from StartDate through EndDate,
+// both inclusive; without an EndDate it stays active indefinitely unless
+// it is cancelled. PriceMinor is the list price charged every
+// BillingPeriod in minor units of Currency; PriceDecimal and EffectivePrice
+// are derived from it when rendering JSON. Up to and including
+// DiscountUntil, DiscountPercent is taken off the list price.
 // @Description Subscription information
 type Subscription struct {
-	ID           uuid.UUID `json:"id,omitempty"`
-	ServiceName  string    `json:"service_name" binding:"required"`
-	Price        int       `json:"price" binding:"required,gte=0"`
-	UserID       uuid.UUID `json:"user_id" binding:"required"`
-	StartDate    string    `json:"start_date" binding:"required"` // Format: MM-YYYY
-	EndDate      *string   `json:"end_date,omitempty"`           // Format: MM-YYYY
+	ID              uuid.UUID         `json:"id,omitempty"`
+	ServiceName     string            `json:"service_name" binding:"required"`
+	ServiceID       *uuid.UUID        `json:"service_id,omitempty"`            // Catalog entry ServiceName was resolved to
+	Plan            *string           `json:"plan,omitempty" example:"Family"` // Plan or tier of the service
+	PriceMinor      int               `json:"price_minor" binding:"required,gte=0" example:"999"`
+	PriceDecimal    string            `json:"price_decimal" example:"9.99"`
+	Currency        string            `json:"currency" example:"RUB"`                       // ISO 4217 code
+	BillingPeriod   string            `json:"billing_period" enums:"monthly,yearly,weekly"` // How often PriceMinor is charged
+	UserID          uuid.UUID         `json:"user_id" binding:"required"`
+	StartDate       MonthYear         `json:"start_date" binding:"required" swaggertype:"string" example:"03-2024"` // Format: MM-YYYY
+	EndDate         *MonthYear        `json:"end_date,omitempty" swaggertype:"string" example:"12-2024"`            // Format: MM-YYYY
+	AutoRenew       bool              `json:"auto_renew"`                                                           // Renews past EndDate unless cancelled; otherwise expires after it
+	TrialEndDate    *MonthYear        `json:"trial_end_date,omitempty" swaggertype:"string" example:"04-2024"`      // Format: MM-YYYY, last free month
+	DiscountPercent *int              `json:"discount_percent,omitempty" example:"50"`
+	DiscountUntil   *MonthYear        `json:"discount_until,omitempty" swaggertype:"string" example:"06-2024"` // Format: MM-YYYY, last discounted month
+	EffectivePrice  int               `json:"effective_price" example:"499"`                                   // Price in minor units charged for the current month, after the discount
+	NextBillingDate *string           `json:"next_billing_date,omitempty" example:"2024-04-01"`                // Format: YYYY-MM-DD, read-only; absent once no charge is left
+	Metadata        map[string]string `json:"metadata,omitempty"`                                              // Free-form string values set by integrators
+	Notes           *string           `json:"notes,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"` // Only set on soft-deleted subscriptions
+	Version         int               `json:"version"`              // Incremented on every update
+	Status          string            `json:"status" enums:"active,trialing,paused,cancelled,expired"`
+	CancelledAt     *time.Time        `json:"cancelled_at,omitempty"`
+	AnonymizedAt    *time.Time        `json:"anonymized_at,omitempty"` // Set once the owner was replaced by a synthetic user
+}
+
+// MarshalJSON renders s with PriceDecimal and EffectivePrice derived from
+// PriceMinor, so the representations of the price always agree.
+func (s Subscription) MarshalJSON() ([]byte, error) {
+	type subscription Subscription
+	out := subscription(s)
+	out.PriceDecimal = FormatPriceDecimal(s.PriceMinor)
+	out.EffectivePrice = s.PriceIn(NewMonthYear(time.Now().UTC()))
+	return json.Marshal(out)
+}
+
+// PriceIn returns the price s charges per BillingPeriod in month: the
+// discounted price up to and including DiscountUntil, the list price
+// afterwards.
+func (s Subscription) PriceIn(month MonthYear) int {
+	if s.DiscountPercent == nil || s.DiscountUntil == nil || s.DiscountUntil.Before(month) {
+		return s.PriceMinor
+	}
+	return DiscountedPrice(s.PriceMinor, *s.DiscountPercent)
+}
+
+// BilledThrough returns the last month s is billed for: its end date, or
+// the month it was cancelled in when that comes first. Nil means s is
+// open-ended.
+func (s Subscription) BilledThrough() *MonthYear {
+	if s.CancelledAt == nil {
+		return s.EndDate
+	}
+	cancelled := NewMonthYear(s.CancelledAt.UTC())
+	if s.EndDate != nil && s.EndDate.Before(cancelled) {
+		return s.EndDate
+	}
+	return &cancelled
+}
+
+// SubscriptionPatch holds the fields of a partial update. Nil fields are
+// left untouched; ClearEndDate makes the subscription open-ended,
+// ClearTrialEndDate removes its trial, ClearDiscount its discount and
+// ClearPlan its plan.
+type SubscriptionPatch struct {
+	ServiceName       *string
+	PriceMinor        *int
+	Currency          *string
+	BillingPeriod     *string
+	StartDate         *MonthYear
+	EndDate           *MonthYear
+	ClearEndDate      bool
+	AutoRenew         *bool
+	TrialEndDate      *MonthYear
+	ClearTrialEndDate bool
+	DiscountPercent   *int
+	DiscountUntil     *MonthYear
+	ClearDiscount     bool
+	Plan              *string
+	ClearPlan         bool
+	// Metadata sets the keys it carries and removes those mapped to nil.
+	// ClearMetadata removes every key first.
+	Metadata      map[string]*string
+	ClearMetadata bool
+	Notes         *string
+	ClearNotes    bool
+	Status        *string
+	// Version, when not zero, is the version the subscription must still
+	// have for the patch to apply.
+	Version int
+}
+
+// IsEmpty reports whether p changes nothing.
+func (p SubscriptionPatch) IsEmpty() bool {
+	return p.ServiceName == nil && p.Plan == nil && !p.ClearPlan && p.PriceMinor == nil && p.Currency == nil && p.BillingPeriod == nil && p.StartDate == nil && p.EndDate == nil && !p.ClearEndDate && p.AutoRenew == nil && p.TrialEndDate == nil && !p.ClearTrialEndDate && p.DiscountPercent == nil && p.DiscountUntil == nil && !p.ClearDiscount && p.Metadata == nil && !p.ClearMetadata && p.Notes == nil && !p.ClearNotes && p.Status == nil
+}
+
+// Apply copies the fields set in p onto s.
+func (p SubscriptionPatch) Apply(s *Subscription) {
+	if p.ServiceName != nil {
+		s.ServiceName = *p.ServiceName
+	}
+	if p.Plan != nil {
+		s.Plan = p.Plan
+	}
+	if p.ClearPlan {
+		s.Plan = nil
+	}
+	if p.PriceMinor != nil {
+		s.PriceMinor = *p.PriceMinor
+	}
+	if p.Currency != nil {
+		s.Currency = *p.Currency
+	}
+	if p.BillingPeriod != nil {
+		s.BillingPeriod = *p.BillingPeriod
+	}
+	if p.StartDate != nil {
+		s.StartDate = *p.StartDate
+	}
+	if p.EndDate != nil {
+		s.EndDate = p.EndDate
+	}
+	if p.ClearEndDate {
+		s.EndDate = nil
+	}
+	if p.AutoRenew != nil {
+		s.AutoRenew = *p.AutoRenew
+	}
+	if p.TrialEndDate != nil {
+		s.TrialEndDate = p.TrialEndDate
+	}
+	if p.ClearTrialEndDate {
+		s.TrialEndDate = nil
+	}
+	if p.ClearDiscount {
+		s.DiscountPercent, s.DiscountUntil = nil, nil
+	}
+	if p.DiscountPercent != nil {
+		s.DiscountPercent = p.DiscountPercent
+	}
+	if p.DiscountUntil != nil {
+		s.DiscountUntil = p.DiscountUntil
+	}
+	if p.ClearMetadata {
+		s.Metadata = nil
+	}
+	for key, value := range p.Metadata {
+		if value == nil {
+			delete(s.Metadata, key)
+			continue
+		}
+		if s.Metadata == nil {
+			s.Metadata = make(map[string]string)
+		}
+		s.Metadata[key] = *value
+	}
+	if p.Notes != nil {
+		s.Notes = p.Notes
+	}
+	if p.ClearNotes {
+		s.Notes = nil
+	}
+	if p.Status != nil {
+		s.Status = *p.Status
+	}
+}
+
+// Validate applies the rules every stored subscription satisfies. It
+// returns a ValidationError describing the first violation.
+func (s *Subscription) Validate() error {
+	if s.ServiceName == "" {
+		return ValidationError("service_name must not be empty")
+	}
+	if s.Plan != nil && (*s.Plan == "" || utf8.RuneCountInString(*s.Plan) > MaxPlanLength) {
+		return ValidationError(fmt.Sprintf("plan must be between 1 and %d characters", MaxPlanLength))
+	}
+	if s.PriceMinor < 0 {
+		return ValidationError("price_minor must not be negative")
+	}
+	if s.BillingPeriod != "" && !IsValidBillingPeriod(s.BillingPeriod) {
+		return ValidationError("billing_period must be one of monthly, yearly, weekly")
+	}
+	if s.StartDate.IsZero() {
+		return ValidationError("start_date is required")
+	}
+	if s.EndDate != nil && s.EndDate.Before(s.StartDate) {
+		return ValidationError("end_date must not be before start_date")
+	}
+	if s.TrialEndDate != nil && s.TrialEndDate.Before(s.StartDate) {
+		return ValidationError("trial_end_date must not be before start_date")
+	}
+	if s.TrialEndDate != nil && s.EndDate != nil && s.EndDate.Before(*s.TrialEndDate) {
+		return ValidationError("trial_end_date must not be after end_date")
+	}
+	if (s.DiscountPercent == nil) != (s.DiscountUntil == nil) {
+		return ValidationError("discount_percent and discount_until must be set together")
+	}
+	if s.DiscountPercent != nil && (*s.DiscountPercent < 0 || *s.DiscountPercent > 100) {
+		return ValidationError("discount_percent must be between 0 and 100")
+	}
+	if s.DiscountUntil != nil && s.DiscountUntil.Before(s.StartDate) {
+		return ValidationError("discount_until must not be before start_date")
+	}
+	if s.DiscountUntil != nil && s.EndDate != nil && s.EndDate.Before(*s.DiscountUntil) {
+		return ValidationError("discount_until must not be after end_date")
+	}
+	if s.Notes != nil && utf8.RuneCountInString(*s.Notes) > MaxNotesLength {
+		return ValidationError(fmt.Sprintf("notes must not exceed %d characters", MaxNotesLength))
+	}
+	return ValidateMetadata(s.Metadata)
+}
+
+// ValidateMetadata rejects empty keys and metadata whose JSON encoding
+// exceeds MaxMetadataSize.
+func ValidateMetadata(metadata map[string]string) error {
+	for key := range metadata {
+		if key == "" {
+			return ValidationError("metadata keys must not be empty")
+		}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxMetadataSize {
+		return ValidationError(fmt.Sprintf("metadata must not exceed %d bytes", MaxMetadataSize))
+	}
+	return nil
+}
+
+// SubscriptionFilter narrows down the subscriptions returned by List.
+// Zero-value fields are not applied.
+type SubscriptionFilter struct {
+	UserIDs []uuid.UUID
+	// ServiceName is matched case-insensitively.
+	ServiceName string
+	// IncludeDeleted also returns soft-deleted subscriptions.
+	IncludeDeleted bool
+	Status         string
+	// Plan is matched case-insensitively.
+	Plan string
+	// Metadata matches subscriptions whose metadata contains every given
+	// key with the given value.
+	Metadata map[string]string
+}
+
+// DeleteFilter selects the subscriptions removed by a bulk delete. UserID
+// is mandatory; StartFrom and StartTo bound start_date, both inclusive.
+type DeleteFilter struct {
+	UserID uuid.UUID
+	// ServiceName is matched case-insensitively.
+	ServiceName string
+	StartFrom   *MonthYear
+	StartTo     *MonthYear
 }
 
 type CreateSubscriptionRequest struct {
-	ServiceName string    `json:"service_name" binding:"required"`
-	Price       int       `json:"price" binding:"required,gte=0"`
-	UserID      uuid.UUID `json:"user_id" binding:"required"`
-	StartDate   string    `json:"start_date" binding:"required"` // Format: MM-YYYY
-	EndDate     *string   `json:"end_date,omitempty"`           // Format: MM-YYYY
+	ServiceName     string            `json:"service_name" binding:"required"`
+	Plan            *string           `json:"plan,omitempty" example:"Family"`                               // At most 100 characters
+	PriceMinor      *int              `json:"price_minor,omitempty" binding:"omitempty,gte=0" example:"999"` // Either price_minor or price_decimal is required
+	PriceDecimal    *string           `json:"price_decimal,omitempty" example:"9.99"`                        // Major units, rounded half-up to minor units
+	Currency        string            `json:"currency,omitempty" example:"RUB"`                              // ISO 4217 code, defaults to the configured currency
+	BillingPeriod   string            `json:"billing_period,omitempty" enums:"monthly,yearly,weekly"`        // Defaults to monthly
+	UserID          uuid.UUID         `json:"user_id" binding:"required"`
+	StartDate       *MonthYear        `json:"start_date" binding:"required" swaggertype:"string" example:"03-2024"`      // Format: MM-YYYY
+	EndDate         *MonthYear        `json:"end_date,omitempty" swaggertype:"string" example:"12-2024"`                 // Format: MM-YYYY
+	AutoRenew       *bool             `json:"auto_renew,omitempty"`                                                      // Defaults to true
+	TrialEndDate    *MonthYear        `json:"trial_end_date,omitempty" swaggertype:"string" example:"04-2024"`           // Format: MM-YYYY, last free month
+	Trial           bool              `json:"trial,omitempty"`                                                           // Start out trialing instead of active
+	DiscountPercent *int              `json:"discount_percent,omitempty" binding:"omitempty,gte=0,lte=100" example:"50"` // Requires discount_until
+	DiscountUntil   *MonthYear        `json:"discount_until,omitempty" swaggertype:"string" example:"06-2024"`           // Format: MM-YYYY, last discounted month
+	Metadata        map[string]string `json:"metadata,omitempty"`                                                        // String values only, at most 4096 bytes as JSON
+	Notes           *string           `json:"notes,omitempty" example:"shared with roommates, they pay half"`            // At most 2000 characters
+}
+
+// AutoRenewOrDefault resolves the optional auto_renew field of a request.
+// Subscriptions renew automatically unless told otherwise.
+func AutoRenewOrDefault(autoRenew *bool) bool {
+	return autoRenew == nil || *autoRenew
+}
+
+// InitialStatus returns the status a subscription created from r starts
+// out with.
+func (r CreateSubscriptionRequest) InitialStatus() string {
+	if r.Trial || r.TrialEndDate != nil {
+		return StatusTrialing
+	}
+	return StatusActive
 }
 
+// ReplaceSubscriptionRequest replaces every mutable field of a
+// subscription; an omitted end_date makes it open-ended, an omitted
+// auto_renew turns automatic renewal back on and an omitted plan,
+// trial_end_date, discount, metadata or notes removes it, while an omitted
+// currency or billing_period is kept. Version is an alternative to the
+// If-Match header.
+type ReplaceSubscriptionRequest struct {
+	ServiceName     string            `json:"service_name" binding:"required"`
+	Plan            *string           `json:"plan,omitempty" example:"Family"`                               // At most 100 characters
+	PriceMinor      *int              `json:"price_minor,omitempty" binding:"omitempty,gte=0" example:"999"` // Either price_minor or price_decimal is required
+	PriceDecimal    *string           `json:"price_decimal,omitempty" example:"9.99"`                        // Major units, rounded half-up to minor units
+	Currency        string            `json:"currency,omitempty" example:"RUB"`                              // ISO 4217 code
+	BillingPeriod   string            `json:"billing_period,omitempty" enums:"monthly,yearly,weekly"`
+	StartDate       *MonthYear        `json:"start_date" binding:"required" swaggertype:"string" example:"03-2024"`      // Format: MM-YYYY
+	EndDate         *MonthYear        `json:"end_date,omitempty" swaggertype:"string" example:"12-2024"`                 // Format: MM-YYYY
+	AutoRenew       *bool             `json:"auto_renew,omitempty"`                                                      // Defaults to true
+	TrialEndDate    *MonthYear        `json:"trial_end_date,omitempty" swaggertype:"string" example:"04-2024"`           // Format: MM-YYYY, last free month
+	DiscountPercent *int              `json:"discount_percent,omitempty" binding:"omitempty,gte=0,lte=100" example:"50"` // Requires discount_until
+	DiscountUntil   *MonthYear        `json:"discount_until,omitempty" swaggertype:"string" example:"06-2024"`           // Format: MM-YYYY, last discounted month
+	Metadata        map[string]string `json:"metadata,omitempty"`                                                        // String values only, at most 4096 bytes as JSON
+	Notes           *string           `json:"notes,omitempty" example:"shared with roommates, they pay half"`            // At most 2000 characters
+	Version         *int              `json:"version,omitempty" binding:"omitempty,gte=1"`
+}
+
+// UpdateSubscriptionRequest changes only the fields it carries. An
+// explicit "end_date": null makes the subscription open-ended,
+// "trial_end_date": null removes its trial and "plan": null its plan. A
+// null discount_percent or discount_until removes the discount. Metadata
+// replaces all stored keys and null removes them. "notes": null removes
+// the notes, which are kept when omitted. Version is an alternative to the
+// If-Match header.
 type UpdateSubscriptionRequest struct {
-	ServiceName *string `json:"service_name,omitempty"`
-	Price       *int    `json:"price,omitempty,gte=0"`
-	StartDate   *string `json:"start_date,omitempty"` // Format: MM-YYYY
-	EndDate     *string `json:"end_date,omitempty"`   // Format: MM-YYYY
-}
\ No newline at end of file
+	ServiceName     *string                     `json:"service_name,omitempty"`
+	Plan            Optional[string]            `json:"plan" swaggertype:"string" extensions:"x-nullable" example:"Family"` // At most 100 characters
+	PriceMinor      *int                        `json:"price_minor,omitempty" binding:"omitempty,gte=0" example:"999"`
+	PriceDecimal    *string                     `json:"price_decimal,omitempty" example:"9.99"` // Major units, rounded half-up to minor units
+	Currency        *string                     `json:"currency,omitempty" example:"RUB"`       // ISO 4217 code
+	BillingPeriod   *string                     `json:"billing_period,omitempty" enums:"monthly,yearly,weekly"`
+	StartDate       *MonthYear                  `json:"start_date,omitempty" swaggertype:"string" example:"03-2024"`             // Format: MM-YYYY
+	EndDate         Optional[MonthYear]         `json:"end_date" swaggertype:"string" extensions:"x-nullable" example:"12-2024"` // Format: MM-YYYY
+	AutoRenew       *bool                       `json:"auto_renew,omitempty"`
+	TrialEndDate    Optional[MonthYear]         `json:"trial_end_date" swaggertype:"string" extensions:"x-nullable" example:"04-2024"` // Format: MM-YYYY
+	DiscountPercent Optional[int]               `json:"discount_percent" swaggertype:"integer" extensions:"x-nullable" example:"50"`
+	DiscountUntil   Optional[MonthYear]         `json:"discount_until" swaggertype:"string" extensions:"x-nullable" example:"06-2024"` // Format: MM-YYYY
+	Metadata        Optional[map[string]string] `json:"metadata" swaggertype:"object,string" extensions:"x-nullable"`
+	Notes           Optional[string]            `json:"notes" swaggertype:"string" extensions:"x-nullable" example:"shared with roommates, they pay half"` // At most 2000 characters
+	Status          *string                     `json:"status,omitempty" enums:"active,trialing,paused,expired"`
+	Version         *int                        `json:"version,omitempty" binding:"omitempty,gte=1"`
+}
+
+// DeleteSubscriptionsRequest selects the subscriptions of a user to delete.
+type DeleteSubscriptionsRequest struct {
+	UserID      uuid.UUID  `json:"user_id" binding:"required"`
+	ServiceName string     `json:"service_name,omitempty"`
+	StartFrom   *MonthYear `json:"start_from,omitempty" swaggertype:"string" example:"01-2024"` // Format: MM-YYYY
+	StartTo     *MonthYear `json:"start_to,omitempty" swaggertype:"string" example:"12-2024"`   // Format: MM-YYYY
+}
+
+// DeleteSubscriptionsResponse reports the outcome of a bulk delete. A dry
+// run deletes nothing and lists a sample of the matching IDs.
+type DeleteSubscriptionsResponse struct {
+	DryRun    bool        `json:"dry_run"`
+	Matched   int64       `json:"matched"`
+	Deleted   int64       `json:"deleted"`
+	SampleIDs []uuid.UUID `json:"sample_ids,omitempty"`
+}
+
+// RepriceRequest sets a new price on the subscriptions to a service priced
+// in Currency, optionally only those of one user. EffectiveOnlyActive
+// defaults to true, leaving subscriptions that have already ended
+// untouched.
+type RepriceRequest struct {
+	ServiceName         string     `json:"service_name" binding:"required"`
+	Currency            string     `json:"currency" binding:"required" example:"RUB"`                         // ISO 4217 code of the subscriptions to reprice
+	NewPriceMinor       *int       `json:"new_price_minor,omitempty" binding:"omitempty,gte=0" example:"999"` // Either new_price_minor or new_price_decimal is required
+	NewPriceDecimal     *string    `json:"new_price_decimal,omitempty" example:"9.99"`
+	UserID              *uuid.UUID `json:"user_id,omitempty"`
+	EffectiveOnlyActive *bool      `json:"effective_only_active,omitempty"`
+}
+
+type RepriceResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+// RenewRequest extends a subscription by Months months, 1 when omitted.
+type RenewRequest struct {
+	Months *int `json:"months,omitempty" binding:"omitempty,min=1,max=60"`
+}
+
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+type ArchiveResponse struct {
+	Archived int64 `json:"archived"`
+}
+
+// BatchGetRequest names the subscriptions fetched by a batch get, at most
+// 200 of them. Repeated IDs are looked up once.
+type BatchGetRequest struct {
+	IDs []uuid.UUID `json:"ids" binding:"required,min=1,max=200"`
+}
+
+// BatchGetResponse carries the live subscriptions found by a batch get and
+// the requested IDs matching none.
+type BatchGetResponse struct {
+	Subscriptions []Subscription `json:"subscriptions"`
+	NotFound      []uuid.UUID    `json:"not_found"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTenantID is the tenant data stored before the service became
+// multi-tenant belongs to.
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx scoped to the tenant id.
+func WithTenant(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant stored on ctx by WithTenant. ok is
+// false outside of a request, such as in background jobs.
+func TenantFromContext(ctx context.Context) (id uuid.UUID, ok bool) {
+	id, ok = ctx.Value(tenantKey{}).(uuid.UUID)
+	return id, ok
+}
+
+type allTenantsKey struct{}
+
+// WithAllTenants returns a copy of ctx that works across the data of every
+// tenant, for background jobs. Without it or a tenant set by WithTenant,
+// queries fail rather than touch every tenant's rows. A tenant set with
+// WithTenant takes precedence.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey{}, true)
+}
+
+// AllTenants reports whether ctx was marked by WithAllTenants.
+func AllTenants(ctx context.Context) bool {
+	all, _ := ctx.Value(allTenantsKey{}).(bool)
+	return all
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is someone subscriptions can belong to.
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	Email     *string   `json:"email,omitempty" example:"jane@example.com"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateUserRequest registers a user. Without an ID a new one is
+// generated; pass one to register a user known to another system.
+type CreateUserRequest struct {
+	ID    *uuid.UUID `json:"id,omitempty"`
+	Email *string    `json:"email,omitempty" binding:"omitempty,email,max=255" example:"jane@example.com"`
+}
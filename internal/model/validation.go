@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// ValidationError reports input that breaks a business rule. Its message is
+// meant to be shown to the client as is. It matches domain.ErrValidation.
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
+}
+
+func (e ValidationError) Is(target error) bool {
+	return target == domain.ErrValidation
+}
+
+// TransitionError reports a status change the subscription lifecycle does
+// not allow.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change status from %s to %s", e.From, e.To)
+}
+
+// OverlapError reports subscriptions of the same user and service whose
+// billing periods overlap the one being written.
+type OverlapError struct {
+	IDs []uuid.UUID
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("period overlaps %d existing subscription(s) to the same service", len(e.IDs))
+}
+
+// ErrQuotaExceeded is matched by every *QuotaExceededError.
+var ErrQuotaExceeded = errors.New("subscription quota exceeded")
+
+// QuotaExceededError reports a write that would leave a user with more
+// active subscriptions than their quota allows. Count is the number they
+// have without the write.
+type QuotaExceededError struct {
+	UserID uuid.UUID
+	Count  int
+	Limit  int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("user %s has %d active subscription(s), the limit is %d", e.UserID, e.Count, e.Limit)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
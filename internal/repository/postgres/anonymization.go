@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetAnonymization returns the synthetic user userID was anonymized to, or
+// domain.ErrNotFound when userID was never anonymized or only irreversibly.
+func (r *SubscriptionRepository) GetAnonymization(ctx context.Context, userID uuid.UUID) (*model.Anonymization, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("user_id", "synthetic_user_id", "created_at").
+		From("user_anonymizations").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetAnonymization: failed to build query: %w", err)
+	}
+
+	var anonymization model.Anonymization
+	err = r.conn(ctx).QueryRow(ctx, query, args...).Scan(&anonymization.UserID, &anonymization.SyntheticUserID, &anonymization.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("repository.GetAnonymization: %w", err)
+	}
+	return &anonymization, nil
+}
+
+// SaveAnonymization stores anonymization and fills in its creation time.
+func (r *SubscriptionRepository) SaveAnonymization(ctx context.Context, anonymization *model.Anonymization) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.SaveAnonymization: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("user_anonymizations").
+		Columns("tenant_id", "user_id", "synthetic_user_id").
+		Values(tenant, anonymization.UserID, anonymization.SyntheticUserID).
+		Suffix("RETURNING created_at").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.SaveAnonymization: failed to build query: %w", err)
+	}
+
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&anonymization.CreatedAt); err != nil {
+		return fmt.Errorf("repository.SaveAnonymization: %w", err)
+	}
+	return nil
+}
+
+// DeleteAnonymization forgets the synthetic user userID was anonymized to.
+func (r *SubscriptionRepository) DeleteAnonymization(ctx context.Context, userID uuid.UUID) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("user_anonymizations").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.DeleteAnonymization: failed to build query: %w", err)
+	}
+
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("repository.DeleteAnonymization: %w", err)
+	}
+	return nil
+}
+
+// Anonymize moves every subscription of userID, soft-deleted, archived or
+// not, to syntheticID, clears their notes and metadata and marks them
+// anonymized. The shares userID has in the subscriptions of others move
+// along. Prices and dates are left alone, so costs stay the same under the
+// synthetic user, which is registered as a user when it takes over
+// subscriptions. It returns how many subscriptions were moved, or
+// domain.ErrConflict when one collides with a subscription syntheticID already
+// has.
+func (r *SubscriptionRepository) Anonymize(ctx context.Context, userID, syntheticID uuid.UUID) (int64, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("repository.Anonymize: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("users").
+		Columns("tenant_id", "id").
+		Select(squirrel.Select().
+			Column("?::uuid, ?::uuid", tenant, syntheticID).
+			Where("EXISTS (SELECT 1 FROM subscriptions WHERE tenant_id = ? AND user_id = ?)", tenant, userID)).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.Anonymize: failed to build query: %w", err)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("repository.Anonymize: %w", err)
+	}
+
+	query, args, err = psql.Update("subscription_members").
+		Set("user_id", syntheticID).
+		Set("updated_at", squirrel.Expr("now()")).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.Anonymize: failed to build query: %w", err)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("repository.Anonymize: %w", err)
+	}
+
+	var anonymized int64
+	for _, table := range []string{"subscriptions", "subscriptions_archive"} {
+		query, args, err := psql.Update(table).
+			Set("user_id", syntheticID).
+			Set("notes", nil).
+			Set("metadata", metadataValue(nil)).
+			Set("anonymized_at", squirrel.Expr("now()")).
+			Set("updated_at", squirrel.Expr("now()")).
+			Set("version", squirrel.Expr("version + 1")).
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"user_id": userID}).
+			ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("repository.Anonymize: failed to build query: %w", err)
+		}
+
+		tag, err := r.conn(ctx).Exec(ctx, query, args...)
+		if err != nil {
+			if isConflict(err) {
+				return 0, fmt.Errorf("repository.Anonymize: %w", domain.ErrConflict)
+			}
+			return 0, fmt.Errorf("repository.Anonymize: %w", err)
+		}
+		anonymized += tag.RowsAffected()
+	}
+	return anonymized, nil
+}
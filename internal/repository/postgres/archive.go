@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// archiveColumns are the columns copied to subscriptions_archive.
+var archiveColumns = append([]string{"tenant_id"}, subscriptionColumns...)
+
+// withArchiveSQL stands in for the subscriptions table in queries that
+// also cover archived subscriptions.
+var withArchiveSQL = fmt.Sprintf("(SELECT %[1]s FROM subscriptions UNION ALL SELECT %[1]s FROM subscriptions_archive) AS subscriptions", strings.Join(archiveColumns, ", "))
+
+// subscriptionsTable returns the table queries read subscriptions from,
+// which takes in the archive with includeArchived.
+func subscriptionsTable(includeArchived bool) string {
+	if includeArchived {
+		return withArchiveSQL
+	}
+	return "subscriptions"
+}
+
+// archivable matches the subscriptions that ended before the month of
+// cutoff. Auto-renewing subscriptions still running past their end date
+// are left alone.
+func archivable(cutoff time.Time) squirrel.Sqlizer {
+	return squirrel.And{
+		squirrel.Expr("end_date < date_trunc('month', ?::date)", cutoff),
+		squirrel.Or{
+			squirrel.Eq{"auto_renew": false},
+			squirrel.Eq{"status": []string{model.StatusCancelled, model.StatusExpired}},
+			squirrel.NotEq{"deleted_at": nil},
+		},
+	}
+}
+
+// ArchiveBatch moves up to batchSize subscriptions that ended before the
+// month of cutoff to subscriptions_archive and returns them as they were.
+// Rows locked by concurrent writes are skipped. Run within WithTx, so that
+// a batch is copied and deleted together; fewer than batchSize rows mean
+// there is nothing left to archive.
+func (r *SubscriptionRepository) ArchiveBatch(ctx context.Context, cutoff time.Time, batchSize int) ([]model.SubscriptionChange, error) {
+	if batchSize < 1 {
+		return nil, fmt.Errorf("repository.ArchiveBatch: invalid batch size %d", batchSize)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("id").
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(archivable(cutoff)).
+		OrderBy("id").
+		Limit(uint64(batchSize)).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: %w", err)
+	}
+	ids := make([]uuid.UUID, 0, batchSize)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("repository.ArchiveBatch: row scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query, args, err = psql.Insert("subscriptions_archive").
+		Columns(archiveColumns...).
+		Select(squirrel.Select(archiveColumns...).
+			From("subscriptions").
+			Where(squirrel.Eq{"id": ids})).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: failed to build query: %w", err)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: %w", err)
+	}
+
+	query, args, err = psql.Delete("subscriptions").
+		Where(squirrel.Eq{"id": ids}).
+		Suffix("RETURNING " + strings.Join(archiveColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: failed to build query: %w", err)
+	}
+	rows, err = r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]model.SubscriptionChange, 0, len(ids))
+	for rows.Next() {
+		var change model.SubscriptionChange
+		if err := rows.Scan(append([]any{&change.TenantID}, subscriptionFields(&change.Before)...)...); err != nil {
+			return nil, fmt.Errorf("repository.ArchiveBatch: row scan failed: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.ArchiveBatch: %w", err)
+	}
+	return changes, nil
+}
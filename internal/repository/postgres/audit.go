@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditRepository stores the audit log. Apart from removing a user's
+// entries on erasure or anonymization it only ever appends; the table
+// rejects updates and any other deletes.
+type AuditRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewAuditRepository(db *pgxpool.Pool, log *slog.Logger) *AuditRepository {
+	return &AuditRepository{db: db, log: log}
+}
+
+// Append stores entry and fills in its ID and creation time. Within
+// SubscriptionRepository.WithTx it is written in the same transaction as
+// the change it records.
+func (r *AuditRepository) Append(ctx context.Context, entry *model.AuditEntry) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.AppendAuditEntry: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("audit_log").
+		Columns("tenant_id", "subscription_id", "action", "old_value", "new_value", "actor", "client_ip").
+		Values(tenant, entry.SubscriptionID, entry.Action, entry.OldValue, entry.NewValue, entry.Actor, entry.ClientIP).
+		Suffix("RETURNING id, created_at").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.AppendAuditEntry: failed to build query: %w", err)
+	}
+
+	if err := conn(ctx, r.db).QueryRow(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt); err != nil {
+		return fmt.Errorf("repository.AppendAuditEntry: %w", err)
+	}
+	return nil
+}
+
+// ListBySubscription returns the entries recorded for the subscription id,
+// oldest first.
+func (r *AuditRepository) ListBySubscription(ctx context.Context, id uuid.UUID, limit, offset int) ([]model.AuditEntry, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("repository.ListAuditEntries: %w", domain.ErrInvalidPagination)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("id", "subscription_id", "action", "old_value", "new_value", "actor", "client_ip", "created_at").
+		From("audit_log").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"subscription_id": id}).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListAuditEntries: failed to build query: %w", err)
+	}
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListAuditEntries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditEntry, 0)
+	for rows.Next() {
+		var entry model.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.SubscriptionID, &entry.Action, &entry.OldValue, &entry.NewValue, &entry.Actor, &entry.ClientIP, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository.ListAuditEntries: row scan failed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.ListAuditEntries: %w", err)
+	}
+	return entries, nil
+}
+
+// DeleteByUser removes the entries recorded for subscriptions of userID,
+// found through the user_id of their old or new value, and returns how
+// many were removed. Earlier erasure and anonymization entries are kept.
+func (r *AuditRepository) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("audit_log").
+		Where(tenantScope(ctx)).
+		Where(squirrel.NotEq{"action": []string{model.AuditActionErase, model.AuditActionAnonymize}}).
+		Where(squirrel.Or{
+			squirrel.Expr("old_value->>'user_id' = ?", userID.String()),
+			squirrel.Expr("new_value->>'user_id' = ?", userID.String()),
+		}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteAuditEntriesByUser: failed to build query: %w", err)
+	}
+
+	var deleted int64
+	err = withTx(ctx, r.db, func(ctx context.Context) error {
+		// The append-only trigger lets deletes through while this is set.
+		if _, err := conn(ctx, r.db).Exec(ctx, "SELECT set_config('audit_log.erasure', 'on', true)"); err != nil {
+			return err
+		}
+		tag, err := conn(ctx, r.db).Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteAuditEntriesByUser: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetBudget returns the budget of userID or domain.ErrNotFound.
+func (r *SubscriptionRepository) GetBudget(ctx context.Context, userID uuid.UUID) (*model.Budget, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("user_id", "amount_minor", "currency", "period", "created_at", "updated_at").
+		From("budgets").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetBudget: failed to build query: %w", err)
+	}
+
+	budget := &model.Budget{}
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&budget.UserID, &budget.AmountMinor, &budget.Currency, &budget.Period, &budget.CreatedAt, &budget.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("repository.GetBudget: %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("repository.GetBudget: %w", err)
+	}
+	return budget, nil
+}
+
+// SetBudget stores budget, replacing the budget of its user if they have
+// one, and fills in the timestamps. It reports whether the budget was
+// created.
+func (r *SubscriptionRepository) SetBudget(ctx context.Context, budget *model.Budget) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repository.SetBudget: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("budgets").
+		Columns("tenant_id", "user_id", "amount_minor", "currency", "period").
+		Values(tenant, budget.UserID, budget.AmountMinor, budget.Currency, budget.Period).
+		Suffix(`ON CONFLICT (tenant_id, user_id) DO UPDATE SET
+			amount_minor = EXCLUDED.amount_minor,
+			currency = EXCLUDED.currency,
+			period = EXCLUDED.period,
+			updated_at = now()
+			RETURNING created_at, updated_at, xmax = 0`).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("repository.SetBudget: failed to build query: %w", err)
+	}
+
+	var created bool
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&budget.CreatedAt, &budget.UpdatedAt, &created); err != nil {
+		return false, fmt.Errorf("repository.SetBudget: %w", err)
+	}
+	return created, nil
+}
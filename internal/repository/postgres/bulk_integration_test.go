@@ -0,0 +1,67 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// BenchmarkImport compares storing an import batch with the single COPY of
+// CreateMany to inserting it row by row in one transaction:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration -run '^$' -bench Import ./internal/repository/postgres/
+func BenchmarkImport(b *testing.B) {
+	const batchSize = 500
+	repo := NewSubscriptionRepository(testPool(b), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	userID := uuid.New()
+	if err := repo.EnsureUser(ctx, userID); err != nil {
+		b.Fatalf("EnsureUser() error = %v", err)
+	}
+
+	// Every row gets a service of its own, so that no two rows ever
+	// collide on the unique start index.
+	var n int
+	batch := func() []model.Subscription {
+		subs := make([]model.Subscription, batchSize)
+		for i := range subs {
+			n++
+			subs[i] = model.Subscription{ServiceName: fmt.Sprintf("Service %d", n), PriceMinor: 1000, Currency: "RUB",
+				BillingPeriod: model.BillingMonthly, UserID: userID, StartDate: month(2024, 1)}
+		}
+		return subs
+	}
+
+	b.Run("copy", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := repo.CreateMany(ctx, batch()); err != nil {
+				b.Fatalf("CreateMany() error = %v", err)
+			}
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "rows/s")
+	})
+
+	b.Run("insert", func(b *testing.B) {
+		for b.Loop() {
+			subs := batch()
+			err := repo.WithTx(ctx, func(ctx context.Context) error {
+				for i := range subs {
+					if err := repo.Create(ctx, &subs[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				b.Fatalf("Create() error = %v", err)
+			}
+		}
+		b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "rows/s")
+	})
+}
@@ -0,0 +1,266 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// catalogNameIndex allows a single catalog entry per case-insensitive name.
+const catalogNameIndex = "idx_services_lower_name"
+
+var catalogColumns = []string{"id", "name", "url", "category", "created_at", "updated_at"}
+
+// scanCatalogEntry scans a row selected with catalogColumns.
+func scanCatalogEntry(row pgx.Row, entry *model.CatalogEntry) error {
+	return row.Scan(&entry.ID, &entry.Name, &entry.URL, &entry.Category, &entry.CreatedAt, &entry.UpdatedAt)
+}
+
+// isCatalogNameTaken reports whether err violates catalogNameIndex.
+func isCatalogNameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == catalogNameIndex
+}
+
+// CatalogRepository stores the service catalog in the services table.
+type CatalogRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewCatalogRepository(db *pgxpool.Pool, log *slog.Logger) *CatalogRepository {
+	return &CatalogRepository{db: db, log: log}
+}
+
+// pool returns the pool with errors passing through translate. The
+// catalog does not take part in transactions of other repositories.
+func (r *CatalogRepository) pool() querier {
+	return translating{q: r.db}
+}
+
+// Create stores entry and fills in the generated fields. It returns
+// domain.ErrCatalogNameTaken when another entry has the same name.
+func (r *CatalogRepository) Create(ctx context.Context, entry *model.CatalogEntry) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.CreateCatalogEntry: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("services").
+		Columns("tenant_id", "name", "url", "category").
+		Values(tenant, entry.Name, entry.URL, entry.Category).
+		Suffix("RETURNING " + strings.Join(catalogColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.CreateCatalogEntry: failed to build query: %w", err)
+	}
+
+	if err := scanCatalogEntry(r.pool().QueryRow(ctx, query, args...), entry); err != nil {
+		if isCatalogNameTaken(err) {
+			return fmt.Errorf("repository.CreateCatalogEntry: %w", domain.ErrCatalogNameTaken)
+		}
+		return fmt.Errorf("repository.CreateCatalogEntry: %w", err)
+	}
+	return nil
+}
+
+// GetOrCreate returns the entry named name, matched case-insensitively,
+// and stores a new one with that name when there is none. Concurrent calls
+// for the same name end up with the same entry.
+func (r *CatalogRepository) GetOrCreate(ctx context.Context, name string) (*model.CatalogEntry, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetOrCreateCatalogEntry: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("services").
+		Columns("tenant_id", "name").
+		Values(tenant, name).
+		Suffix("ON CONFLICT (tenant_id, LOWER(name)) DO NOTHING RETURNING " + strings.Join(catalogColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetOrCreateCatalogEntry: failed to build query: %w", err)
+	}
+
+	entry := &model.CatalogEntry{}
+	err = scanCatalogEntry(r.pool().QueryRow(ctx, query, args...), entry)
+	if err == nil {
+		return entry, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("repository.GetOrCreateCatalogEntry: %w", err)
+	}
+
+	// Nothing was inserted because the entry exists already.
+	entry, err = r.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetOrCreateCatalogEntry: %w", err)
+	}
+	return entry, nil
+}
+
+// GetByID returns the entry with the given ID or domain.ErrNotFound.
+func (r *CatalogRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.CatalogEntry, error) {
+	return r.get(ctx, "repository.GetCatalogEntryByID", squirrel.Eq{"id": id})
+}
+
+// GetByName returns the entry named name, matched case-insensitively, or
+// domain.ErrNotFound.
+func (r *CatalogRepository) GetByName(ctx context.Context, name string) (*model.CatalogEntry, error) {
+	return r.get(ctx, "repository.GetCatalogEntryByName", squirrel.Expr("LOWER(name) = LOWER(?)", name))
+}
+
+func (r *CatalogRepository) get(ctx context.Context, op string, where squirrel.Sqlizer) (*model.CatalogEntry, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select(catalogColumns...).
+		From("services").
+		Where(tenantScope(ctx)).
+		Where(where).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build query: %w", op, err)
+	}
+
+	entry := &model.CatalogEntry{}
+	if err := scanCatalogEntry(r.pool().QueryRow(ctx, query, args...), entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return entry, nil
+}
+
+// List returns a page of catalog entries ordered by name.
+func (r *CatalogRepository) List(ctx context.Context, limit, offset int) ([]model.CatalogEntry, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("repository.ListCatalogEntries: %w", domain.ErrInvalidPagination)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select(catalogColumns...).
+		From("services").
+		Where(tenantScope(ctx)).
+		OrderBy("LOWER(name)", "id").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListCatalogEntries: failed to build query: %w", err)
+	}
+
+	rows, err := r.pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListCatalogEntries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]model.CatalogEntry, 0)
+	for rows.Next() {
+		var entry model.CatalogEntry
+		if err := scanCatalogEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("repository.ListCatalogEntries: row scan failed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.ListCatalogEntries: %w", err)
+	}
+	return entries, nil
+}
+
+// Update replaces the name, url and category of the entry with entry.ID and
+// renames the subscriptions linked to it in the same transaction, so that they
+// keep showing the canonical name. It returns domain.ErrNotFound when no such
+// entry exists and domain.ErrCatalogNameTaken when another entry has the new
+// name.
+func (r *CatalogRepository) Update(ctx context.Context, entry *model.CatalogEntry) error {
+	tx, err := r.pool().Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.UpdateCatalogEntry: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Update("services").
+		Set("name", entry.Name).
+		Set("url", entry.URL).
+		Set("category", entry.Category).
+		Set("updated_at", squirrel.Expr("now()")).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": entry.ID}).
+		Suffix("RETURNING " + strings.Join(catalogColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.UpdateCatalogEntry: failed to build query: %w", err)
+	}
+	if err := scanCatalogEntry(tx.QueryRow(ctx, query, args...), entry); err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("repository.UpdateCatalogEntry: %w", domain.ErrNotFound)
+		case isCatalogNameTaken(err):
+			return fmt.Errorf("repository.UpdateCatalogEntry: %w", domain.ErrCatalogNameTaken)
+		}
+		return fmt.Errorf("repository.UpdateCatalogEntry: %w", err)
+	}
+
+	query, args, err = psql.Update("subscriptions").
+		Set("service_name", entry.Name).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"service_id": entry.ID}).
+		Where(squirrel.NotEq{"service_name": entry.Name}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.UpdateCatalogEntry: failed to build query: %w", err)
+	}
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		if isConflict(err) {
+			return fmt.Errorf("repository.UpdateCatalogEntry: %w", domain.ErrConflict)
+		}
+		return fmt.Errorf("repository.UpdateCatalogEntry: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("repository.UpdateCatalogEntry: failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the entry with the given ID. It returns domain.ErrNotFound
+// when no such entry exists and domain.ErrCatalogInUse while subscriptions,
+// including soft-deleted ones, still reference it.
+func (r *CatalogRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("services").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.DeleteCatalogEntry: failed to build query: %w", err)
+	}
+
+	tag, err := r.pool().Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+			return fmt.Errorf("repository.DeleteCatalogEntry: %w", domain.ErrCatalogInUse)
+		}
+		return fmt.Errorf("repository.DeleteCatalogEntry: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("repository.DeleteCatalogEntry: %w", domain.ErrNotFound)
+	}
+	return nil
+}
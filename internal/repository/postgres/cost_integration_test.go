@@ -0,0 +1,141 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// month returns the MonthYear of month in year.
+func month(year int, month time.Month) model.MonthYear {
+	return model.NewMonthYear(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// costCase is a subscription whose cost over a period is known. The cases
+// are kept in internal/testdata/cost_cases.json, shared with the service
+// tests of monthCost.
+type costCase struct {
+	Name     string             `json:"name"`
+	Sub      model.Subscription `json:"subscription"`
+	From     model.MonthYear    `json:"from"`
+	To       model.MonthYear    `json:"to"`
+	Amortize bool               `json:"amortize"`
+	Want     int64              `json:"want"`
+}
+
+// costCases loads the shared cost cases, failing t when they cannot be
+// read.
+func costCases(t *testing.T) []costCase {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/cost_cases.json")
+	if err != nil {
+		t.Fatalf("failed to read cost cases: %v", err)
+	}
+	var cases []costCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("failed to decode cost cases: %v", err)
+	}
+	return cases
+}
+
+// createCostCase stores the subscription of a cost case, cancelling it at
+// its cancelled_at if it has one.
+func createCostCase(ctx context.Context, t *testing.T, repo *SubscriptionRepository, sub *model.Subscription) {
+	t.Helper()
+	cancelledAt := sub.CancelledAt
+	if err := repo.Create(ctx, sub); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if cancelledAt != nil {
+		if _, _, err := repo.Cancel(ctx, sub.ID, *cancelledAt, false); err != nil {
+			t.Fatalf("Cancel() error = %v", err)
+		}
+	}
+}
+
+func TestTotalCostSQL(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	for _, tt := range costCases(t) {
+		t.Run(tt.Name, func(t *testing.T) {
+			sub := tt.Sub
+			sub.UserID, sub.ServiceName = uuid.New(), "Service"
+			if err := repo.EnsureUser(ctx, sub.UserID); err != nil {
+				t.Fatalf("EnsureUser() error = %v", err)
+			}
+			createCostCase(ctx, t, repo, &sub)
+
+			from, to := tt.From.Time(), tt.To.Time()
+			totals, _, _, err := repo.GetTotalCostByCurrency(ctx, &sub.UserID, "", "", &from, &to, tt.Amortize, false)
+			if err != nil {
+				t.Fatalf("GetTotalCostByCurrency() error = %v", err)
+			}
+			var total int64
+			for _, amount := range totals {
+				total += amount
+			}
+			if total != tt.Want {
+				t.Errorf("cost = %d, want %d", total, tt.Want)
+			}
+		})
+	}
+}
+
+func TestCostExpansionInGoMatchesSQL(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	newService := func(inGo bool) *service.SubscriptionService {
+		return service.NewSubscriptionService(repo, repo, nil, nil, nil, config.CacheConfig{}, config.CostConfig{ExpandInGo: inGo},
+			config.IdempotencyConfig{}, config.ArchiveConfig{}, config.QuotaConfig{}, config.BudgetConfig{}, testLog)
+	}
+	inSQL, inGo := newService(false), newService(true)
+
+	for _, tt := range costCases(t) {
+		t.Run(tt.Name, func(t *testing.T) {
+			sub := tt.Sub
+			sub.UserID, sub.ServiceName, sub.Currency = uuid.New(), "Service", "RUB"
+			member := uuid.New()
+			for _, user := range []uuid.UUID{sub.UserID, member} {
+				if err := repo.EnsureUser(ctx, user); err != nil {
+					t.Fatalf("EnsureUser() error = %v", err)
+				}
+			}
+			createCostCase(ctx, t, repo, &sub)
+			// A member paying a third makes both sides round the parts.
+			if _, err := repo.SetMember(ctx, &model.SubscriptionMember{SubscriptionID: sub.ID, UserID: member, SharePercent: 33}); err != nil {
+				t.Fatalf("SetMember() error = %v", err)
+			}
+
+			from, to := tt.From.Time(), tt.To.Time()
+			var total int64
+			for _, user := range []uuid.UUID{sub.UserID, member} {
+				want, err := inSQL.GetCostBreakdown(ctx, user, "", sub.Currency, &from, &to, true, true, tt.Amortize)
+				if err != nil {
+					t.Fatalf("GetCostBreakdown() in SQL error = %v", err)
+				}
+				got, err := inGo.GetCostBreakdown(ctx, user, "", sub.Currency, &from, &to, true, true, tt.Amortize)
+				if err != nil {
+					t.Fatalf("GetCostBreakdown() in Go error = %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("breakdown of %s in Go = %+v, in SQL = %+v", user, got, want)
+				}
+				total += *want.TotalCost
+			}
+			if total != tt.Want {
+				t.Errorf("parts add up to %d, want %d", total, tt.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCostSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		cost string
+	}{
+		{name: "billed", cost: billedCostSQL},
+		{name: "amortized", cost: amortizedCostSQL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := costSQL(tt.cost)
+			if strings.ContainsAny(rendered, "{}") {
+				t.Fatalf("costSQL() left a placeholder: %s", rendered)
+			}
+			// The discounted months run up to dh, the list-price ones
+			// from the month after.
+			for _, want := range []string{"discounted_price * ", "LEAST(hi, dh)", "price_minor * ", "GREATEST(lo, dh + 1)"} {
+				if !strings.Contains(rendered, want) {
+					t.Errorf("costSQL() = %s, missing %q", rendered, want)
+				}
+			}
+		})
+	}
+}
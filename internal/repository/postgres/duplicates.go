@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// GetDuplicateGroups returns groups of at least minGroupSize subscriptions
+// of the same user and service, compared case-insensitively, whose billed
+// periods overlap, paginated over groups. A group is a chain: every member
+// overlaps at least one earlier member, so two members need not overlap
+// each other directly. Groups are ordered by user, service and start.
+func (r *SubscriptionRepository) GetDuplicateGroups(ctx context.Context, minGroupSize, limit, offset int) ([]model.DuplicateGroup, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("repository.GetDuplicateGroups: %w", domain.ErrInvalidPagination)
+	}
+
+	// Gaps and islands: ordered by start, a subscription opens a new group
+	// unless it starts before the latest billed end of the earlier ones.
+	// Open-ended subscriptions never end, so they absorb everything after.
+	ordered := squirrel.Select("id", "user_id", "LOWER(service_name) AS normalized_name", "start_date").
+		Column("MAX(COALESCE(" + billedEndExpr + ", 'infinity'::date)) OVER (PARTITION BY user_id, LOWER(service_name) ORDER BY start_date, id ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING) AS previous_end").
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(notDeleted)
+	islands := squirrel.Select("id", "user_id", "normalized_name", "start_date").
+		Column("SUM(CASE WHEN previous_end IS NULL OR start_date > previous_end THEN 1 ELSE 0 END) OVER (PARTITION BY user_id, normalized_name ORDER BY start_date, id) AS island").
+		FromSelect(ordered, "ordered")
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("user_id", "normalized_name", "array_agg(id ORDER BY start_date, id)").
+		FromSelect(islands, "islands").
+		GroupBy("user_id", "normalized_name", "island").
+		Having("COUNT(*) >= ?", minGroupSize).
+		OrderBy("user_id", "normalized_name", "MIN(start_date)").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetDuplicateGroups: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetDuplicateGroups: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make([]model.DuplicateGroup, 0)
+	var memberIDs [][]uuid.UUID
+	var ids []uuid.UUID
+	for rows.Next() {
+		var group model.DuplicateGroup
+		var members []uuid.UUID
+		if err := rows.Scan(&group.UserID, &group.ServiceName, &members); err != nil {
+			return nil, fmt.Errorf("repository.GetDuplicateGroups: row scan failed: %w", err)
+		}
+		groups = append(groups, group)
+		memberIDs = append(memberIDs, members)
+		ids = append(ids, members...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetDuplicateGroups: %w", err)
+	}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+
+	subs, err := r.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetDuplicateGroups: %w", err)
+	}
+	byID := make(map[uuid.UUID]model.Subscription, len(subs))
+	for _, sub := range subs {
+		byID[sub.ID] = sub
+	}
+	// Members deleted since the groups were found are left out.
+	for i := range groups {
+		groups[i].Subscriptions = make([]model.Subscription, 0, len(memberIDs[i]))
+		for _, id := range memberIDs[i] {
+			if sub, ok := byID[id]; ok {
+				groups[i].Subscriptions = append(groups[i].Subscriptions, sub)
+			}
+		}
+	}
+	return groups, nil
+}
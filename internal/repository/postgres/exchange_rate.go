@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ExchangeRateRepository stores exchange rates. Rates are shared by all
+// tenants.
+type ExchangeRateRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewExchangeRateRepository(db *pgxpool.Pool, log *slog.Logger) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db, log: log}
+}
+
+// Upsert stores rates, replacing the rates of the same pairs and days. No
+// two rates may share their pair and day.
+func (r *ExchangeRateRepository) Upsert(ctx context.Context, rates []model.ExchangeRate) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	queryBuilder := psql.Insert("exchange_rates").
+		Columns("base", "quote", "as_of", "rate")
+	for _, rate := range rates {
+		queryBuilder = queryBuilder.Values(rate.Base, rate.Quote, rate.AsOf, rate.Rate)
+	}
+	query, args, err := queryBuilder.
+		Suffix(`ON CONFLICT (base, quote, as_of) DO UPDATE SET
+			rate = EXCLUDED.rate,
+			updated_at = now()`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.UpsertExchangeRates: failed to build query: %w", err)
+	}
+
+	if _, err := conn(ctx, r.db).Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("repository.UpsertExchangeRates: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the most recent rate as of day between target and each
+// of currencies, in whichever direction it is stored. Pairs stored in both
+// directions are returned twice.
+func (r *ExchangeRateRepository) Latest(ctx context.Context, currencies []string, target string, day time.Time) ([]model.ExchangeRate, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("base", "quote", "rate::text", "as_of::text").
+		Options("DISTINCT ON (base, quote)").
+		From("exchange_rates").
+		Where(squirrel.Or{
+			squirrel.Eq{"base": currencies, "quote": target},
+			squirrel.Eq{"base": target, "quote": currencies},
+		}).
+		Where(squirrel.LtOrEq{"as_of": day}).
+		OrderBy("base", "quote", "as_of DESC").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.LatestExchangeRates: failed to build query: %w", err)
+	}
+
+	rows, err := conn(ctx, r.db).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.LatestExchangeRates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := make([]model.ExchangeRate, 0)
+	for rows.Next() {
+		var rate model.ExchangeRate
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.AsOf); err != nil {
+			return nil, fmt.Errorf("repository.LatestExchangeRates: row scan failed: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.LatestExchangeRates: %w", err)
+	}
+	return rates, nil
+}
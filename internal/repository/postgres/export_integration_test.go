@@ -0,0 +1,71 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestExportStreamsAllRows(t *testing.T) {
+	// pgx has no fetch size of its own: it reads rows off the connection
+	// as the server sends them, a buffer at a time, so thousands of rows
+	// take many reads.
+	const rows = 5000
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	userID := uuid.New()
+	if err := repo.EnsureUser(ctx, userID); err != nil {
+		t.Fatalf("EnsureUser() error = %v", err)
+	}
+	subs := make([]model.Subscription, rows)
+	for i := range subs {
+		subs[i] = model.Subscription{ServiceName: fmt.Sprintf("Service %d", i), PriceMinor: 500, Currency: "RUB",
+			BillingPeriod: model.BillingMonthly, UserID: userID, StartDate: month(2024, 1)}
+	}
+	if _, err := repo.CreateMany(ctx, subs); err != nil {
+		t.Fatalf("CreateMany() error = %v", err)
+	}
+
+	t.Run("every row once", func(t *testing.T) {
+		seen := make(map[uuid.UUID]bool, rows)
+		err := repo.Export(ctx, func(sub model.Subscription) error {
+			if seen[sub.ID] {
+				t.Errorf("Export() wrote %s twice", sub.ID)
+			}
+			seen[sub.ID] = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if len(seen) != rows {
+			t.Errorf("Export() wrote %d rows, want %d", len(seen), rows)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var written int
+		err := repo.Export(ctx, func(sub model.Subscription) error {
+			written++
+			if written == 10 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Export() error = %v, want %v", err, context.Canceled)
+		}
+		if written != 10 {
+			t.Errorf("Export() wrote %d rows, want it to stop after the cancellation at 10", written)
+		}
+	})
+}
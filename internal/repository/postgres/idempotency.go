@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateIdempotent creates sub, overwriting it with the stored row, unless key
+// was already used since notBefore. In that case it returns the ID of the
+// subscription created by the first request and replayed set to true, or
+// domain.ErrIdempotencyKeyReused when requestHash differs. Concurrent requests
+// of the same tenant with the same key are serialized with a
+// transaction-scoped advisory lock on the tenant and the key.
+func (r *SubscriptionRepository) CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, notBefore time.Time) (id uuid.UUID, replayed bool, err error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: %w", err)
+	}
+
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", tenant.String(), key); err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to lock key: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("request_hash", "subscription_id").
+		From("idempotency_keys").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"key": key}).
+		Where(squirrel.Gt{"created_at": notBefore}).
+		ToSql()
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to build query: %w", err)
+	}
+
+	var storedHash string
+	err = tx.QueryRow(ctx, query, args...).Scan(&storedHash, &id)
+	switch {
+	case err == nil:
+		if storedHash != requestHash {
+			return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: %w", domain.ErrIdempotencyKeyReused)
+		}
+		return id, true, nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: %w", err)
+	}
+
+	query, args, err = psql.Insert("subscriptions").
+		Columns(insertColumns...).
+		Values(insertValues(tenant, sub)...).
+		Suffix("RETURNING " + strings.Join(subscriptionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to build query: %w", err)
+	}
+	if err := scanSubscription(tx.QueryRow(ctx, query, args...), sub); err != nil {
+		switch {
+		case isConflict(err):
+			// The failed insert aborted tx, so look up the conflict outside.
+			err = r.conflict(ctx, sub, err)
+		case isUnknownUser(err):
+			err = domain.ErrUnknownUser
+		}
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: %w", err)
+	}
+
+	// An expired key that has not been cleaned up yet is taken over.
+	query, args, err = psql.Insert("idempotency_keys").
+		Columns("tenant_id", "key", "request_hash", "subscription_id").
+		Values(tenant, key, requestHash, sub.ID).
+		Suffix(`ON CONFLICT (tenant_id, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			subscription_id = EXCLUDED.subscription_id,
+			created_at = now()`).
+		ToSql()
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to build query: %w", err)
+	}
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to store key: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, false, fmt.Errorf("repository.CreateIdempotent: failed to commit transaction: %w", err)
+	}
+	return sub.ID, false, nil
+}
+
+// GetIdempotencyKey returns the request hash and subscription ID stored
+// for key if it was used since notBefore, or domain.ErrNotFound.
+func (r *SubscriptionRepository) GetIdempotencyKey(ctx context.Context, key string, notBefore time.Time) (string, uuid.UUID, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("request_hash", "subscription_id").
+		From("idempotency_keys").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"key": key}).
+		Where(squirrel.Gt{"created_at": notBefore}).
+		ToSql()
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("repository.GetIdempotencyKey: failed to build query: %w", err)
+	}
+
+	var requestHash string
+	var id uuid.UUID
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&requestHash, &id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", uuid.Nil, domain.ErrNotFound
+		}
+		return "", uuid.Nil, fmt.Errorf("repository.GetIdempotencyKey: %w", err)
+	}
+	return requestHash, id, nil
+}
+
+// DeleteIdempotencyKeys removes the idempotency keys created before
+// olderThan and returns how many were removed.
+func (r *SubscriptionRepository) DeleteIdempotencyKeys(ctx context.Context, olderThan time.Time) (int64, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("idempotency_keys").
+		Where(tenantScope(ctx)).
+		Where(squirrel.LtOrEq{"created_at": olderThan}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteIdempotencyKeys: failed to build query: %w", err)
+	}
+
+	tag, err := r.conn(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteIdempotencyKeys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteIdempotencyKeysByUser removes the idempotency keys pointing at
+// subscriptions of userID and returns how many were removed. It must run
+// before the subscriptions themselves are purged.
+func (r *SubscriptionRepository) DeleteIdempotencyKeysByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("idempotency_keys").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Expr("subscription_id IN (SELECT id FROM "+withArchiveSQL+" WHERE user_id = ?)", userID)).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteIdempotencyKeysByUser: failed to build query: %w", err)
+	}
+
+	tag, err := r.conn(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("repository.DeleteIdempotencyKeysByUser: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,65 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Integration tests run against the database named by TEST_DATABASE_URL,
+// a postgres:// URL, with the migrations applied:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration ./internal/repository/postgres/
+//
+// Every test works in tenants of its own, so the database needs no
+// cleaning between runs.
+
+var migrateOnce sync.Once
+
+// testPool returns a pool connected to the test database, skipping the
+// test when none is configured.
+func testPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), testDatabaseURL(t))
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// testDatabaseURL returns the URL of the test database with the migrations
+// applied, skipping the test when none is configured.
+func testDatabaseURL(t testing.TB) string {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	var migrateErr error
+	migrateOnce.Do(func() {
+		m, err := migrate.New("file://../../../migrations", url)
+		if err != nil {
+			migrateErr = err
+			return
+		}
+		defer m.Close()
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			migrateErr = err
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("failed to apply migrations: %v", migrateErr)
+	}
+	return url
+}
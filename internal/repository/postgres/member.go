@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var memberColumns = []string{"subscription_id", "user_id", "share_percent", "created_at", "updated_at"}
+
+// ListMembers returns the members of the subscription id, oldest first.
+func (r *SubscriptionRepository) ListMembers(ctx context.Context, id uuid.UUID) ([]model.SubscriptionMember, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select(memberColumns...).
+		From("subscription_members").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"subscription_id": id}).
+		OrderBy("created_at", "user_id").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListMembers: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ListMembers: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]model.SubscriptionMember, 0)
+	for rows.Next() {
+		var member model.SubscriptionMember
+		if err := rows.Scan(&member.SubscriptionID, &member.UserID, &member.SharePercent, &member.CreatedAt, &member.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("repository.ListMembers: row scan failed: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.ListMembers: %w", err)
+	}
+	return members, nil
+}
+
+// SetMember adds member to its subscription, or changes its share when the user
+// is a member already, and fills in the timestamps. It reports whether the
+// member was added. The subscription is locked while the shares are checked, so
+// concurrent calls cannot push them past 100% together. It returns
+// domain.ErrNotFound when no such subscription exists, domain.ErrMemberIsOwner
+// when the user owns it and domain.ErrSharesExceeded when the shares would add
+// up to more than 100%.
+func (r *SubscriptionRepository) SetMember(ctx context.Context, member *model.SubscriptionMember) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repository.SetMember: %w", err)
+	}
+
+	var added bool
+	err = withTx(ctx, r.db, func(ctx context.Context) error {
+		psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+		query, args, err := psql.Select("user_id").
+			From("subscriptions").
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"id": member.SubscriptionID}).
+			Where(notDeleted).
+			Suffix("FOR UPDATE").
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		var owner uuid.UUID
+		if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&owner); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrNotFound
+			}
+			return err
+		}
+		if owner == member.UserID {
+			return domain.ErrMemberIsOwner
+		}
+
+		query, args, err = psql.Select("COALESCE(SUM(share_percent), 0)").
+			From("subscription_members").
+			Where(squirrel.Eq{"subscription_id": member.SubscriptionID}).
+			Where(squirrel.NotEq{"user_id": member.UserID}).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		var others int
+		if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&others); err != nil {
+			return err
+		}
+		if others+member.SharePercent > 100 {
+			return domain.ErrSharesExceeded
+		}
+
+		query, args, err = psql.Insert("subscription_members").
+			Columns("subscription_id", "user_id", "tenant_id", "share_percent").
+			Values(member.SubscriptionID, member.UserID, tenant, member.SharePercent).
+			Suffix(`ON CONFLICT (subscription_id, user_id) DO UPDATE SET
+				share_percent = EXCLUDED.share_percent,
+				updated_at = now()
+				RETURNING created_at, updated_at, xmax = 0`).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+		return r.conn(ctx).QueryRow(ctx, query, args...).Scan(&member.CreatedAt, &member.UpdatedAt, &added)
+	})
+	if err != nil {
+		return false, fmt.Errorf("repository.SetMember: %w", err)
+	}
+	return added, nil
+}
+
+// RemoveMember removes userID from the members of the subscription id. It
+// returns domain.ErrNotFound when userID is not a member.
+func (r *SubscriptionRepository) RemoveMember(ctx context.Context, id, userID uuid.UUID) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("subscription_members").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"subscription_id": id, "user_id": userID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.RemoveMember: failed to build query: %w", err)
+	}
+
+	tag, err := r.conn(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("repository.RemoveMember: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("repository.RemoveMember: %w", domain.ErrNotFound)
+	}
+	return nil
+}
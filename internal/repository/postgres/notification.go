@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetNotificationPreferences returns the preferences stored for userID or
+// domain.ErrNotFound.
+func (r *SubscriptionRepository) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("user_id", "remind_before_renewal_days", "email_enabled", "webhook_url", "created_at", "updated_at").
+		From("notification_preferences").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetNotificationPreferences: failed to build query: %w", err)
+	}
+
+	prefs := &model.NotificationPreferences{}
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&prefs.UserID, &prefs.RemindBeforeRenewalDays, &prefs.EmailEnabled, &prefs.WebhookURL, &prefs.CreatedAt, &prefs.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("repository.GetNotificationPreferences: %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("repository.GetNotificationPreferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreferences stores prefs, replacing the preferences of
+// its user if they have any, and fills in the timestamps. It reports
+// whether the preferences were created.
+func (r *SubscriptionRepository) SetNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repository.SetNotificationPreferences: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("notification_preferences").
+		Columns("tenant_id", "user_id", "remind_before_renewal_days", "email_enabled", "webhook_url").
+		Values(tenant, prefs.UserID, prefs.RemindBeforeRenewalDays, prefs.EmailEnabled, prefs.WebhookURL).
+		Suffix(`ON CONFLICT (tenant_id, user_id) DO UPDATE SET
+			remind_before_renewal_days = EXCLUDED.remind_before_renewal_days,
+			email_enabled = EXCLUDED.email_enabled,
+			webhook_url = EXCLUDED.webhook_url,
+			updated_at = now()
+			RETURNING created_at, updated_at, xmax = 0`).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("repository.SetNotificationPreferences: failed to build query: %w", err)
+	}
+
+	var created bool
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&prefs.CreatedAt, &prefs.UpdatedAt, &created); err != nil {
+		return false, fmt.Errorf("repository.SetNotificationPreferences: %w", err)
+	}
+	return created, nil
+}
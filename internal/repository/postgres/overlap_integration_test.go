@@ -0,0 +1,81 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFindOverlapping(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	ptr := func(m model.MonthYear) *model.MonthYear { return &m }
+
+	tests := []struct {
+		name        string
+		serviceName string
+		otherUser   bool
+		start       model.MonthYear
+		end         *model.MonthYear
+		deleted     bool // the existing subscription is deleted first
+		want        bool
+	}{
+		{name: "inside", serviceName: "Netflix", start: month(2024, 3), end: ptr(month(2024, 4)), want: true},
+		{name: "starting in the last month", serviceName: "Netflix", start: month(2024, 6), want: true},
+		{name: "starting after", serviceName: "Netflix", start: month(2024, 7), want: false},
+		{name: "ending in the first month", serviceName: "Netflix", start: month(2023, 1), end: ptr(month(2024, 1)), want: true},
+		{name: "ending before", serviceName: "Netflix", start: month(2023, 1), end: ptr(month(2023, 12)), want: false},
+		{name: "open-ended from before", serviceName: "Netflix", start: month(2020, 1), want: true},
+		{name: "service named in another case", serviceName: "NETFLIX", start: month(2024, 3), want: true},
+		{name: "another service", serviceName: "Spotify", start: month(2024, 3), want: false},
+		{name: "another user", serviceName: "Netflix", otherUser: true, start: month(2024, 3), want: false},
+		{name: "deleted", serviceName: "Netflix", start: month(2024, 3), deleted: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &model.Subscription{ServiceName: "Netflix", PriceMinor: 500, UserID: uuid.New(), StartDate: month(2024, 1), EndDate: ptr(month(2024, 6))}
+			if err := repo.EnsureUser(ctx, existing.UserID); err != nil {
+				t.Fatalf("EnsureUser() error = %v", err)
+			}
+			if err := repo.Create(ctx, existing); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if tt.deleted {
+				if _, err := repo.Delete(ctx, existing.ID); err != nil {
+					t.Fatalf("Delete() error = %v", err)
+				}
+			}
+
+			sub := &model.Subscription{ServiceName: tt.serviceName, UserID: existing.UserID, StartDate: tt.start, EndDate: tt.end}
+			if tt.otherUser {
+				sub.UserID = uuid.New()
+			}
+			ids, err := repo.FindOverlapping(ctx, sub)
+			if err != nil {
+				t.Fatalf("FindOverlapping() error = %v", err)
+			}
+			if got := len(ids) == 1 && ids[0] == existing.ID; got != tt.want || len(ids) > 1 {
+				t.Errorf("FindOverlapping() = %v, want overlap with %s: %t", ids, existing.ID, tt.want)
+			}
+		})
+	}
+
+	t.Run("itself", func(t *testing.T) {
+		sub := &model.Subscription{ServiceName: "Netflix", PriceMinor: 500, UserID: uuid.New(), StartDate: month(2024, 1)}
+		if err := repo.EnsureUser(ctx, sub.UserID); err != nil {
+			t.Fatalf("EnsureUser() error = %v", err)
+		}
+		if err := repo.Create(ctx, sub); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if ids, err := repo.FindOverlapping(ctx, sub); err != nil || len(ids) != 0 {
+			t.Errorf("FindOverlapping() of itself = %v, %v, want none", ids, err)
+		}
+	})
+}
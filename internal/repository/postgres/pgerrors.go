@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// pgInvalidTextRepresentation is the SQLSTATE of a value that does not
+	// parse as its type, such as a malformed UUID.
+	pgInvalidTextRepresentation = "22P02"
+	// pgQueryCanceled is the SQLSTATE of a statement cancelled by
+	// statement_timeout.
+	pgQueryCanceled = "57014"
+)
+
+// translate marks err with the typed error matching its SQLSTATE, keeping
+// the original in the chain so that checks for specific constraints still
+// see it. Errors without a SQLSTATE and codes without a typed error are
+// returned unchanged.
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	if err == nil || !errors.As(err, &pgErr) {
+		return err
+	}
+	var typed error
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		typed = domain.ErrConflict
+	case pgForeignKeyViolation:
+		typed = domain.ErrForeignKeyViolation
+	case pgInvalidTextRepresentation:
+		typed = domain.ErrInvalidInput
+	case pgQueryCanceled:
+		typed = domain.ErrTimeout
+	default:
+		return err
+	}
+	if errors.Is(err, typed) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", typed, err)
+}
+
+// costError returns domain.ErrCostOverflow for err when it reports a cost
+// aggregate that does not fit into bigint, and err otherwise. Only cost
+// queries use it: elsewhere the same SQLSTATE means a value out of range
+// for its column.
+func costError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgNumericValueOutOfRange {
+		return domain.ErrCostOverflow
+	}
+	return err
+}
+
+// translating wraps q so that every error it returns passes through
+// translate.
+type translating struct {
+	q querier
+}
+
+func (t translating) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.q.Begin(ctx)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return translatingTx{Tx: tx}, nil
+}
+
+func (t translating) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tag, err := t.q.Exec(ctx, sql, args...)
+	return tag, translate(err)
+}
+
+func (t translating) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := t.q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return translatingRows{Rows: rows}, nil
+}
+
+func (t translating) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return translatingRow{row: t.q.QueryRow(ctx, sql, args...)}
+}
+
+func (t translating) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return translatingBatch{BatchResults: t.q.SendBatch(ctx, b)}
+}
+
+func (t translating) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	n, err := t.q.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	return n, translate(err)
+}
+
+// translatingTx is a transaction whose errors pass through translate.
+type translatingTx struct {
+	pgx.Tx
+}
+
+func (t translatingTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return translating{q: t.Tx}.Begin(ctx)
+}
+
+func (t translatingTx) Commit(ctx context.Context) error {
+	return translate(t.Tx.Commit(ctx))
+}
+
+func (t translatingTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return translating{q: t.Tx}.Exec(ctx, sql, args...)
+}
+
+func (t translatingTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return translating{q: t.Tx}.Query(ctx, sql, args...)
+}
+
+func (t translatingTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return translating{q: t.Tx}.QueryRow(ctx, sql, args...)
+}
+
+func (t translatingTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return translating{q: t.Tx}.SendBatch(ctx, b)
+}
+
+func (t translatingTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return translating{q: t.Tx}.CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+type translatingRows struct {
+	pgx.Rows
+}
+
+func (r translatingRows) Err() error {
+	return translate(r.Rows.Err())
+}
+
+func (r translatingRows) Scan(dest ...any) error {
+	return translate(r.Rows.Scan(dest...))
+}
+
+type translatingRow struct {
+	row pgx.Row
+}
+
+func (r translatingRow) Scan(dest ...any) error {
+	return translate(r.row.Scan(dest...))
+}
+
+type translatingBatch struct {
+	pgx.BatchResults
+}
+
+func (b translatingBatch) Exec() (pgconn.CommandTag, error) {
+	tag, err := b.BatchResults.Exec()
+	return tag, translate(err)
+}
+
+func (b translatingBatch) Query() (pgx.Rows, error) {
+	rows, err := b.BatchResults.Query()
+	if err != nil {
+		return nil, translate(err)
+	}
+	return translatingRows{Rows: rows}, nil
+}
+
+func (b translatingBatch) QueryRow() pgx.Row {
+	return translatingRow{row: b.BatchResults.QueryRow()}
+}
+
+func (b translatingBatch) Close() error {
+	return translate(b.BatchResults.Close())
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: domain.ErrConflict},
+		{name: "foreign key violation", err: &pgconn.PgError{Code: "23503"}, want: domain.ErrForeignKeyViolation},
+		{name: "invalid text representation", err: &pgconn.PgError{Code: "22P02"}, want: domain.ErrInvalidInput},
+		{name: "query canceled", err: &pgconn.PgError{Code: "57014"}, want: domain.ErrTimeout},
+		{name: "wrapped", err: fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), want: domain.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := translate(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("translate() = %v, want %v", got, tt.want)
+			}
+			// Checks for specific constraints still see the original.
+			var pgErr *pgconn.PgError
+			if !errors.As(got, &pgErr) {
+				t.Errorf("translate() = %v, lost the *pgconn.PgError", got)
+			}
+		})
+	}
+}
+
+func TestTranslateUnchanged(t *testing.T) {
+	plain := errors.New("connection reset")
+	other := &pgconn.PgError{Code: "40001"}
+	marked := fmt.Errorf("%w: %w", domain.ErrConflict, &pgconn.PgError{Code: "23505"})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "nil", err: nil},
+		{name: "without a SQLSTATE", err: plain},
+		{name: "code without a typed error", err: other},
+		{name: "translated already", err: marked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := translate(tt.err); got != tt.err {
+				t.Errorf("translate() = %v, want %v unchanged", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestCostError(t *testing.T) {
+	plain := errors.New("connection reset")
+	outOfRange := &pgconn.PgError{Code: "22003"}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "bigint out of range", err: outOfRange, want: domain.ErrCostOverflow},
+		{name: "translated", err: translate(outOfRange), want: domain.ErrCostOverflow},
+		{name: "wrapped", err: fmt.Errorf("sum: %w", outOfRange), want: domain.ErrCostOverflow},
+		// Left unchanged.
+		{name: "other SQLSTATE", err: &pgconn.PgError{Code: "57014"}},
+		{name: "without a SQLSTATE", err: plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := costError(tt.err)
+			if tt.want == nil && got != tt.err {
+				t.Errorf("costError() = %v, want %v unchanged", got, tt.err)
+			}
+			if tt.want != nil && !errors.Is(got, tt.want) {
+				t.Errorf("costError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// quotaStatuses are the statuses of the subscriptions counted against the
+// quota of their user.
+var quotaStatuses = []string{model.StatusActive, model.StatusTrialing, model.StatusPaused}
+
+// CountActiveForQuota returns how many active subscriptions userID has and
+// how many they may have: the limit of the tenant when it sets one,
+// defaultLimit otherwise. It must run within a transaction; the user stays
+// locked against concurrent quota checks until the transaction ends, so
+// that concurrent creations cannot exceed the quota together.
+func (r *SubscriptionRepository) CountActiveForQuota(ctx context.Context, userID uuid.UUID, defaultLimit int) (int, int, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("repository.CountActiveForQuota: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("1").
+		From("users").
+		Where(squirrel.Eq{"tenant_id": tenant, "id": userID}).
+		Suffix("FOR NO KEY UPDATE").
+		ToSql()
+	if err != nil {
+		return 0, 0, fmt.Errorf("repository.CountActiveForQuota: failed to build query: %w", err)
+	}
+	// A user missing from the users table cannot own subscriptions, so
+	// there is nothing to lock.
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return 0, 0, fmt.Errorf("repository.CountActiveForQuota: %w", err)
+	}
+
+	count := squirrel.Select("COUNT(*)").
+		From("subscriptions").
+		Where(squirrel.Eq{"tenant_id": tenant, "user_id": userID}).
+		Where(squirrel.Eq{"status": quotaStatuses}).
+		Where(notDeleted)
+	limit := squirrel.Select("max_active_subscriptions_per_user").
+		From("tenant_quotas").
+		Where(squirrel.Eq{"tenant_id": tenant})
+	query, args, err = psql.Select().
+		Column(squirrel.Alias(count, "count")).
+		Column(squirrel.Expr("COALESCE((?), ?)", limit, defaultLimit)).
+		ToSql()
+	if err != nil {
+		return 0, 0, fmt.Errorf("repository.CountActiveForQuota: failed to build query: %w", err)
+	}
+
+	var active, max int
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&active, &max); err != nil {
+		return 0, 0, fmt.Errorf("repository.CountActiveForQuota: %w", err)
+	}
+	return active, max, nil
+}
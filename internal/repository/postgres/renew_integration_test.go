@@ -0,0 +1,77 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRenew(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	ptr := func(m model.MonthYear) *model.MonthYear { return &m }
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		start   model.MonthYear
+		end     *model.MonthYear
+		months  int
+		now     time.Time
+		wantEnd model.MonthYear
+	}{
+		{name: "from the end date", start: month(2024, 1), end: ptr(month(2025, 6)), months: 1, now: day(2025, time.March, 15), wantEnd: month(2025, 7)},
+		{name: "into the next year", start: month(2024, 1), end: ptr(month(2025, 12)), months: 1, now: day(2025, time.March, 15), wantEnd: month(2026, 1)},
+		{name: "by the maximum", start: month(2024, 1), end: ptr(month(2025, 6)), months: 60, now: day(2025, time.March, 15), wantEnd: month(2030, 6)},
+		{name: "open-ended on January 31", start: month(2024, 1), months: 1, now: day(2025, time.January, 31), wantEnd: month(2025, 2)},
+		{name: "open-ended on a leap day", start: month(2024, 1), months: 1, now: day(2024, time.February, 29), wantEnd: month(2024, 3)},
+		{name: "open-ended on December 31", start: month(2024, 1), months: 2, now: day(2024, time.December, 31), wantEnd: month(2025, 2)},
+		{name: "ended on August 31", start: month(2024, 1), end: ptr(month(2024, 3)), months: 1, now: day(2024, time.August, 31), wantEnd: month(2024, 9)},
+		{name: "not before the start", start: month(2026, 1), end: ptr(month(2026, 3)), months: 1, now: day(2025, time.March, 15), wantEnd: month(2026, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &model.Subscription{ServiceName: "Netflix", PriceMinor: 500, UserID: uuid.New(), StartDate: tt.start, EndDate: tt.end}
+			if err := repo.EnsureUser(ctx, sub.UserID); err != nil {
+				t.Fatalf("EnsureUser() error = %v", err)
+			}
+			if err := repo.Create(ctx, sub); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			renewed, err := repo.Renew(ctx, sub.ID, tt.months, tt.now)
+			if err != nil {
+				t.Fatalf("Renew() error = %v", err)
+			}
+			if renewed.EndDate == nil || renewed.EndDate.String() != tt.wantEnd.String() {
+				t.Errorf("end date = %v, want %s", renewed.EndDate, tt.wantEnd)
+			}
+		})
+	}
+
+	t.Run("renewals add up", func(t *testing.T) {
+		sub := createSubscription(t, ctx, repo)
+		now := day(2025, time.March, 15)
+		for range 2 {
+			if _, err := repo.Renew(ctx, sub.ID, 3, now); err != nil {
+				t.Fatalf("Renew() error = %v", err)
+			}
+		}
+		stored, err := repo.GetByID(ctx, sub.ID, false)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if want := month(2025, 9); stored.EndDate == nil || stored.EndDate.String() != want.String() {
+			t.Errorf("end date = %v, want %s", stored.EndDate, want)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"subscriptions-service/internal/config"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATEs of failures that a retry of the same statement may get past.
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgAdminShutdown        = "57P01"
+	pgCrashShutdown        = "57P02"
+	pgCannotConnectNow     = "57P03"
+	// pgConnectionExceptionClass is the class of SQLSTATEs Postgres uses
+	// for broken connections.
+	pgConnectionExceptionClass = "08"
+)
+
+// maxRetryDelay caps the backoff between two attempts.
+const maxRetryDelay = 2 * time.Second
+
+// retrier runs idempotent operations again when they fail with a transient
+// error, such as during a failover of the database.
+type retrier struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	log         *slog.Logger
+}
+
+func newRetrier(cfg config.RetryConfig, log *slog.Logger) retrier {
+	return retrier{maxAttempts: cfg.MaxAttempts, baseDelay: cfg.BaseDelay, log: log}
+}
+
+// retry runs fn until it succeeds, fails with an error that is not
+// transient, the attempts are used up or ctx is done, waiting twice as long
+// before every attempt. fn must be safe to run more than once: a read, or a
+// write guarded by a unique key. Within a transaction fn runs only once,
+// since a failed statement aborts the transaction and only all of it could
+// be retried.
+func retry[T any](ctx context.Context, rt retrier, op string, fn func() (T, error)) (T, error) {
+	if _, inTx := ctx.Value(txKey{}).(pgx.Tx); inTx || rt.maxAttempts <= 1 {
+		return fn()
+	}
+
+	delay := rt.baseDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= rt.maxAttempts || ctx.Err() != nil || !isTransient(err) {
+			return result, err
+		}
+
+		rt.log.Warn("retrying after transient database error", "op", op, "attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// isTransient reports whether err is a serialization failure, a deadlock or
+// the loss of the connection to the database.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgSerializationFailure, pgDeadlockDetected, pgAdminShutdown, pgCrashShutdown, pgCannotConnectNow:
+			return true
+		}
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == pgConnectionExceptionClass
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
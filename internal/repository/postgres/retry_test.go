@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// stubTx stands for an open transaction on the context.
+type stubTx struct {
+	pgx.Tx
+}
+
+func TestRetry(t *testing.T) {
+	rt := retrier{maxAttempts: 3, baseDelay: time.Millisecond, log: testLog}
+	inTx := context.WithValue(context.Background(), txKey{}, pgx.Tx(stubTx{}))
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		err          error
+		wantAttempts int
+	}{
+		{name: "serialization failure", ctx: context.Background(), err: &pgconn.PgError{Code: "40001"}, wantAttempts: 3},
+		{name: "deadlock", ctx: context.Background(), err: &pgconn.PgError{Code: "40P01"}, wantAttempts: 3},
+		{name: "connection failure", ctx: context.Background(), err: &pgconn.PgError{Code: "08006"}, wantAttempts: 3},
+		{name: "connection exception", ctx: context.Background(), err: &pgconn.PgError{Code: "08000"}, wantAttempts: 3},
+		{name: "unique violation", ctx: context.Background(), err: &pgconn.PgError{Code: "23505"}, wantAttempts: 1},
+		{name: "plain error", ctx: context.Background(), err: errors.New("boom"), wantAttempts: 1},
+		{name: "serialization failure within a transaction", ctx: inTx, err: &pgconn.PgError{Code: "40001"}, wantAttempts: 1},
+		{name: "connection failure within a transaction", ctx: inTx, err: &pgconn.PgError{Code: "08006"}, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			_, err := retry(tt.ctx, rt, "test", func() (struct{}, error) {
+				attempts++
+				return struct{}{}, tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("retry() error = %v, want %v", err, tt.err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetrySucceedsAfterTransientError(t *testing.T) {
+	rt := retrier{maxAttempts: 3, baseDelay: time.Millisecond, log: testLog}
+
+	attempts := 0
+	got, err := retry(context.Background(), rt, "test", func() (int, error) {
+		attempts++
+		if attempts == 1 {
+			return 0, &pgconn.PgError{Code: "40001"}
+		}
+		return 42, nil
+	})
+	if err != nil || got != 42 {
+		t.Fatalf("retry() = %d, %v, want 42, nil", got, err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
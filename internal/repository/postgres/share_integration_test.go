@@ -0,0 +1,73 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSharedCostSQL(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	tests := []struct {
+		name   string
+		price  int
+		shares []int
+	}{
+		{name: "thirds of 1000", price: 1000, shares: []int{33, 33}},
+		{name: "thirds of 1001", price: 1001, shares: []int{33, 33}},
+		{name: "thirds of 999", price: 999, shares: []int{33, 33}},
+		{name: "uneven thirds", price: 1099, shares: []int{34, 33}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := model.Subscription{ServiceName: "Service", PriceMinor: tt.price, Currency: "RUB", BillingPeriod: model.BillingMonthly, UserID: uuid.New(), StartDate: month(2024, 1)}
+			users := []uuid.UUID{sub.UserID}
+			for range tt.shares {
+				users = append(users, uuid.New())
+			}
+			for _, user := range users {
+				if err := repo.EnsureUser(ctx, user); err != nil {
+					t.Fatalf("EnsureUser() error = %v", err)
+				}
+			}
+			if err := repo.Create(ctx, &sub); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			for i, p := range tt.shares {
+				member := model.SubscriptionMember{SubscriptionID: sub.ID, UserID: users[i+1], SharePercent: p}
+				if _, err := repo.SetMember(ctx, &member); err != nil {
+					t.Fatalf("SetMember() error = %v", err)
+				}
+			}
+
+			from, to := month(2024, 1).Time(), month(2024, 1).Time()
+			var total int64
+			for i, user := range users {
+				totals, _, _, err := repo.GetTotalCostByCurrency(ctx, &user, "", sub.Currency, &from, &to, false, false)
+				if err != nil {
+					t.Fatalf("GetTotalCostByCurrency() error = %v", err)
+				}
+				part := totals[sub.Currency]
+				want := model.CostShare{Owned: i == 0, MemberShares: tt.shares}
+				if i > 0 {
+					want.SharePercent = tt.shares[i-1]
+				}
+				if part != want.Part(int64(tt.price)) {
+					t.Errorf("part of user %d = %d, want %d", i, part, want.Part(int64(tt.price)))
+				}
+				total += part
+			}
+			if total != int64(tt.price) {
+				t.Errorf("parts add up to %d, want %d", total, tt.price)
+			}
+		})
+	}
+}
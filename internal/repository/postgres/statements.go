@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// statementCache holds the SQL of queries whose text only depends on a few
+// flags, such as whether the context carries a tenant, and not on the
+// values they are run with. squirrel rebuilds the text on every ToSql,
+// which is wasted work on hot paths.
+type statementCache struct {
+	m sync.Map // string -> string
+}
+
+// statements caches the SQL of the hot, fixed-shape queries.
+var statements statementCache
+
+// get returns the SQL cached under key, building it on first use. The
+// arguments build returns are dropped: callers pass the values themselves,
+// in the order of the placeholders build produced.
+func (c *statementCache) get(key string, build func() (string, []any, error)) (string, error) {
+	if query, ok := c.m.Load(key); ok {
+		return query.(string), nil
+	}
+	query, _, err := build()
+	if err != nil {
+		return "", err
+	}
+	c.m.Store(key, query)
+	return query, nil
+}
+
+// scopedArgs returns the arguments of a cached query whose first condition
+// is tenantScope: the tenant, when the context carries one, then args.
+func scopedArgs(tenant uuid.UUID, scoped bool, args ...any) []any {
+	if !scoped {
+		return args
+	}
+	return append([]any{tenant}, args...)
+}
+
+// insertShape splits the values of an INSERT into a key naming the ones
+// written as SQL, such as DEFAULT, and the arguments of the others, in
+// order. Inserts with the same key share their text.
+func insertShape(values []any) (string, []any) {
+	var key strings.Builder
+	args := make([]any, 0, len(values))
+	for _, value := range values {
+		if _, ok := value.(squirrel.Sqlizer); ok {
+			key.WriteByte('s')
+			continue
+		}
+		key.WriteByte('v')
+		args = append(args, value)
+	}
+	return key.String(), args
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BenchmarkQueryExecMode runs GetByID under each of the pgx query exec
+// modes that database.query_exec_mode can select:
+//
+//	TEST_DATABASE_URL=postgres://... go test -tags integration -run '^$' -bench QueryExecMode ./internal/repository/postgres/
+func BenchmarkQueryExecMode(b *testing.B) {
+	url := testDatabaseURL(b)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	modes := []pgx.QueryExecMode{
+		pgx.QueryExecModeCacheStatement,
+		pgx.QueryExecModeCacheDescribe,
+		pgx.QueryExecModeDescribeExec,
+		pgx.QueryExecModeExec,
+		pgx.QueryExecModeSimpleProtocol,
+	}
+	for _, mode := range modes {
+		b.Run(mode.String(), func(b *testing.B) {
+			cfg, err := pgxpool.ParseConfig(url)
+			if err != nil {
+				b.Fatalf("failed to parse database URL: %v", err)
+			}
+			cfg.ConnConfig.DefaultQueryExecMode = mode
+			pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+			if err != nil {
+				b.Fatalf("failed to connect to database: %v", err)
+			}
+			defer pool.Close()
+
+			repo := NewSubscriptionRepository(pool, config.RetryConfig{}, testLog)
+			sub := model.Subscription{ServiceName: "Service", PriceMinor: 1000, Currency: "RUB", BillingPeriod: model.BillingMonthly, UserID: uuid.New(), StartDate: month(2024, 1)}
+			if err := repo.EnsureUser(ctx, sub.UserID); err != nil {
+				b.Fatalf("EnsureUser() error = %v", err)
+			}
+			if err := repo.Create(ctx, &sub); err != nil {
+				b.Fatalf("Create() error = %v", err)
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := repo.GetByID(ctx, sub.ID, false); err != nil {
+					b.Fatalf("GetByID() error = %v", err)
+				}
+			}
+		})
+	}
+}
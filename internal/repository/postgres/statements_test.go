@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// BenchmarkStatementCache compares building the query of GetByID with
+// squirrel on every call to taking it from a statementCache.
+func BenchmarkStatementCache(b *testing.B) {
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	id := uuid.New()
+	build := func() (string, []any, error) {
+		return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
+			Select(subscriptionColumns...).
+			From("subscriptions").
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"id": id}).
+			Where(notDeleted).
+			ToSql()
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, _, err := build(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		var cache statementCache
+		tenant, scoped, err := tenantFilter(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := cache.get("GetByID/true/false", build); err != nil {
+				b.Fatal(err)
+			}
+			_ = scopedArgs(tenant, scoped, id)
+		}
+	})
+}
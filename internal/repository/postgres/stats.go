@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// GetServiceStats aggregates the subscriptions priced in currency per
+// service name. With grouping.ByCatalog, subscriptions linked to a catalog
+// entry are grouped by that entry and reported under its current name
+// instead; unlinked ones are still grouped by their service name. With
+// grouping.ByPlan, every group is broken down further by plan,
+// subscriptions without a plan coming first.
+func (r *SubscriptionRepository) GetServiceStats(ctx context.Context, userID *uuid.UUID, currency string, grouping model.StatsGrouping) ([]model.ServiceStats, error) {
+	name, id := "s.service_name", "NULL::uuid"
+	groupBy := []string{"s.service_name"}
+	if grouping.ByCatalog {
+		name, id = "COALESCE(c.name, s.service_name)", "c.id"
+		groupBy = []string{"c.id", name}
+	}
+	plan := "NULL::text"
+	if grouping.ByPlan {
+		plan = "s.plan"
+		groupBy = append(groupBy, plan)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	queryBuilder := psql.Select(name+" AS name", id+" AS service_id", plan+" AS plan", "COUNT(*)", "SUM(s.price_minor)::bigint", "AVG(s.price_minor)::float8").
+		From("subscriptions s").
+		Where(tenantScopeOn(ctx, "s.tenant_id")).
+		Where(squirrel.Eq{"s.deleted_at": nil, "s.currency": currency}).
+		GroupBy(groupBy...).
+		OrderBy("name", "service_id", "plan NULLS FIRST")
+	if grouping.ByCatalog {
+		queryBuilder = queryBuilder.LeftJoin("services c ON c.id = s.service_id")
+	}
+
+	if userID != nil {
+		queryBuilder = queryBuilder.Where(squirrel.Eq{"s.user_id": *userID})
+	}
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetServiceStats: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetServiceStats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make([]model.ServiceStats, 0)
+	for rows.Next() {
+		var st model.ServiceStats
+		if err := rows.Scan(&st.ServiceName, &st.ServiceID, &st.Plan, &st.Count, &st.TotalPrice, &st.AvgPrice); err != nil {
+			return nil, fmt.Errorf("repository.GetServiceStats: row scan failed: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetServiceStats: %w", err)
+	}
+	return stats, nil
+}
+
+// payersJoin adds a row for every user paying a part of a subscription,
+// the owner and each member, in payer. owned and member_share are those
+// shareSQL reads.
+const payersJoin = `CROSS JOIN LATERAL (
+	SELECT subscriptions.user_id AS payer, true AS owned, 0 AS member_share
+	UNION ALL
+	SELECT m.user_id, false, m.share_percent FROM subscription_members m WHERE m.subscription_id = subscriptions.id
+) AS payers`
+
+// GetCostReport returns what every user pays in currency within [from, to],
+// ordered by user_id. Users are charged like GetTotalCostByCurrency charges
+// them: trial months are free, discounts and billing periods apply, and
+// members of a shared subscription pay their share while its owner pays the
+// rest. The aggregate is computed entirely in Postgres.
+func (r *SubscriptionRepository) GetCostReport(ctx context.Context, currency string, from, to time.Time, limit, offset int) ([]model.UserCost, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("repository.GetCostReport: %w", domain.ErrInvalidPagination)
+	}
+
+	conditions := append(totalCostConditions(ctx, nil, "", &from, &to), squirrel.Eq{"currency": currency})
+	billed := billedOffsetsQuery("subscriptions", conditions, &from, &to).
+		Columns("payer", "owned", "member_share").
+		Column("ARRAY(SELECT share_percent FROM subscription_members m WHERE m.subscription_id = subscriptions.id) AS shares").
+		JoinClause(payersJoin)
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("payer", "SUM("+shareSQL(costSQL(billedCostSQL))+")::bigint").
+		FromSelect(billed, "billed").
+		GroupBy("payer").
+		OrderBy("payer").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetCostReport: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetCostReport: %w", err)
+	}
+	defer rows.Close()
+
+	report := make([]model.UserCost, 0)
+	for rows.Next() {
+		var row model.UserCost
+		if err := rows.Scan(&row.UserID, &row.TotalCost); err != nil {
+			return nil, fmt.Errorf("repository.GetCostReport: row scan failed: %w", err)
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetCostReport: %w", costError(err))
+	}
+	return report, nil
+}
@@ -0,0 +1,64 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCostReportMatchesTotalCost(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	ptr := func(m model.MonthYear) *model.MonthYear { return &m }
+	percent := func(p int) *int { return &p }
+
+	owner, member := uuid.New(), uuid.New()
+	for _, user := range []uuid.UUID{owner, member} {
+		if err := repo.EnsureUser(ctx, user); err != nil {
+			t.Fatalf("EnsureUser() error = %v", err)
+		}
+	}
+	yearly := model.Subscription{ServiceName: "Yearly", PriceMinor: 12000, Currency: "RUB", BillingPeriod: model.BillingYearly, UserID: owner, StartDate: month(2023, 3)}
+	trial := model.Subscription{ServiceName: "Trial", PriceMinor: 1000, Currency: "RUB", BillingPeriod: model.BillingMonthly, UserID: member, StartDate: month(2024, 1),
+		TrialEndDate: ptr(month(2024, 2)), DiscountPercent: percent(50), DiscountUntil: ptr(month(2024, 3))}
+	// Subscriptions in other currencies are left out of the report.
+	usd := model.Subscription{ServiceName: "Dollars", PriceMinor: 700, Currency: "USD", BillingPeriod: model.BillingMonthly, UserID: owner, StartDate: month(2024, 1)}
+	for _, sub := range []*model.Subscription{&yearly, &trial, &usd} {
+		if err := repo.Create(ctx, sub); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if _, err := repo.SetMember(ctx, &model.SubscriptionMember{SubscriptionID: yearly.ID, UserID: member, SharePercent: 25}); err != nil {
+		t.Fatalf("SetMember() error = %v", err)
+	}
+
+	from, to := month(2024, 1).Time(), month(2024, 6).Time()
+	report, err := repo.GetCostReport(ctx, "RUB", from, to, 10, 0)
+	if err != nil {
+		t.Fatalf("GetCostReport() error = %v", err)
+	}
+
+	// The owner pays 3/4 of the yearly charge in March; the member the
+	// rest of it, plus March at half price and April through June in full.
+	want := map[uuid.UUID]int64{owner: 9000, member: 3000 + 500 + 3000}
+	if len(report) != len(want) {
+		t.Fatalf("GetCostReport() = %+v, want %d users", report, len(want))
+	}
+	for _, row := range report {
+		if row.TotalCost != want[row.UserID] {
+			t.Errorf("report of %s = %d, want %d", row.UserID, row.TotalCost, want[row.UserID])
+		}
+		totals, _, _, err := repo.GetTotalCostByCurrency(ctx, &row.UserID, "", "RUB", &from, &to, false, false)
+		if err != nil {
+			t.Fatalf("GetTotalCostByCurrency() error = %v", err)
+		}
+		if row.TotalCost != totals["RUB"] {
+			t.Errorf("report of %s = %d, total_cost = %d", row.UserID, row.TotalCost, totals["RUB"])
+		}
+	}
+}
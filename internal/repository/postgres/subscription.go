@@ -2,73 +2,482 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
 	"subscriptions-service/internal/model"
+	"subscriptions-service/internal/service"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ErrNotFound = errors.New("not found")
+const (
+	// pgNumericValueOutOfRange is the SQLSTATE Postgres reports when an
+	// aggregate does not fit into its result type.
+	pgNumericValueOutOfRange = "22003"
+	// pgUniqueViolation is the SQLSTATE of a unique constraint violation.
+	pgUniqueViolation = "23505"
+	// pgForeignKeyViolation is the SQLSTATE of a foreign key violation.
+	pgForeignKeyViolation = "23503"
+)
+
+const (
+	// activeSubscriptionIndex allows a single open-ended subscription per
+	// user and service.
+	activeSubscriptionIndex = "idx_subscriptions_active_user_service"
+	// startSubscriptionIndex allows a single subscription per user and
+	// service starting in a given month.
+	startSubscriptionIndex = "idx_subscriptions_user_service_start"
+	// subscriptionUserForeignKey ties every subscription to a row of users.
+	subscriptionUserForeignKey = "fk_subscriptions_user"
+)
+
+// conflictIndex returns the unique index err violates when it is one of
+// activeSubscriptionIndex and startSubscriptionIndex, and "" otherwise.
+func conflictIndex(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return ""
+	}
+	switch pgErr.ConstraintName {
+	case activeSubscriptionIndex, startSubscriptionIndex:
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// isUnknownUser reports whether err violates subscriptionUserForeignKey.
+func isUnknownUser(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation && pgErr.ConstraintName == subscriptionUserForeignKey
+}
+
+// isConflict reports whether err violates activeSubscriptionIndex or
+// startSubscriptionIndex.
+func isConflict(err error) bool {
+	return conflictIndex(err) != ""
+}
+
+// sameOwner matches the subscriptions of the user sub belongs to. Updates
+// may not carry the user, which is then taken from the stored row.
+func sameOwner(sub *model.Subscription) squirrel.Sqlizer {
+	if sub.UserID == uuid.Nil {
+		return squirrel.Expr("user_id = (SELECT user_id FROM subscriptions WHERE id = ?)", sub.ID)
+	}
+	return squirrel.Eq{"user_id": sub.UserID}
+}
+
+// conflict builds the domain.ConflictError for a write of sub that failed with
+// cause, a violation of activeSubscriptionIndex or startSubscriptionIndex,
+// by looking up the subscription it collides with.
+func (r *SubscriptionRepository) conflict(ctx context.Context, sub *model.Subscription, cause error) error {
+	var collides squirrel.Sqlizer = squirrel.And{
+		squirrel.Eq{"end_date": nil},
+		squirrel.NotEq{"status": model.StatusCancelled},
+	}
+	if conflictIndex(cause) == startSubscriptionIndex {
+		collides = squirrel.Eq{"start_date": sub.StartDate}
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("id").
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(sameOwner(sub)).
+		Where(collides).
+		Where(notDeleted).
+		Where(serviceNameEq(sub.ServiceName)).
+		Where(squirrel.NotEq{"id": sub.ID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	// A failed write aborts the transaction it ran in, so the lookup always
+	// goes through the pool.
+	conflict := &domain.ConflictError{}
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&conflict.ExistingID); err != nil {
+		// The conflicting row is gone already; report the conflict anyway.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrConflict
+		}
+		return err
+	}
+	return conflict
+}
+
+// billedEndExpr is the last month a subscription is billed for: end_date,
+// or the month it was cancelled in when that comes first. LEAST ignores
+// NULLs, so the result is NULL only for open-ended subscriptions. It
+// mirrors model.Subscription.BilledThrough.
+const billedEndExpr = "LEAST(end_date, date_trunc('month', cancelled_at AT TIME ZONE 'UTC')::date)"
+
+// billedStartExpr is the first month a subscription is charged for: its
+// start month, or the month after its trial ends. GREATEST ignores NULLs,
+// so subscriptions without a trial start with start_date.
+const billedStartExpr = "GREATEST(start_date, (trial_end_date + interval '1 month')::date)"
+
+// notDeleted hides soft-deleted subscriptions. Every query touching
+// existing rows applies it, except HardDelete.
+var notDeleted = squirrel.Eq{"deleted_at": nil}
+
+var subscriptionColumns = []string{"id", "service_name", "price_minor", "currency", "billing_period", "user_id", "start_date", "end_date", "created_at", "updated_at", "deleted_at", "version", "status", "cancelled_at", "trial_end_date", "discount_percent", "discount_until", "metadata", "notes", "service_id", "plan", "auto_renew", "anonymized_at"}
+
+// insertColumns are the columns written when a subscription is created.
+var insertColumns = []string{"tenant_id", "service_name", "price_minor", "currency", "billing_period", "user_id", "start_date", "end_date", "status", "trial_end_date", "discount_percent", "discount_until", "metadata", "notes", "service_id", "plan", "auto_renew"}
+
+// insertValues returns the values of sub for insertColumns, stored for
+// tenant. Subscriptions without a status start out active; without a
+// currency or billing period they get the column default.
+func insertValues(tenant uuid.UUID, sub *model.Subscription) []any {
+	status := sub.Status
+	if status == "" {
+		status = model.StatusActive
+	}
+	orDefault := func(value string) any {
+		if value == "" {
+			return squirrel.Expr("DEFAULT")
+		}
+		return value
+	}
+	return []any{tenant, sub.ServiceName, sub.PriceMinor, orDefault(sub.Currency), orDefault(sub.BillingPeriod), sub.UserID, sub.StartDate, sub.EndDate, status, sub.TrialEndDate, sub.DiscountPercent, sub.DiscountUntil, metadataValue(sub.Metadata), sub.Notes, sub.ServiceID, sub.Plan, sub.AutoRenew}
+}
+
+// metadataValue returns the value stored for metadata. Subscriptions
+// without metadata store an empty object rather than a JSON null.
+func metadataValue(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return map[string]string{}
+	}
+	return metadata
+}
+
+// scanSubscription scans a row selected with subscriptionColumns.
+func scanSubscription(row pgx.Row, sub *model.Subscription) error {
+	return row.Scan(subscriptionFields(sub)...)
+}
+
+// subscriptionFields returns the scan targets in sub for
+// subscriptionColumns, for queries selecting more columns after them.
+func subscriptionFields(sub *model.Subscription) []any {
+	return []any{&sub.ID, &sub.ServiceName, &sub.PriceMinor, &sub.Currency, &sub.BillingPeriod, &sub.UserID, &sub.StartDate, &sub.EndDate, &sub.CreatedAt, &sub.UpdatedAt, &sub.DeletedAt, &sub.Version, &sub.Status, &sub.CancelledAt, &sub.TrialEndDate, &sub.DiscountPercent, &sub.DiscountUntil, &sub.Metadata, &sub.Notes, &sub.ServiceID, &sub.Plan, &sub.AutoRenew, &sub.AnonymizedAt}
+}
 
 type SubscriptionRepository struct {
-	db  *pgxpool.Pool
-	log *slog.Logger
+	db    *pgxpool.Pool
+	log   *slog.Logger
+	retry retrier
+}
+
+// SubscriptionRepository serves both sides of the service's storage.
+var (
+	_ service.SubscriptionReader = (*SubscriptionRepository)(nil)
+	_ service.SubscriptionWriter = (*SubscriptionRepository)(nil)
+)
+
+func NewSubscriptionRepository(db *pgxpool.Pool, retry config.RetryConfig, log *slog.Logger) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db, log: log, retry: newRetrier(retry, log)}
 }
 
-func NewSubscriptionRepository(db *pgxpool.Pool, log *slog.Logger) *SubscriptionRepository {
-	return &SubscriptionRepository{db: db, log: log}
+// conn returns the transaction ctx carries, or the pool outside of WithTx.
+func (r *SubscriptionRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
 }
 
-func (r *SubscriptionRepository) Create(ctx context.Context, sub *model.Subscription) (uuid.UUID, error) {
+// WithTx runs fn in a single transaction. Calls made with the context
+// passed to fn, on this repository or any other one sharing the pool, are
+// committed together when fn succeeds and rolled back when it fails.
+func (r *SubscriptionRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, r.db, fn)
+}
+
+// Create inserts sub and overwrites it with the stored row, so that it
+// carries the ID, timestamps and defaults set by the database.
+func (r *SubscriptionRepository) Create(ctx context.Context, sub *model.Subscription) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.Create: %w", err)
+	}
+
+	values := insertValues(tenant, sub)
+	shape, args := insertShape(values)
+	query, err := statements.get("Create/"+shape, func() (string, []any, error) {
+		psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+		return psql.Insert("subscriptions").
+			Columns(insertColumns...).
+			Values(values...).
+			Suffix("RETURNING " + strings.Join(subscriptionColumns, ", ")).
+			ToSql()
+	})
+	if err != nil {
+		return fmt.Errorf("repository.Create: failed to build query: %w", err)
+	}
+
+	if err := scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), sub); err != nil {
+		switch {
+		case isConflict(err):
+			err = r.conflict(ctx, sub, err)
+		case isUnknownUser(err):
+			err = domain.ErrUnknownUser
+		}
+		return fmt.Errorf("repository.Create: %w", err)
+	}
+	return nil
+}
+
+// CreateIfNotExists inserts sub unless the user already has a subscription
+// to the same service starting in the same month, in which case sub is
+// overwritten with the stored one. It reports whether sub was inserted.
+func (r *SubscriptionRepository) CreateIfNotExists(ctx context.Context, sub *model.Subscription) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repository.CreateIfNotExists: %w", err)
+	}
+
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 	query, args, err := psql.Insert("subscriptions").
-		Columns("service_name", "price", "user_id", "start_date", "end_date").
-		Values(sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate).
-		Suffix("RETURNING id").
+		Columns(insertColumns...).
+		Values(insertValues(tenant, sub)...).
+		Suffix("ON CONFLICT (tenant_id, user_id, LOWER(service_name), start_date) WHERE deleted_at IS NULL DO NOTHING RETURNING " + strings.Join(subscriptionColumns, ", ")).
 		ToSql()
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("repository.Create: failed to build query: %w", err)
+		return false, fmt.Errorf("repository.CreateIfNotExists: failed to build query: %w", err)
+	}
+
+	// The conflict target makes a repeated insert a no-op, so it is retried.
+	_, err = retry(ctx, r.retry, "repository.CreateIfNotExists", func() (struct{}, error) {
+		return struct{}{}, scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), sub)
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case isConflict(err):
+		return false, fmt.Errorf("repository.CreateIfNotExists: %w", r.conflict(ctx, sub, err))
+	case isUnknownUser(err):
+		return false, fmt.Errorf("repository.CreateIfNotExists: %w", domain.ErrUnknownUser)
+	case !errors.Is(err, pgx.ErrNoRows):
+		return false, fmt.Errorf("repository.CreateIfNotExists: %w", err)
 	}
 
-	var id uuid.UUID
-	err = r.db.QueryRow(ctx, query, args...).Scan(&id)
+	// Nothing was inserted. The statement below runs with a fresh snapshot
+	// and therefore sees the row that was conflicted on.
+	existing, err := r.GetEquivalent(ctx, sub)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("repository.Create: %w", err)
+		return false, fmt.Errorf("repository.CreateIfNotExists: %w", err)
 	}
-	return id, nil
+	*sub = *existing
+	return false, nil
 }
 
-func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
-	r.log.Info("repository: getting subscription by id", "id", id.String())
+// GetEquivalent returns the subscription of sub's user to the same service
+// starting in the same month.
+func (r *SubscriptionRepository) GetEquivalent(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-	query, args, err := psql.Select("id", "service_name", "price", "user_id", "start_date", "end_date").
+	query, args, err := psql.Select(subscriptionColumns...).
 		From("subscriptions").
-		Where(squirrel.Eq{"id": id}).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": sub.UserID, "start_date": sub.StartDate}).
+		Where(serviceNameEq(sub.ServiceName)).
+		Where(notDeleted).
 		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetEquivalent: failed to build query: %w", err)
+	}
+
+	var existing model.Subscription
+	if err := scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), &existing); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("repository.GetEquivalent: %w", err)
+	}
+	return &existing, nil
+}
+
+// GetByID returns the subscription with the given ID. Soft-deleted
+// subscriptions are only returned with includeDeleted.
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*model.Subscription, error) {
+	r.log.Info("repository: getting subscription by id", "id", id.String())
+	tenant, scoped, err := tenantFilter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetByID: %w", err)
+	}
+	query, err := statements.get(fmt.Sprintf("GetByID/%t/%t", scoped, includeDeleted), func() (string, []any, error) {
+		psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+		queryBuilder := psql.Select(subscriptionColumns...).
+			From("subscriptions").
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"id": id})
+		if !includeDeleted {
+			queryBuilder = queryBuilder.Where(notDeleted)
+		}
+		return queryBuilder.ToSql()
+	})
 	if err != nil {
 		return nil, fmt.Errorf("repository.GetByID: failed to build query: %w", err)
 	}
 
-	sub := &model.Subscription{}
-	err = r.db.QueryRow(ctx, query, args...).Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate)
+	sub, err := retry(ctx, r.retry, "repository.GetByID", func() (*model.Subscription, error) {
+		sub := &model.Subscription{}
+		return sub, scanSubscription(r.conn(ctx).QueryRow(ctx, query, scopedArgs(tenant, scoped, id)...), sub)
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrNotFound
+			return nil, domain.ErrNotFound
 		}
 		return nil, fmt.Errorf("repository.GetByID: %w", err)
 	}
 	return sub, nil
 }
 
-func (r *SubscriptionRepository) List(ctx context.Context, limit, offset int) ([]model.Subscription, error) {
+// GetForUpdate returns the live subscription with the given ID like GetByID
+// and locks its row until the end of the transaction, so that concurrent
+// read-modify-write cycles on it run one after the other. Called outside of
+// WithTx, the lock is released as soon as the row is read.
+func (r *SubscriptionRepository) GetForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	r.log.Info("repository: locking subscription", "id", id.String())
+	tenant, scoped, err := tenantFilter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetForUpdate: %w", err)
+	}
+	query, err := statements.get(fmt.Sprintf("GetForUpdate/%t", scoped), func() (string, []any, error) {
+		psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+		return psql.Select(subscriptionColumns...).
+			From("subscriptions").
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"id": id}).
+			Where(notDeleted).
+			Suffix("FOR UPDATE").
+			ToSql()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetForUpdate: failed to build query: %w", err)
+	}
+
+	sub := &model.Subscription{}
+	if err := scanSubscription(r.conn(ctx).QueryRow(ctx, query, scopedArgs(tenant, scoped, id)...), sub); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, fmt.Errorf("repository.GetForUpdate: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *SubscriptionRepository) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	tenant, scoped, err := tenantFilter(ctx)
+	if err != nil {
+		return false, fmt.Errorf("repository.Exists: %w", err)
+	}
+	query, err := statements.get(fmt.Sprintf("Exists/%t", scoped), func() (string, []any, error) {
+		psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+		return psql.Select("1").
+			Prefix("SELECT EXISTS (").
+			From("subscriptions").
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"id": id}).
+			Where(notDeleted).
+			Suffix(")").
+			ToSql()
+	})
+	if err != nil {
+		return false, fmt.Errorf("repository.Exists: failed to build query: %w", err)
+	}
+
+	exists, err := retry(ctx, r.retry, "repository.Exists", func() (bool, error) {
+		var exists bool
+		return exists, r.conn(ctx).QueryRow(ctx, query, scopedArgs(tenant, scoped, id)...).Scan(&exists)
+	})
+	if err != nil {
+		return false, fmt.Errorf("repository.Exists: %w", err)
+	}
+	return exists, nil
+}
+
+// GetByIDs returns the live subscriptions with the given IDs, in no
+// particular order. IDs matching none are left out.
+func (r *SubscriptionRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Subscription, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select(subscriptionColumns...).
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": ids}).
+		Where(notDeleted).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetByIDs: failed to build query: %w", err)
+	}
+
+	return retry(ctx, r.retry, "repository.GetByIDs", func() ([]model.Subscription, error) {
+		rows, err := r.conn(ctx).Query(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("repository.GetByIDs: %w", err)
+		}
+		defer rows.Close()
+
+		var subs []model.Subscription
+		for rows.Next() {
+			var sub model.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return nil, fmt.Errorf("repository.GetByIDs: row scan failed: %w", err)
+			}
+			subs = append(subs, sub)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("repository.GetByIDs: %w", err)
+		}
+		return subs, nil
+	})
+}
+
+func (r *SubscriptionRepository) List(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error) {
+	// Guard the uint64 conversions below: a negative value would wrap
+	// around into a huge LIMIT/OFFSET that Postgres rejects.
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("repository.List: %w", domain.ErrInvalidPagination)
+	}
+
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-	query, args, err := psql.Select("id", "service_name", "price", "user_id", "start_date", "end_date").
+	queryBuilder := psql.Select(subscriptionColumns...).
 		From("subscriptions").
+		Where(tenantScope(ctx))
+
+	if !filter.IncludeDeleted {
+		queryBuilder = queryBuilder.Where(notDeleted)
+	}
+	if len(filter.UserIDs) > 0 {
+		queryBuilder = queryBuilder.Where(squirrel.Eq{"user_id": filter.UserIDs})
+	}
+	if filter.ServiceName != "" {
+		queryBuilder = queryBuilder.Where(serviceNameEq(filter.ServiceName))
+	}
+	if filter.Status != "" {
+		queryBuilder = queryBuilder.Where(squirrel.Eq{"status": filter.Status})
+	}
+	if filter.Plan != "" {
+		queryBuilder = queryBuilder.Where(squirrel.Expr("LOWER(plan) = LOWER(?)", filter.Plan))
+	}
+	if len(filter.Metadata) > 0 {
+		metadata, err := json.Marshal(filter.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("repository.List: failed to encode metadata filter: %w", err)
+		}
+		queryBuilder = queryBuilder.Where("metadata @> ?::jsonb", string(metadata))
+	}
+
+	query, args, err := queryBuilder.
+		OrderBy("created_at", "id").
 		Limit(uint64(limit)).
 		Offset(uint64(offset)).
 		ToSql()
@@ -76,100 +485,1028 @@ func (r *SubscriptionRepository) List(ctx context.Context, limit, offset int) ([
 		return nil, fmt.Errorf("repository.List: failed to build query: %w", err)
 	}
 
-	rows, err := r.db.Query(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("repository.List: %w", err)
-	}
-	defer rows.Close()
+	return retry(ctx, r.retry, "repository.List", func() ([]model.Subscription, error) {
+		rows, err := r.conn(ctx).Query(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("repository.List: %w", err)
+		}
+		defer rows.Close()
 
-	var subs []model.Subscription
-	for rows.Next() {
-		var sub model.Subscription
-		if err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate); err != nil {
-			return nil, fmt.Errorf("repository.List: row scan failed: %w", err)
+		subs := make([]model.Subscription, 0)
+		for rows.Next() {
+			var sub model.Subscription
+			if err := scanSubscription(rows, &sub); err != nil {
+				return nil, fmt.Errorf("repository.List: row scan failed: %w", err)
+			}
+			subs = append(subs, sub)
 		}
-		subs = append(subs, sub)
-	}
-	return subs, nil
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("repository.List: %w", err)
+		}
+		return subs, nil
+	})
 }
 
+// Update overwrites the mutable fields of the subscription with sub.ID, bumps
+// its version and updated_at and refreshes sub with the stored row. The owning
+// user cannot be changed, and an empty sub.Currency, sub.BillingPeriod or
+// sub.Status keeps the stored one. A non-zero sub.Version is the version the
+// stored row must still have, otherwise domain.ErrVersionConflict is returned.
+// It returns domain.ErrNotFound when no such subscription exists.
 func (r *SubscriptionRepository) Update(ctx context.Context, sub *model.Subscription) error {
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-	query, args, err := psql.Update("subscriptions").
+	queryBuilder := psql.Update("subscriptions").
 		Set("service_name", sub.ServiceName).
-		Set("price", sub.Price).
-		Set("user_id", sub.UserID).
+		Set("service_id", sub.ServiceID).
+		Set("plan", sub.Plan).
+		Set("price_minor", sub.PriceMinor).
 		Set("start_date", sub.StartDate).
 		Set("end_date", sub.EndDate).
+		Set("auto_renew", sub.AutoRenew).
+		Set("trial_end_date", sub.TrialEndDate).
+		Set("discount_percent", sub.DiscountPercent).
+		Set("discount_until", sub.DiscountUntil).
+		Set("metadata", metadataValue(sub.Metadata)).
+		Set("notes", sub.Notes).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(tenantScope(ctx)).
 		Where(squirrel.Eq{"id": sub.ID}).
-		ToSql()
+		Where(notDeleted).
+		Suffix("RETURNING " + strings.Join(subscriptionColumns, ", "))
+	if sub.Currency != "" {
+		queryBuilder = queryBuilder.Set("currency", sub.Currency)
+	}
+	if sub.BillingPeriod != "" {
+		queryBuilder = queryBuilder.Set("billing_period", sub.BillingPeriod)
+	}
+	if sub.Status != "" {
+		queryBuilder = queryBuilder.Set("status", sub.Status)
+	}
+	if sub.Version != 0 {
+		queryBuilder = queryBuilder.Where(squirrel.Eq{"version": sub.Version})
+	}
+
+	query, args, err := queryBuilder.ToSql()
 	if err != nil {
 		return fmt.Errorf("repository.Update: failed to build query: %w", err)
 	}
 
-	_, err = r.db.Exec(ctx, query, args...)
-	if err != nil {
+	if err := scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), sub); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if sub.Version == 0 {
+				return fmt.Errorf("repository.Update: %w", domain.ErrNotFound)
+			}
+			// Tell a stale version apart from a missing subscription.
+			exists, err := r.Exists(ctx, sub.ID)
+			switch {
+			case err != nil:
+				return fmt.Errorf("repository.Update: %w", err)
+			case exists:
+				return fmt.Errorf("repository.Update: %w", domain.ErrVersionConflict)
+			default:
+				return fmt.Errorf("repository.Update: %w", domain.ErrNotFound)
+			}
+		}
+		switch {
+		case isConflict(err):
+			err = r.conflict(ctx, sub, err)
+		case isUnknownUser(err):
+			err = domain.ErrUnknownUser
+		}
 		return fmt.Errorf("repository.Update: %w", err)
 	}
 	return nil
 }
 
-func (r *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+// Delete soft-deletes the subscription by setting its deleted_at and returns
+// the ID of the user it belonged to. It returns domain.ErrNotFound when no such
+// subscription exists or it has been deleted already.
+func (r *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Update("subscriptions").
+		Set("deleted_at", squirrel.Expr("now()")).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		Where(notDeleted).
+		Suffix("RETURNING user_id").
+		ToSql()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("repository.Delete: failed to build query: %w", err)
+	}
+
+	var userID uuid.UUID
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("repository.Delete: %w", domain.ErrNotFound)
+		}
+		return uuid.Nil, fmt.Errorf("repository.Delete: %w", err)
+	}
+	return userID, nil
+}
+
+// PurgeDeletedBefore removes the subscriptions soft-deleted before cutoff
+// for good and returns how many were removed.
+func (r *SubscriptionRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 	query, args, err := psql.Delete("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Lt{"deleted_at": cutoff}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.PurgeDeletedBefore: failed to build query: %w", err)
+	}
+
+	tag, err := r.conn(ctx).Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("repository.PurgeDeletedBefore: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PurgeByUser removes every subscription of userID for good, soft-deleted,
+// archived or not, along with the shares userID has in the subscriptions
+// of others and the user itself, and returns how many subscriptions were
+// removed.
+func (r *SubscriptionRepository) PurgeByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("subscription_members").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.PurgeByUser: failed to build query: %w", err)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("repository.PurgeByUser: %w", err)
+	}
+
+	var purged int64
+	for _, table := range []string{"subscriptions", "subscriptions_archive"} {
+		query, args, err := psql.Delete(table).
+			Where(tenantScope(ctx)).
+			Where(squirrel.Eq{"user_id": userID}).
+			ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("repository.PurgeByUser: failed to build query: %w", err)
+		}
+
+		tag, err := r.conn(ctx).Exec(ctx, query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("repository.PurgeByUser: %w", err)
+		}
+		purged += tag.RowsAffected()
+	}
+
+	query, args, err = psql.Delete("users").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("repository.PurgeByUser: failed to build query: %w", err)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("repository.PurgeByUser: %w", err)
+	}
+	return purged, nil
+}
+
+// qualified returns columns prefixed with the table alias.
+func qualified(alias string, columns []string) []string {
+	out := make([]string, len(columns))
+	for i, column := range columns {
+		out[i] = alias + "." + column
+	}
+	return out
+}
+
+// updateChanges runs update, an UPDATE of subscriptions aliased s, on the
+// rows matching where and returns each of them as it was before and after.
+// The rows are locked before they are read, so that the old values are
+// the ones the update overwrote. Expressions set by update must qualify
+// the columns they refer to with s.
+func (r *SubscriptionRepository) updateChanges(ctx context.Context, update squirrel.UpdateBuilder, where ...squirrel.Sqlizer) ([]model.SubscriptionChange, error) {
+	locked := squirrel.Select(archiveColumns...).
+		From("subscriptions").
+		Suffix("FOR UPDATE")
+	for _, condition := range where {
+		locked = locked.Where(condition)
+	}
+
+	returning := append(qualified("old", archiveColumns), qualified("s", subscriptionColumns)...)
+	query, args, err := update.
+		FromSelect(locked, "old").
+		Where("s.id = old.id").
+		Suffix("RETURNING " + strings.Join(returning, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]model.SubscriptionChange, 0)
+	for rows.Next() {
+		change := model.SubscriptionChange{After: &model.Subscription{}}
+		fields := append([]any{&change.TenantID}, subscriptionFields(&change.Before)...)
+		if err := rows.Scan(append(fields, subscriptionFields(change.After)...)...); err != nil {
+			return nil, fmt.Errorf("row scan failed: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ActivateEndedTrials moves the trialing subscriptions whose trial ended
+// before the month of now to active and returns them as they were before
+// and after.
+func (r *SubscriptionRepository) ActivateEndedTrials(ctx context.Context, now time.Time) ([]model.SubscriptionChange, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	update := psql.Update("subscriptions AS s").
+		Set("status", model.StatusActive).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("s.version + 1"))
+
+	changes, err := r.updateChanges(ctx, update,
+		tenantScope(ctx),
+		squirrel.Eq{"status": model.StatusTrialing},
+		squirrel.Expr("trial_end_date < date_trunc('month', ?::date)", now),
+		notDeleted,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ActivateEndedTrials: %w", err)
+	}
+	return changes, nil
+}
+
+// ExpireEnded marks the subscriptions that do not renew automatically and
+// whose end date is before the month of now as expired and returns them
+// as they were before and after. Cancelled subscriptions are left alone.
+func (r *SubscriptionRepository) ExpireEnded(ctx context.Context, now time.Time) ([]model.SubscriptionChange, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	update := psql.Update("subscriptions AS s").
+		Set("status", model.StatusExpired).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("s.version + 1"))
+
+	changes, err := r.updateChanges(ctx, update,
+		tenantScope(ctx),
+		squirrel.Eq{"auto_renew": false},
+		squirrel.Eq{"status": []string{model.StatusActive, model.StatusTrialing, model.StatusPaused}},
+		squirrel.Expr("end_date < date_trunc('month', ?::date)", now),
+		notDeleted,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("repository.ExpireEnded: %w", err)
+	}
+	return changes, nil
+}
+
+// HardDelete removes the subscription row for good, whether or not it has
+// been soft-deleted. It returns domain.ErrNotFound when no such row exists.
+func (r *SubscriptionRepository) HardDelete(ctx context.Context, id uuid.UUID) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Delete("subscriptions").
+		Where(tenantScope(ctx)).
 		Where(squirrel.Eq{"id": id}).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("repository.Delete: failed to build query: %w", err)
+		return fmt.Errorf("repository.HardDelete: failed to build query: %w", err)
 	}
 
-	_, err = r.db.Exec(ctx, query, args...)
+	tag, err := r.conn(ctx).Exec(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("repository.Delete: %w", err)
+		return fmt.Errorf("repository.HardDelete: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("repository.HardDelete: %w", domain.ErrNotFound)
 	}
 	return nil
 }
 
-func (r *SubscriptionRepository) GetSubscriptionsForTotalCost(ctx context.Context, userID uuid.UUID, serviceName, startDate, endDate string) ([]model.Subscription, error) {
+// Cancel marks the subscription cancelled as of now. With setEndDate an
+// open-ended subscription also gets an end date: the month of now, or its
+// start month if it has not started yet. It returns the stored
+// subscription and whether it was changed; cancelling a cancelled
+// subscription changes nothing. It returns domain.ErrNotFound when no such
+// subscription exists.
+func (r *SubscriptionRepository) Cancel(ctx context.Context, id uuid.UUID, now time.Time, setEndDate bool) (*model.Subscription, bool, error) {
 	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-	queryBuilder := psql.Select("id", "service_name", "price", "user_id", "start_date", "end_date").
+	queryBuilder := psql.Update("subscriptions").
+		Set("status", model.StatusCancelled).
+		Set("cancelled_at", now).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"status": model.StatusCancelled}).
+		Where(notDeleted).
+		Suffix("RETURNING " + strings.Join(subscriptionColumns, ", "))
+	if setEndDate {
+		queryBuilder = queryBuilder.Set("end_date", squirrel.Expr(
+			"COALESCE(end_date, GREATEST(start_date, date_trunc('month', ?::date)::date))", now))
+	}
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, false, fmt.Errorf("repository.Cancel: failed to build query: %w", err)
+	}
+
+	sub := &model.Subscription{}
+	err = scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), sub)
+	if err == nil {
+		return sub, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("repository.Cancel: %w", err)
+	}
+
+	// Either there is no such subscription or it is cancelled already.
+	sub, err = r.GetByID(ctx, id, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("repository.Cancel: %w", err)
+	}
+	return sub, false, nil
+}
+
+// Renew extends the end date of the subscription by months months, counted from
+// its current end date, or from the month of now when it is open-ended or has
+// already ended. The new end date is never before the start month. The
+// extension is computed by the UPDATE itself, so concurrent renewals add up. It
+// returns the stored subscription, domain.ErrCancelled for a cancelled
+// subscription and domain.ErrNotFound when no such subscription exists.
+func (r *SubscriptionRepository) Renew(ctx context.Context, id uuid.UUID, months int, now time.Time) (*model.Subscription, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Update("subscriptions").
+		Set("end_date", squirrel.Expr(`GREATEST(
+			(GREATEST(COALESCE(end_date, date_trunc('month', ?::date)::date), date_trunc('month', ?::date)::date) + make_interval(months => ?::int))::date,
+			start_date)`, now, now, months)).
+		Set("status", squirrel.Expr("CASE WHEN status = ? THEN ? ELSE status END", model.StatusExpired, model.StatusActive)).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("version + 1")).
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.NotEq{"status": model.StatusCancelled}).
+		Where(notDeleted).
+		Suffix("RETURNING " + strings.Join(subscriptionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.Renew: failed to build query: %w", err)
+	}
+
+	sub := &model.Subscription{}
+	err = scanSubscription(r.conn(ctx).QueryRow(ctx, query, args...), sub)
+	if err == nil {
+		return sub, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("repository.Renew: %w", err)
+	}
+
+	// Either there is no such subscription or it is cancelled.
+	if _, err := r.GetByID(ctx, id, false); err != nil {
+		return nil, fmt.Errorf("repository.Renew: %w", err)
+	}
+	return nil, fmt.Errorf("repository.Renew: %w", domain.ErrCancelled)
+}
+
+// Reprice sets price on the subscriptions to serviceName priced in
+// currency whose price differs, restricted to userID when it is not nil and to subscriptions
+// billed in activeFrom's month or later when activeFrom is not nil. It
+// returns the subscriptions changed as they were before and after.
+func (r *SubscriptionRepository) Reprice(ctx context.Context, serviceName, currency string, userID *uuid.UUID, price int, activeFrom *time.Time) ([]model.SubscriptionChange, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	update := psql.Update("subscriptions AS s").
+		Set("price_minor", price).
+		Set("updated_at", squirrel.Expr("now()")).
+		Set("version", squirrel.Expr("s.version + 1"))
+
+	conditions := squirrel.And{
+		tenantScope(ctx),
+		serviceNameEq(serviceName),
+		squirrel.Eq{"currency": currency},
+		squirrel.NotEq{"price_minor": price},
+		notDeleted,
+	}
+	if userID != nil {
+		conditions = append(conditions, squirrel.Eq{"user_id": *userID})
+	}
+	if activeFrom != nil {
+		conditions = append(conditions, squirrel.Or{
+			squirrel.Expr(billedEndExpr + " IS NULL"),
+			squirrel.Expr(billedEndExpr+" >= date_trunc('month', ?::date)", *activeFrom),
+		})
+	}
+
+	changes, err := r.updateChanges(ctx, update, conditions)
+	if err != nil {
+		return nil, fmt.Errorf("repository.Reprice: %w", err)
+	}
+	return changes, nil
+}
+
+// deleteFilterConditions translates filter into WHERE conditions. It fails with
+// domain.ErrEmptyFilter unless the filter names a user, so that a bulk delete
+// never spans the whole table.
+func deleteFilterConditions(filter model.DeleteFilter) (squirrel.And, error) {
+	if filter.UserID == uuid.Nil {
+		return nil, domain.ErrEmptyFilter
+	}
+
+	conditions := squirrel.And{squirrel.Eq{"user_id": filter.UserID}, notDeleted}
+	if filter.ServiceName != "" {
+		conditions = append(conditions, serviceNameEq(filter.ServiceName))
+	}
+	if filter.StartFrom != nil {
+		conditions = append(conditions, squirrel.GtOrEq{"start_date": *filter.StartFrom})
+	}
+	if filter.StartTo != nil {
+		conditions = append(conditions, squirrel.LtOrEq{"start_date": *filter.StartTo})
+	}
+	return conditions, nil
+}
+
+// DeleteMatching soft-deletes every subscription matching filter and
+// returns them as they were before and after.
+func (r *SubscriptionRepository) DeleteMatching(ctx context.Context, filter model.DeleteFilter) ([]model.SubscriptionChange, error) {
+	conditions, err := deleteFilterConditions(filter)
+	if err != nil {
+		return nil, fmt.Errorf("repository.DeleteMatching: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	update := psql.Update("subscriptions AS s").
+		Set("deleted_at", squirrel.Expr("now()"))
+
+	changes, err := r.updateChanges(ctx, update, tenantScope(ctx), conditions)
+	if err != nil {
+		return nil, fmt.Errorf("repository.DeleteMatching: %w", err)
+	}
+	return changes, nil
+}
+
+// CountMatching returns how many subscriptions match filter along with the
+// IDs of at most sampleSize of them, earliest start first.
+func (r *SubscriptionRepository) CountMatching(ctx context.Context, filter model.DeleteFilter, sampleSize int) (int64, []uuid.UUID, error) {
+	conditions, err := deleteFilterConditions(filter)
+	if err != nil {
+		return 0, nil, fmt.Errorf("repository.CountMatching: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("id", "COUNT(*) OVER ()").
 		From("subscriptions").
-		Where(squirrel.Eq{"user_id": userID})
+		Where(tenantScope(ctx)).
+		Where(conditions).
+		OrderBy("start_date", "id").
+		Limit(uint64(sampleSize)).
+		ToSql()
+	if err != nil {
+		return 0, nil, fmt.Errorf("repository.CountMatching: failed to build query: %w", err)
+	}
 
-	if serviceName != "" {
-		queryBuilder = queryBuilder.Where(squirrel.Eq{"service_name": serviceName})
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("repository.CountMatching: %w", err)
+	}
+	defer rows.Close()
+
+	// The window count is evaluated before LIMIT, so every row carries the
+	// full number of matches.
+	var count int64
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id, &count); err != nil {
+			return 0, nil, fmt.Errorf("repository.CountMatching: row scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("repository.CountMatching: %w", err)
+	}
+	return count, ids, nil
+}
+
+// FindOverlapping returns the IDs of the other subscriptions of the same
+// user and service whose billing period shares at least one month with
+// sub's. Open-ended periods extend indefinitely.
+func (r *SubscriptionRepository) FindOverlapping(ctx context.Context, sub *model.Subscription) ([]uuid.UUID, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	queryBuilder := psql.Select("id").
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(sameOwner(sub)).
+		Where(serviceNameEq(sub.ServiceName)).
+		Where(squirrel.NotEq{"id": sub.ID}).
+		Where(notDeleted).
+		Where(squirrel.Or{
+			squirrel.Expr(billedEndExpr + " IS NULL"),
+			squirrel.Expr(billedEndExpr+" >= ?", sub.StartDate),
+		}).
+		OrderBy("start_date", "id")
+	if sub.EndDate != nil {
+		queryBuilder = queryBuilder.Where(squirrel.LtOrEq{"start_date": *sub.EndDate})
+	}
+
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.FindOverlapping: failed to build query: %w", err)
 	}
 
-	if startDate != "" {
-		queryBuilder = queryBuilder.Where(squirrel.GtOrEq{"start_date": startDate})
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository.FindOverlapping: %w", err)
 	}
+	defer rows.Close()
 
-	if endDate != "" {
-		queryBuilder = queryBuilder.Where(squirrel.Or{
-			squirrel.Eq{"end_date": nil},
-			squirrel.LtOrEq{"end_date": endDate},
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("repository.FindOverlapping: row scan failed: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.FindOverlapping: %w", err)
+	}
+	return ids, nil
+}
+
+// serviceNameEq matches service_name case-insensitively, so "Netflix" and
+// "NETFLIX" are treated as the same service. The expression is backed by
+// idx_subscriptions_user_id_lower_service_name.
+func serviceNameEq(serviceName string) squirrel.Sqlizer {
+	return squirrel.Expr("LOWER(service_name) = LOWER(?)", serviceName)
+}
+
+// totalCostConditions builds the filters shared by the total cost queries.
+// A subscription matches the period when it is active in at least one of
+// its months. A nil userID matches every user of the tenant on ctx.
+func totalCostConditions(ctx context.Context, userID *uuid.UUID, serviceName string, startDate, endDate *time.Time) squirrel.And {
+	conditions := squirrel.And{tenantScope(ctx), notDeleted}
+	if userID != nil {
+		conditions = append(conditions, squirrel.Eq{"user_id": *userID})
+	}
+
+	if serviceName != "" {
+		conditions = append(conditions, serviceNameEq(serviceName))
+	}
+
+	if startDate != nil {
+		conditions = append(conditions, squirrel.Or{
+			squirrel.Expr(billedEndExpr + " IS NULL"),
+			squirrel.Expr(billedEndExpr+" >= date_trunc('month', ?::date)", *startDate),
 		})
 	}
 
+	if endDate != nil {
+		conditions = append(conditions, squirrel.Expr("start_date < date_trunc('month', ?::date) + interval '1 month'", *endDate))
+	}
+
+	return conditions
+}
+
+// billedOffsetsQuery selects the subscriptions matching conditions along
+// with lo and hi, the offsets from the start month of the first and last
+// month they are billed for within the period. Trial months are not billed.
+// When startDate or endDate is set, only months inside that period are
+// considered. Open-ended subscriptions run until endDate, or until the
+// current month when no endDate is given. A subscription without billed
+// months in the period has hi < lo. The rows also carry discounted_price,
+// the price charged while the discount lasts, and dh, the offset of the
+// last discounted month, which is -1 for subscriptions without a discount.
+// The subscriptions are read from table, see subscriptionsTable.
+func billedOffsetsQuery(table string, conditions squirrel.Sqlizer, startDate, endDate *time.Time) squirrel.SelectBuilder {
+	lower, lowerArgs := billedStartExpr, []any(nil)
+	if startDate != nil {
+		lower, lowerArgs = "GREATEST("+billedStartExpr+", date_trunc('month', ?::date))", []any{*startDate}
+	}
+
+	upper, upperArgs := "COALESCE("+billedEndExpr+", date_trunc('month', now()))", []any(nil)
+	if endDate != nil {
+		upper, upperArgs = "LEAST(COALESCE("+billedEndExpr+", date_trunc('month', ?::date)), date_trunc('month', ?::date))", []any{*endDate, *endDate}
+	}
+
+	offset := func(month string, args []any) squirrel.Sqlizer {
+		return squirrel.Expr(fmt.Sprintf(`((date_part('year', %[1]s) - date_part('year', start_date)) * 12
+			+ date_part('month', %[1]s) - date_part('month', start_date))::int`, month), append(args, args...)...)
+	}
+
+	return squirrel.Select("currency", "price_minor", "billing_period", "start_date").
+		Column(squirrel.Alias(offset(lower, lowerArgs), "lo")).
+		Column(squirrel.Alias(offset(upper, upperArgs), "hi")).
+		Column(squirrel.Alias(squirrel.Expr("COALESCE("+discountedPriceExpr+", price_minor)"), "discounted_price")).
+		Column(squirrel.Alias(squirrel.Expr("COALESCE(?, -1)", offset("discount_until", nil)), "dh")).
+		From(table).
+		Where(conditions)
+}
+
+// discountedPriceExpr is the price charged while the discount lasts,
+// rounded half-up to a whole minor unit. It mirrors model.DiscountedPrice
+// and is NULL for subscriptions without a discount.
+const discountedPriceExpr = "(price_minor * (100 - discount_percent) + 50) / 100"
+
+// billedCostSQL computes what a row of billedOffsetsQuery is charged at
+// {price} for the months {lo} through {hi}. Monthly subscriptions are
+// charged every month, yearly ones on every 12-month anniversary of their
+// start month and weekly ones every 7 days from the first day of their
+// start month. It mirrors monthCost in the service layer.
+const billedCostSQL = `CASE WHEN {hi} < {lo} THEN 0 ELSE {price} * CASE billing_period
+	WHEN 'yearly' THEN ({hi} + 12) / 12 - ({lo} + 11) / 12
+	WHEN 'weekly' THEN ((start_date + make_interval(months => {hi} + 1))::date - start_date + 6) / 7
+		- ((start_date + make_interval(months => {lo}))::date - start_date + 6) / 7
+	ELSE {hi} - {lo} + 1
+END END`
+
+// amortizedCostSQL is billedCostSQL with yearly and weekly prices spread
+// evenly over the months, rounding down so that every 12 months add up to
+// the exact yearly amount.
+const amortizedCostSQL = `CASE WHEN {hi} < {lo} THEN 0 ELSE CASE billing_period
+	WHEN 'yearly' THEN {price} * ({hi} + 1) / 12 - {price} * {lo} / 12
+	WHEN 'weekly' THEN {price} * 52 * ({hi} + 1) / 12 - {price} * 52 * {lo} / 12
+	ELSE {price} * ({hi} - {lo} + 1)
+END END`
+
+// costSQL renders cost, billedCostSQL or amortizedCostSQL, for the billed
+// months lo through hi of a row of billedOffsetsQuery: the months up to and
+// including dh at the discounted price, the remaining ones at the list
+// price.
+func costSQL(cost string) string {
+	segment := func(price, lo, hi string) string {
+		return strings.NewReplacer("{price}", price, "{lo}", lo, "{hi}", hi).Replace(cost)
+	}
+	return "(" + segment("discounted_price", "lo", "LEAST(hi, dh)") + " + " + segment("price_minor", "GREATEST(lo, dh + 1)", "hi") + ")"
+}
+
+// sharedWith matches the subscriptions userID owns or is a member of.
+func sharedWith(userID uuid.UUID) squirrel.Sqlizer {
+	return squirrel.Or{
+		squirrel.Eq{"user_id": userID},
+		squirrel.Expr("id IN (SELECT subscription_id FROM subscription_members WHERE user_id = ?)", userID),
+	}
+}
+
+// shareSQL renders the part of cost, see costSQL, that falls to the user
+// of a row of userCostQuery. Members pay their share of the cost, rounded
+// down, and the owner pays the remainder, so that the parts always add up
+// to the full cost.
+func shareSQL(cost string) string {
+	return "CASE WHEN owned THEN " + cost + " - (SELECT COALESCE(SUM(" + cost + "::bigint * p / 100), 0) FROM unnest(shares) AS p)" +
+		" ELSE " + cost + "::bigint * member_share / 100 END"
+}
+
+// withShares adds the columns shareSQL reads to query, which selects from
+// subscriptions: owned, whether userID owns the row, member_share, the
+// share userID pays as a member, and shares, those of every member.
+func withShares(query squirrel.SelectBuilder, userID uuid.UUID) squirrel.SelectBuilder {
+	return query.
+		Column(squirrel.Alias(squirrel.Expr("user_id = ?", userID), "owned")).
+		Column(squirrel.Alias(squirrel.Expr("COALESCE((SELECT share_percent FROM subscription_members m WHERE m.subscription_id = subscriptions.id AND m.user_id = ?), 0)", userID), "member_share")).
+		Column("ARRAY(SELECT share_percent FROM subscription_members m WHERE m.subscription_id = subscriptions.id) AS shares")
+}
+
+// userCostQuery is billedOffsetsQuery for the total cost queries, along
+// with cost rendered for its rows. A nil userID covers every subscription
+// matching conditions at its full cost. Otherwise the subscriptions shared
+// with userID are covered as well, and cost is narrowed to the part of it
+// userID pays, see shareSQL. The rows then also tell whether userID owns
+// them in owned.
+func userCostQuery(table string, conditions squirrel.And, userID *uuid.UUID, cost string, from, to *time.Time) (squirrel.SelectBuilder, string) {
+	if userID == nil {
+		return billedOffsetsQuery(table, conditions, from, to), cost
+	}
+
+	conditions = append(conditions, sharedWith(*userID))
+	return withShares(billedOffsetsQuery(table, conditions, from, to), *userID), shareSQL(cost)
+}
+
+// GetTotalCostByCurrency sums what every matching subscription is charged
+// for the months it is billed for within the requested period, separately
+// for every currency the subscriptions are priced in, and counts the
+// subscriptions billed for at least one month. The aggregate runs in
+// Postgres. A nil userID aggregates across all users; for a user, their
+// share of the subscriptions shared with them is included. A non-empty
+// currency only considers subscriptions in that currency. With amortize,
+// yearly and weekly prices are spread evenly over the months. With
+// includeArchived, archived subscriptions are counted as well. It also
+// reports whether the total includes subscriptions shared with the user by
+// someone else.
+func (r *SubscriptionRepository) GetTotalCostByCurrency(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived bool) (map[string]int64, int, bool, error) {
+	conditions := totalCostConditions(ctx, nil, serviceName, from, to)
+	if currency != "" {
+		conditions = append(conditions, squirrel.Eq{"currency": currency})
+	}
+	cost := costSQL(billedCostSQL)
+	if amortize {
+		cost = costSQL(amortizedCostSQL)
+	}
+	billed, cost := userCostQuery(subscriptionsTable(includeArchived), conditions, userID, cost, from, to)
+	shared := "false"
+	if userID != nil {
+		shared = "COALESCE(bool_or(NOT owned), false)"
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("currency", "SUM("+cost+")::bigint", "COUNT(*) FILTER (WHERE hi >= lo)", shared).
+		FromSelect(billed, "billed").
+		GroupBy("currency").
+		ToSql()
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("repository.GetTotalCostByCurrency: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("repository.GetTotalCostByCurrency: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int64)
+	var counted int
+	var anyShared bool
+	for rows.Next() {
+		var code string
+		var total int64
+		var n int
+		var sharedRows bool
+		if err := rows.Scan(&code, &total, &n, &sharedRows); err != nil {
+			return nil, 0, false, fmt.Errorf("repository.GetTotalCostByCurrency: row scan failed: %w", err)
+		}
+		totals[code] = total
+		counted += n
+		anyShared = anyShared || sharedRows
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, false, fmt.Errorf("repository.GetTotalCostByCurrency: %w", costError(err))
+	}
+	return totals, counted, anyShared, nil
+}
+
+// GetSubscriptionsForTotalCost returns the subscriptions
+// GetTotalCostByCurrency would add up for the user, those shared with them
+// included, along with the part of their cost the user pays. A non-empty
+// currency only returns subscriptions in that currency.
+func (r *SubscriptionRepository) GetSubscriptionsForTotalCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time) ([]model.SharedSubscription, error) {
+	conditions := append(totalCostConditions(ctx, nil, serviceName, from, to), sharedWith(userID))
+	if currency != "" {
+		conditions = append(conditions, squirrel.Eq{"currency": currency})
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	queryBuilder := withShares(psql.Select(subscriptionColumns...), userID).
+		From("subscriptions").
+		Where(conditions)
+
 	query, args, err := queryBuilder.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("repository.GetTotalCost: failed to build query: %w", err)
+		return nil, fmt.Errorf("repository.GetSubscriptionsForTotalCost: failed to build query: %w", err)
 	}
 
-	rows, err := r.db.Query(ctx, query, args...)
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("repository.GetTotalCost: %w", err)
+		return nil, fmt.Errorf("repository.GetSubscriptionsForTotalCost: %w", err)
 	}
 	defer rows.Close()
 
-	var subs []model.Subscription
+	var subs []model.SharedSubscription
 	for rows.Next() {
-		var sub model.Subscription
-		if err := rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate); err != nil {
-			return nil, fmt.Errorf("repository.GetTotalCost: row scan failed: %w", err)
+		var sub model.SharedSubscription
+		if err := rows.Scan(append(subscriptionFields(&sub.Subscription), &sub.Share.Owned, &sub.Share.SharePercent, &sub.Share.MemberShares)...); err != nil {
+			return nil, fmt.Errorf("repository.GetSubscriptionsForTotalCost: row scan failed: %w", err)
 		}
 		subs = append(subs, sub)
 	}
-
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository.GetSubscriptionsForTotalCost: %w", err)
+	}
 	return subs, nil
 }
+
+// GetCostCells expands the subscriptions GetSubscriptionsForTotalCost
+// returns into their months with generate_series and sums what they are
+// charged per month, currency and service, entirely in Postgres. Months
+// are expanded from the start month, or from, through the month the
+// subscription is billed through; open-ended ones run until to, or until
+// the current month when no upper bound is given. Trial months are
+// expanded at no cost, so the cells match what monthCost computes for the
+// same months. It also counts the subscriptions with at least one month in
+// the period. With amortize, yearly and weekly prices are spread evenly
+// over the months. Subscriptions shared with the user only count with the
+// part the user pays, see shareSQL. The part is taken of the cost
+// accumulated up to every month, and the month is charged the growth of
+// that part, so that the months add up to what GetTotalCostByCurrency
+// reports.
+func (r *SubscriptionRepository) GetCostCells(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, amortize bool) ([]model.CostCell, int, error) {
+	conditions := append(totalCostConditions(ctx, nil, serviceName, from, to), sharedWith(userID))
+	if currency != "" {
+		conditions = append(conditions, squirrel.Eq{"currency": currency})
+	}
+
+	lower, lowerArgs := "start_date", []any(nil)
+	if from != nil {
+		lower, lowerArgs = "GREATEST(start_date, ?::date)", []any{*from}
+	}
+	upper, upperArgs := "COALESCE("+billedEndExpr+", now()::date)", []any(nil)
+	if to != nil {
+		upper, upperArgs = "LEAST(COALESCE("+billedEndExpr+", ?::date), ?::date)", []any{*to, *to}
+	}
+	series := squirrel.Expr(fmt.Sprintf("CROSS JOIN LATERAL generate_series(date_trunc('month', %s::timestamp), date_trunc('month', %s::timestamp), interval '1 month') AS m(month)", lower, upper),
+		append(lowerArgs, upperArgs...)...)
+	offset := func(month string) string {
+		return fmt.Sprintf("((date_part('year', %[1]s) - date_part('year', start_date)) * 12 + date_part('month', %[1]s) - date_part('month', start_date))::int", month)
+	}
+
+	// Every month becomes a row of its own, with lo and hi both set to its
+	// offset, so that costSQL prices exactly that month.
+	months := withShares(squirrel.Select("id", "currency", "service_name", "price_minor", "billing_period", "start_date", "trial_end_date", "m.month::date AS month").
+		Column(offset("m.month")+" AS lo").
+		Column(offset("m.month")+" AS hi").
+		Column("COALESCE("+discountedPriceExpr+", price_minor) AS discounted_price").
+		Column("COALESCE("+offset("discount_until")+", -1) AS dh"), userID).
+		From("subscriptions").
+		JoinClause(series).
+		Where(conditions)
+
+	cost := costSQL(billedCostSQL)
+	if amortize {
+		cost = costSQL(amortizedCostSQL)
+	}
+	cost = "CASE WHEN trial_end_date >= month THEN 0 ELSE " + cost + "::bigint END"
+
+	// cum is the cost of a subscription from the first month expanded
+	// through the month of the row, c the cost of that month alone.
+	costs := squirrel.Select("id", "month", "currency", "service_name", "owned", "member_share", "shares", cost+" AS c").
+		Column("SUM("+cost+") OVER (PARTITION BY id ORDER BY month)::bigint AS cum").
+		FromSelect(months, "months")
+
+	// The empty grouping set adds a row with the number of subscriptions
+	// expanded, told apart from the cells by GROUPING.
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("GROUPING(month) = 1", "month", "currency", "service_name", "COALESCE(SUM("+shareSQL("cum")+" - "+shareSQL("(cum - c)")+"), 0)::bigint", "COUNT(DISTINCT id)").
+		FromSelect(costs, "costs").
+		GroupBy("GROUPING SETS ((month, currency, service_name), ())").
+		ToSql()
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository.GetCostCells: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("repository.GetCostCells: %w", err)
+	}
+	defer rows.Close()
+
+	var cells []model.CostCell
+	var counted int
+	for rows.Next() {
+		var summary bool
+		var month *time.Time
+		var code, name *string
+		var cell model.CostCell
+		var n int
+		if err := rows.Scan(&summary, &month, &code, &name, &cell.Cost, &n); err != nil {
+			return nil, 0, fmt.Errorf("repository.GetCostCells: row scan failed: %w", err)
+		}
+		if summary {
+			counted = n
+			continue
+		}
+		cell.Month, cell.Currency, cell.ServiceName = *month, *code, *name
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("repository.GetCostCells: %w", costError(err))
+	}
+	return cells, counted, nil
+}
+
+// Export streams every subscription to fn one row at a time, so memory use
+// does not depend on the table size. Iteration stops at the first error
+// returned by fn or when ctx is cancelled.
+func (r *SubscriptionRepository) Export(ctx context.Context, fn func(sub model.Subscription) error) error {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select(subscriptionColumns...).
+		From("subscriptions").
+		Where(tenantScope(ctx)).
+		Where(notDeleted).
+		OrderBy("created_at", "id").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.Export: failed to build query: %w", err)
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("repository.Export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		// Rows already buffered are returned without looking at ctx.
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("repository.Export: %w", err)
+		}
+		var sub model.Subscription
+		if err := scanSubscription(rows, &sub); err != nil {
+			return fmt.Errorf("repository.Export: row scan failed: %w", err)
+		}
+		if err := fn(sub); err != nil {
+			return fmt.Errorf("repository.Export: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("repository.Export: %w", err)
+	}
+	return nil
+}
+
+// copyLinePattern extracts the line from the context Postgres reports for
+// errors raised while copying a row.
+var copyLinePattern = regexp.MustCompile(`COPY \S+, line (\d+)`)
+
+// copyIndex returns the index of the row err was raised for by a COPY, if
+// Postgres says. Errors of checks deferred to the end of the statement,
+// such as foreign keys, do not name a row.
+func copyIndex(err error) (int, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return 0, false
+	}
+	match := copyLinePattern.FindStringSubmatch(pgErr.Where)
+	if match == nil {
+		return 0, false
+	}
+	line, err := strconv.Atoi(match[1])
+	if err != nil || line < 1 {
+		return 0, false
+	}
+	return line - 1, true
+}
+
+// CreateMany inserts subs with a single COPY inside a transaction and returns
+// their IDs in the same order. The IDs are generated here, since COPY cannot
+// return them. When a row is rejected nothing is stored and the error is a
+// *domain.BulkItemError naming it, if Postgres tells which row it was. COPY
+// cannot fall back on column defaults row by row, so a missing billing period
+// is stored as monthly and every subscription must carry its currency.
+func (r *SubscriptionRepository) CreateMany(ctx context.Context, subs []model.Subscription) ([]uuid.UUID, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repository.CreateMany: %w", err)
+	}
+
+	ids := make([]uuid.UUID, len(subs))
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	rows := pgx.CopyFromSlice(len(subs), func(i int) ([]any, error) {
+		sub := subs[i]
+		if sub.Currency == "" {
+			return nil, &domain.BulkItemError{Index: i, Err: errors.New("currency is required")}
+		}
+		if sub.BillingPeriod == "" {
+			sub.BillingPeriod = model.BillingMonthly
+		}
+		return append([]any{ids[i]}, insertValues(tenant, &sub)...), nil
+	})
+
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repository.CreateMany: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"subscriptions"}, append([]string{"id"}, insertColumns...), rows); err != nil {
+		var itemErr *domain.BulkItemError
+		if errors.As(err, &itemErr) {
+			return nil, fmt.Errorf("repository.CreateMany: %w", itemErr)
+		}
+		index, ok := copyIndex(err)
+		switch {
+		case isConflict(err) && ok:
+			err = r.conflict(ctx, &subs[index], err)
+		case isConflict(err):
+			err = domain.ErrConflict
+		case isUnknownUser(err):
+			err = domain.ErrUnknownUser
+		}
+		if ok {
+			err = &domain.BulkItemError{Index: index, Err: err}
+		}
+		return nil, fmt.Errorf("repository.CreateMany: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("repository.CreateMany: failed to commit transaction: %w", err)
+	}
+	return ids, nil
+}
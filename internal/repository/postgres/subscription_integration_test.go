@@ -0,0 +1,231 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// createSubscription stores a subscription of a new user in the tenant of
+// ctx.
+func createSubscription(t *testing.T, ctx context.Context, repo *SubscriptionRepository) *model.Subscription {
+	t.Helper()
+	sub := &model.Subscription{ServiceName: "Netflix", PriceMinor: 500, UserID: uuid.New(), StartDate: month(2024, 1)}
+	if err := repo.EnsureUser(ctx, sub.UserID); err != nil {
+		t.Fatalf("EnsureUser() error = %v", err)
+	}
+	if err := repo.Create(ctx, sub); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return sub
+}
+
+func TestUpdateMissingSubscription(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	t.Run("unknown ID", func(t *testing.T) {
+		sub := &model.Subscription{ID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, StartDate: month(2024, 1)}
+		if err := repo.Update(ctx, sub); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("Update() error = %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("deleted ID", func(t *testing.T) {
+		sub := createSubscription(t, ctx, repo)
+		if _, err := repo.Delete(ctx, sub.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		sub.PriceMinor, sub.Version = 700, 0
+		if err := repo.Update(ctx, sub); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("Update() error = %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("deleted after a versioned read", func(t *testing.T) {
+		sub := createSubscription(t, ctx, repo)
+		read, err := repo.GetByID(ctx, sub.ID, false)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if _, err := repo.Delete(ctx, sub.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		read.PriceMinor = 700
+		if err := repo.Update(ctx, read); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("Update() error = %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+}
+
+func TestDeletedSubscriptionsAreHidden(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	live := createSubscription(t, ctx, repo)
+	deleted := &model.Subscription{ServiceName: "Spotify", PriceMinor: 300, Currency: "RUB", UserID: live.UserID, StartDate: month(2024, 1)}
+	if err := repo.Create(ctx, deleted); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		subs, err := repo.List(ctx, model.SubscriptionFilter{UserIDs: []uuid.UUID{live.UserID}}, 10, 0)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(subs) != 1 || subs[0].ID != live.ID {
+			t.Errorf("List() = %+v, want only %s", subs, live.ID)
+		}
+	})
+
+	t.Run("GetByID", func(t *testing.T) {
+		if _, err := repo.GetByID(ctx, deleted.ID, false); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("GetByID() error = %v, want %v", err, domain.ErrNotFound)
+		}
+	})
+
+	t.Run("cost", func(t *testing.T) {
+		from, to := month(2024, 1).Time(), month(2024, 1).Time()
+		totals, _, _, err := repo.GetTotalCostByCurrency(ctx, &live.UserID, "", "", &from, &to, false, false)
+		if err != nil {
+			t.Fatalf("GetTotalCostByCurrency() error = %v", err)
+		}
+		if len(totals) != 1 || totals[live.Currency] != int64(live.PriceMinor) {
+			t.Errorf("GetTotalCostByCurrency() = %v, want only %d %s", totals, live.PriceMinor, live.Currency)
+		}
+		cells, _, err := repo.GetCostCells(ctx, live.UserID, "", live.Currency, &from, &to, false)
+		if err != nil {
+			t.Fatalf("GetCostCells() error = %v", err)
+		}
+		for _, cell := range cells {
+			if cell.ServiceName != live.ServiceName {
+				t.Errorf("GetCostCells() = %+v, want only %s", cells, live.ServiceName)
+			}
+		}
+	})
+
+	t.Run("export", func(t *testing.T) {
+		var exported []uuid.UUID
+		err := repo.Export(ctx, func(sub model.Subscription) error {
+			exported = append(exported, sub.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		if len(exported) != 1 || exported[0] != live.ID {
+			t.Errorf("Export() = %v, want only %s", exported, live.ID)
+		}
+	})
+}
+
+func TestListPagesDoNotOverlap(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	userID := uuid.New()
+	if err := repo.EnsureUser(ctx, userID); err != nil {
+		t.Fatalf("EnsureUser() error = %v", err)
+	}
+	var n int
+	create := func() uuid.UUID {
+		n++
+		sub := &model.Subscription{ServiceName: fmt.Sprintf("Service %d", n), PriceMinor: 500, UserID: userID, StartDate: month(2024, 1)}
+		if err := repo.Create(ctx, sub); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return sub.ID
+	}
+	page := func(offset int) []uuid.UUID {
+		subs, err := repo.List(ctx, model.SubscriptionFilter{UserIDs: []uuid.UUID{userID}}, 2, offset)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		ids := make([]uuid.UUID, len(subs))
+		for i, sub := range subs {
+			ids[i] = sub.ID
+		}
+		return ids
+	}
+
+	existing := []uuid.UUID{create(), create(), create()}
+	first := page(0)
+	// Rows created between the reads sort after the existing ones, so they
+	// cannot push a row of the first page onto the second.
+	create()
+	create()
+	second := page(2)
+
+	if len(first) != 2 || len(second) != 2 {
+		t.Fatalf("pages = %v, %v, want two rows each", first, second)
+	}
+	if got := append(first, second[0]); !reflect.DeepEqual(got, existing) {
+		t.Errorf("pages start with %v, want %v", got, existing)
+	}
+	if slices.Contains(first, second[1]) {
+		t.Errorf("%s is on both pages", second[1])
+	}
+}
+
+func TestScanLegacyDate(t *testing.T) {
+	pool := testPool(t)
+
+	// Dates used to be kept as YYYY-MM-DD text; both kinds of value must
+	// come back as their month.
+	var current, legacy model.MonthYear
+	err := pool.QueryRow(context.Background(), "SELECT '2024-03-01'::date, '2024-03-17'::text").Scan(&current, &legacy)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	for _, got := range []model.MonthYear{current, legacy} {
+		if got.String() != "03-2024" {
+			t.Errorf("scanned %s, want 03-2024", got)
+		}
+	}
+}
+
+func TestServiceNameMatchesAnyCase(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctx := model.WithTenant(context.Background(), uuid.New())
+	sub := createSubscription(t, ctx, repo)
+
+	for _, name := range []string{"Netflix", "netflix", "NETFLIX", "nEtFlIx"} {
+		t.Run(name, func(t *testing.T) {
+			subs, err := repo.List(ctx, model.SubscriptionFilter{UserIDs: []uuid.UUID{sub.UserID}, ServiceName: name}, 10, 0)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(subs) != 1 || subs[0].ID != sub.ID {
+				t.Errorf("List() = %+v, want %s", subs, sub.ID)
+			}
+
+			from, to := month(2024, 1).Time(), month(2024, 1).Time()
+			totals, _, _, err := repo.GetTotalCostByCurrency(ctx, &sub.UserID, name, "", &from, &to, false, false)
+			if err != nil {
+				t.Fatalf("GetTotalCostByCurrency() error = %v", err)
+			}
+			if totals[sub.Currency] != int64(sub.PriceMinor) {
+				t.Errorf("GetTotalCostByCurrency() = %v, want %d %s", totals, sub.PriceMinor, sub.Currency)
+			}
+		})
+	}
+
+	t.Run("a second subscription in another case is the same service", func(t *testing.T) {
+		dup := &model.Subscription{ServiceName: "NETFLIX", PriceMinor: 700, UserID: sub.UserID, StartDate: month(2024, 6)}
+		if err := repo.Create(ctx, dup); !errors.Is(err, domain.ErrConflict) {
+			t.Errorf("Create() error = %v, want %v", err, domain.ErrConflict)
+		}
+	})
+}
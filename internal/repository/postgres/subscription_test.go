@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestListRejectsNegativePagination(t *testing.T) {
+	// Without a pool, a query that got as far as the database would panic.
+	r := &SubscriptionRepository{log: testLog}
+	ctx := model.WithTenant(context.Background(), uuid.New())
+
+	tests := []struct {
+		name          string
+		limit, offset int
+	}{
+		{name: "negative limit", limit: -1, offset: 0},
+		{name: "negative offset", limit: 10, offset: -5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.List(ctx, model.SubscriptionFilter{}, tt.limit, tt.offset); !errors.Is(err, domain.ErrInvalidPagination) {
+				t.Fatalf("List() error = %v, want %v", err, domain.ErrInvalidPagination)
+			}
+		})
+	}
+}
+
+func TestServiceNameEq(t *testing.T) {
+	sql, args, err := serviceNameEq("NETFLIX").ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() error = %v", err)
+	}
+	// Both sides are lowered, so the argument is passed as given.
+	if sql != "LOWER(service_name) = LOWER(?)" || len(args) != 1 || args[0] != "NETFLIX" {
+		t.Errorf("serviceNameEq() = %q %v, want both sides lowered", sql, args)
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// tenantScope restricts a query to the tenant on ctx. Background jobs
+// working across tenants match the rows of every tenant. Without either,
+// the query fails to build with domain.ErrNoTenant.
+func tenantScope(ctx context.Context) squirrel.Sqlizer {
+	return tenantScopeOn(ctx, "tenant_id")
+}
+
+// tenantScopeOn is tenantScope for queries that need column qualified.
+func tenantScopeOn(ctx context.Context, column string) squirrel.Sqlizer {
+	id, scoped, err := tenantFilter(ctx)
+	switch {
+	case err != nil:
+		return noTenant{}
+	case scoped:
+		return squirrel.Eq{column: id}
+	}
+	return squirrel.And{}
+}
+
+// tenantFilter returns the tenant queries made with ctx are restricted to.
+// scoped is false for background jobs working across tenants, and err is
+// domain.ErrNoTenant when ctx carries neither.
+func tenantFilter(ctx context.Context) (id uuid.UUID, scoped bool, err error) {
+	if id, ok := model.TenantFromContext(ctx); ok {
+		return id, true, nil
+	}
+	if model.AllTenants(ctx) {
+		return uuid.Nil, false, nil
+	}
+	return uuid.Nil, false, domain.ErrNoTenant
+}
+
+// noTenant stands in for the tenant condition of a query made without a
+// tenant and makes building it fail.
+type noTenant struct{}
+
+func (noTenant) ToSql() (string, []any, error) {
+	return "", nil, domain.ErrNoTenant
+}
+
+// tenantID returns the tenant on ctx, which new rows belong to.
+func tenantID(ctx context.Context) (uuid.UUID, error) {
+	if id, ok := model.TenantFromContext(ctx); ok {
+		return id, nil
+	}
+	return uuid.Nil, domain.ErrNoTenant
+}
@@ -0,0 +1,104 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	repo := NewSubscriptionRepository(testPool(t), config.RetryConfig{}, testLog)
+	ctxA := model.WithTenant(context.Background(), uuid.New())
+	ctxB := model.WithTenant(context.Background(), uuid.New())
+
+	// The same user ID is a different user in every tenant.
+	userID := uuid.New()
+	for _, ctx := range []context.Context{ctxA, ctxB} {
+		if err := repo.EnsureUser(ctx, userID); err != nil {
+			t.Fatalf("EnsureUser() error = %v", err)
+		}
+	}
+	newSub := func() *model.Subscription {
+		return &model.Subscription{
+			ServiceName: "Netflix",
+			PriceMinor:  500,
+			UserID:      userID,
+			StartDate:   model.NewMonthYear(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		}
+	}
+
+	subA := newSub()
+	if err := repo.Create(ctxA, subA); err != nil {
+		t.Fatalf("Create() in tenant A error = %v", err)
+	}
+
+	if _, err := repo.GetByID(ctxB, subA.ID, true); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("GetByID() from tenant B error = %v, want %v", err, domain.ErrNotFound)
+	}
+	if exists, err := repo.Exists(ctxB, subA.ID); err != nil || exists {
+		t.Errorf("Exists() from tenant B = %v, %v, want false", exists, err)
+	}
+	if subs, err := repo.List(ctxB, model.SubscriptionFilter{UserIDs: []uuid.UUID{userID}}, 10, 0); err != nil || len(subs) != 0 {
+		t.Errorf("List() from tenant B = %d subscriptions, %v, want none", len(subs), err)
+	}
+	changed := *subA
+	changed.PriceMinor = 1
+	if err := repo.Update(ctxB, &changed); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Update() from tenant B error = %v, want %v", err, domain.ErrNotFound)
+	}
+	if _, err := repo.Delete(ctxB, subA.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("Delete() from tenant B error = %v, want %v", err, domain.ErrNotFound)
+	}
+
+	// Tenant B's identical subscription does not collide with tenant A's.
+	subB := newSub()
+	if err := repo.Create(ctxB, subB); err != nil {
+		t.Fatalf("Create() in tenant B error = %v", err)
+	}
+
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want uuid.UUID
+	}{
+		{name: "tenant A", ctx: ctxA, want: subA.ID},
+		{name: "tenant B", ctx: ctxB, want: subB.ID},
+	} {
+		subs, err := repo.List(tt.ctx, model.SubscriptionFilter{UserIDs: []uuid.UUID{userID}}, 10, 0)
+		if err != nil {
+			t.Fatalf("List() in %s error = %v", tt.name, err)
+		}
+		if len(subs) != 1 || subs[0].ID != tt.want {
+			t.Errorf("List() in %s = %v, want only %s", tt.name, subs, tt.want)
+		}
+		if subs[0].PriceMinor != 500 {
+			t.Errorf("price in %s = %d, want 500", tt.name, subs[0].PriceMinor)
+		}
+
+		totals, counted, _, err := repo.GetTotalCostByCurrency(tt.ctx, &userID, "", "", &from, &to, false, false)
+		if err != nil {
+			t.Fatalf("GetTotalCostByCurrency() in %s error = %v", tt.name, err)
+		}
+		if counted != 1 {
+			t.Errorf("subscriptions counted in %s = %d, want 1", tt.name, counted)
+		}
+		if len(totals) != 1 {
+			t.Errorf("totals in %s = %v, want a single currency", tt.name, totals)
+		}
+		for currency, total := range totals {
+			if total != 12*500 {
+				t.Errorf("total in %s = %d %s, want %d", tt.name, total, currency, 12*500)
+			}
+		}
+	}
+}
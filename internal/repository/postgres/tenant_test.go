@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// testLog discards the logs of the repositories under test.
+var testLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestTenantScope(t *testing.T) {
+	tenantA := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	tenantB := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantSQL  string
+		wantArgs []any
+		wantErr  error
+	}{
+		{
+			name:     "tenant A",
+			ctx:      model.WithTenant(context.Background(), tenantA),
+			wantSQL:  "SELECT id FROM subscriptions WHERE tenant_id = $1",
+			wantArgs: []any{tenantA},
+		},
+		{
+			name:     "tenant B",
+			ctx:      model.WithTenant(context.Background(), tenantB),
+			wantSQL:  "SELECT id FROM subscriptions WHERE tenant_id = $1",
+			wantArgs: []any{tenantB},
+		},
+		{
+			name:    "all tenants",
+			ctx:     model.WithAllTenants(context.Background()),
+			wantSQL: "SELECT id FROM subscriptions WHERE (1=1)",
+		},
+		{
+			name:     "tenant within all tenants",
+			ctx:      model.WithTenant(model.WithAllTenants(context.Background()), tenantA),
+			wantSQL:  "SELECT id FROM subscriptions WHERE tenant_id = $1",
+			wantArgs: []any{tenantA},
+		},
+		{
+			name:    "no tenant",
+			ctx:     context.Background(),
+			wantErr: domain.ErrNoTenant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).
+				Select("id").
+				From("subscriptions").
+				Where(tenantScope(tt.ctx)).
+				ToSql()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ToSql() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if query != tt.wantSQL {
+				t.Errorf("ToSql() query = %q, want %q", query, tt.wantSQL)
+			}
+			// squirrel hands over the driver value of the tenant, its string.
+			if fmt.Sprint(args) != fmt.Sprint(tt.wantArgs) {
+				t.Errorf("ToSql() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRepositoryWithoutTenant(t *testing.T) {
+	// Without a pool, any query that got as far as the database would panic.
+	r := &SubscriptionRepository{log: testLog}
+	ctx := context.Background()
+	id := uuid.New()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "GetByID", call: func() error { _, err := r.GetByID(ctx, id, false); return err }},
+		{name: "GetForUpdate", call: func() error { _, err := r.GetForUpdate(ctx, id); return err }},
+		{name: "Exists", call: func() error { _, err := r.Exists(ctx, id); return err }},
+		{name: "List", call: func() error { _, err := r.List(ctx, model.SubscriptionFilter{}, 10, 0); return err }},
+		{name: "Delete", call: func() error { _, err := r.Delete(ctx, id); return err }},
+		{name: "Create", call: func() error { return r.Create(ctx, &model.Subscription{}) }},
+		{name: "ExpireEnded", call: func() error { _, err := r.ExpireEnded(ctx, time.Now()); return err }},
+		{name: "PurgeDeletedBefore", call: func() error { _, err := r.PurgeDeletedBefore(ctx, time.Now()); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, domain.ErrNoTenant) {
+				t.Fatalf("error = %v, want %v", err, domain.ErrNoTenant)
+			}
+		})
+	}
+}
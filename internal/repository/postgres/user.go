@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserRepository stores the users subscriptions belong to.
+type UserRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewUserRepository(db *pgxpool.Pool, log *slog.Logger) *UserRepository {
+	return &UserRepository{db: db, log: log}
+}
+
+// Create stores user and fills in its creation time. It returns
+// domain.ErrUserExists when the tenant has a user with the same ID.
+func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.CreateUser: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("users").
+		Columns("tenant_id", "id", "email").
+		Values(tenant, user.ID, user.Email).
+		Suffix("RETURNING created_at").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.CreateUser: failed to build query: %w", err)
+	}
+
+	if err := conn(ctx, r.db).QueryRow(ctx, query, args...).Scan(&user.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return fmt.Errorf("repository.CreateUser: %w", domain.ErrUserExists)
+		}
+		return fmt.Errorf("repository.CreateUser: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns the user with the given ID or domain.ErrNotFound.
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Select("id", "email", "created_at").
+		From("users").
+		Where(tenantScope(ctx)).
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("repository.GetUser: failed to build query: %w", err)
+	}
+
+	user := &model.User{}
+	if err := conn(ctx, r.db).QueryRow(ctx, query, args...).Scan(&user.ID, &user.Email, &user.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("repository.GetUser: %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("repository.GetUser: %w", err)
+	}
+	return user, nil
+}
+
+// EnsureUser registers userID in the tenant of ctx unless it is registered
+// already, so that subscriptions of users confirmed by the user service
+// satisfy the foreign key on subscriptions.
+func (r *SubscriptionRepository) EnsureUser(ctx context.Context, userID uuid.UUID) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return fmt.Errorf("repository.EnsureUser: %w", err)
+	}
+
+	psql := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+	query, args, err := psql.Insert("users").
+		Columns("tenant_id", "id").
+		Values(tenant, userID).
+		Suffix("ON CONFLICT (tenant_id, id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("repository.EnsureUser: failed to build query: %w", err)
+	}
+
+	// Registering a user twice is harmless, so the insert is retried.
+	_, err = retry(ctx, r.retry, "repository.EnsureUser", func() (pgconn.CommandTag, error) {
+		return r.conn(ctx).Exec(ctx, query, args...)
+	})
+	if err != nil {
+		return fmt.Errorf("repository.EnsureUser: %w", err)
+	}
+	return nil
+}
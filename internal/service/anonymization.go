@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Anonymize moves the subscriptions of userID to a synthetic user, clears
+// their notes and metadata and removes the audit entries naming userID,
+// keeping the financial record while dropping the link to the person. The
+// link to the synthetic user is kept for admins unless irreversible is
+// set. It happens in one transaction and is idempotent: a user anonymized
+// before keeps their synthetic user, and anonymizing them again only moves
+// subscriptions created since.
+func (s *SubscriptionService) Anonymize(ctx context.Context, userID uuid.UUID, irreversible bool) (*model.AnonymizationResult, error) {
+	const op = "service.Anonymize"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("anonymizing user", "user_id", userID.String(), "irreversible", irreversible)
+	result := &model.AnonymizationResult{UserID: userID, Irreversible: irreversible}
+	var syntheticID uuid.UUID
+	var kept bool
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		existing, err := s.reader.GetAnonymization(ctx, userID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+		syntheticID = uuid.New()
+		if existing != nil {
+			syntheticID = existing.SyntheticUserID
+		}
+
+		if result.AuditEntries, err = s.audit.DeleteByUser(ctx, userID); err != nil {
+			return err
+		}
+		if result.Subscriptions, err = s.writer.Anonymize(ctx, userID, syntheticID); err != nil {
+			return err
+		}
+
+		switch {
+		case irreversible && existing != nil:
+			err = s.writer.DeleteAnonymization(ctx, userID)
+		case !irreversible && existing != nil:
+			kept = true
+		case !irreversible && result.Subscriptions > 0:
+			err = s.writer.SaveAnonymization(ctx, &model.Anonymization{UserID: userID, SyntheticUserID: syntheticID})
+			kept = true
+		}
+		if err != nil {
+			return err
+		}
+
+		// The audit entry must not link the user to the synthetic one.
+		entry := &model.AuditEntry{Action: model.AuditActionAnonymize}
+		if entry.NewValue, err = json.Marshal(result); err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		return s.appendAudit(ctx, entry)
+	})
+	if err != nil {
+		log.Error("failed to anonymize user", "error", err)
+		return nil, err
+	}
+	s.totalCost.invalidate(userID, syntheticID)
+
+	if kept {
+		result.SyntheticUserID = &syntheticID
+	}
+	log.Info("anonymized user successfully", "user_id", userID.String(), "subscriptions", result.Subscriptions, "audit_entries", result.AuditEntries)
+	return result, nil
+}
+
+// GetAnonymization returns the synthetic user userID was anonymized to.
+// It returns domain.ErrNotFound when userID was never anonymized or only
+// irreversibly.
+func (s *SubscriptionService) GetAnonymization(ctx context.Context, userID uuid.UUID) (*model.Anonymization, error) {
+	const op = "service.GetAnonymization"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting anonymization", "user_id", userID.String())
+	anonymization, err := s.reader.GetAnonymization(ctx, userID)
+	if err != nil {
+		log.Error("failed to get anonymization", "error", err)
+		return nil, err
+	}
+	return anonymization, nil
+}
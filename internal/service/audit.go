@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// recordChange appends an audit entry for a change of the subscription id
+// from before to after, attributed to the actor on ctx. before is nil for
+// creations and after for deletions. It must be called with the context of
+// the transaction making the change, so that failing to record the change
+// rolls it back.
+func (s *SubscriptionService) recordChange(ctx context.Context, action string, id uuid.UUID, before, after *model.Subscription) error {
+	entry := &model.AuditEntry{SubscriptionID: &id, Action: action}
+	var err error
+	if before != nil {
+		if entry.OldValue, err = json.Marshal(before); err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+	}
+	if after != nil {
+		if entry.NewValue, err = json.Marshal(after); err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+	}
+
+	return s.appendAudit(ctx, entry)
+}
+
+// recordChanges calls recordChange for every change in changes. Each
+// entry is recorded for the tenant of its subscription, so that jobs
+// working across tenants keep every tenant's log apart.
+func (s *SubscriptionService) recordChanges(ctx context.Context, action string, changes []model.SubscriptionChange) error {
+	for _, change := range changes {
+		ctx := model.WithTenant(ctx, change.TenantID)
+		if err := s.recordChange(ctx, action, change.Before.ID, &change.Before, change.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedOwners returns the distinct users owning the subscriptions in
+// changes.
+func changedOwners(changes []model.SubscriptionChange) []uuid.UUID {
+	seen := make(map[uuid.UUID]struct{}, len(changes))
+	owners := make([]uuid.UUID, 0)
+	for _, change := range changes {
+		if _, ok := seen[change.Before.UserID]; !ok {
+			seen[change.Before.UserID] = struct{}{}
+			owners = append(owners, change.Before.UserID)
+		}
+	}
+	return owners
+}
+
+// appendAudit attributes entry to the actor on ctx and appends it.
+func (s *SubscriptionService) appendAudit(ctx context.Context, entry *model.AuditEntry) error {
+	actor := model.ActorFromContext(ctx)
+	entry.Actor = actor.Name
+	if actor.ClientIP != "" {
+		entry.ClientIP = &actor.ClientIP
+	}
+	return s.audit.Append(ctx, entry)
+}
+
+// recordCreated appends an audit entry for the creation of the
+// subscription id, reading back the row as it was stored.
+func (s *SubscriptionService) recordCreated(ctx context.Context, id uuid.UUID) error {
+	created, err := s.reader.GetByID(ctx, id, false)
+	if err != nil {
+		return err
+	}
+	return s.recordChange(ctx, model.AuditActionCreate, id, nil, created)
+}
+
+// recordCreatedMany is recordCreated for many subscriptions at once,
+// reading them back with a single query.
+func (s *SubscriptionService) recordCreatedMany(ctx context.Context, ids []uuid.UUID) error {
+	created, err := s.reader.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*model.Subscription, len(created))
+	for i := range created {
+		byID[created[i].ID] = &created[i]
+	}
+	for _, id := range ids {
+		sub, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("service.recordCreatedMany: created subscription %s not found", id)
+		}
+		if err := s.recordChange(ctx, model.AuditActionCreate, id, nil, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetHistory returns the changes recorded for the subscription id, oldest
+// first. The history of soft-deleted subscriptions stays available; it
+// returns domain.ErrNotFound when no such subscription exists.
+func (s *SubscriptionService) GetHistory(ctx context.Context, id uuid.UUID, limit, offset int) ([]model.HistoryEntry, error) {
+	const op = "service.GetHistory"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting subscription history", "id", id.String())
+	if _, err := s.reader.GetByID(ctx, id, true); err != nil {
+		log.Error("failed to get subscription", "error", err)
+		return nil, err
+	}
+
+	entries, err := s.audit.ListBySubscription(ctx, id, limit, offset)
+	if err != nil {
+		log.Error("failed to list audit entries", "error", err)
+		return nil, err
+	}
+	history := make([]model.HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		item, err := entry.History()
+		if err != nil {
+			log.Error("failed to diff audit entry", "error", err)
+			return nil, err
+		}
+		history = append(history, item)
+	}
+	log.Info("got subscription history successfully", "count", len(history))
+	return history, nil
+}
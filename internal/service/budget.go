@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetBudget returns the budget of userID along with their spend in the
+// current month. It returns domain.ErrNotFound when the user has no
+// budget.
+func (s *SubscriptionService) GetBudget(ctx context.Context, userID uuid.UUID) (*model.BudgetUsage, error) {
+	const op = "service.GetBudget"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting budget", "user_id", userID.String())
+	budget, err := s.reader.GetBudget(ctx, userID)
+	if err != nil {
+		log.Error("failed to get budget", "error", err)
+		return nil, err
+	}
+	usage, err := s.budgetUsage(ctx, budget, s.currentMonth())
+	if err != nil {
+		log.Error("failed to get budget usage", "error", err)
+		return nil, err
+	}
+	log.Info("got budget successfully", "over_budget", usage.OverBudget)
+	return usage, nil
+}
+
+// SetBudget stores budget, replacing the budget of its user if they have
+// one, and reports whether it was created. A budget below what the user
+// spends already is stored all the same and reported as over budget.
+func (s *SubscriptionService) SetBudget(ctx context.Context, budget *model.Budget) (*model.BudgetUsage, bool, error) {
+	const op = "service.SetBudget"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("setting budget", "user_id", budget.UserID.String(), "amount_minor", budget.AmountMinor, "currency", budget.Currency)
+	if err := s.validateUser(ctx, budget.UserID); err != nil {
+		log.Warn("failed to validate user", "user_id", budget.UserID.String(), "error", err)
+		return nil, false, err
+	}
+	created, err := s.writer.SetBudget(ctx, budget)
+	if err != nil {
+		log.Error("failed to set budget", "error", err)
+		return nil, false, err
+	}
+	usage, err := s.budgetUsage(ctx, budget, s.currentMonth())
+	if err != nil {
+		log.Error("failed to get budget usage", "error", err)
+		return nil, false, err
+	}
+	log.Info("set budget successfully", "created", created, "over_budget", usage.OverBudget)
+	return usage, created, nil
+}
+
+// GetBudgetStatus evaluates what userID spends in the current month
+// against their budget. It returns domain.ErrNotFound when the user has
+// no budget.
+func (s *SubscriptionService) GetBudgetStatus(ctx context.Context, userID uuid.UUID) (*model.BudgetStatus, error) {
+	const op = "service.GetBudgetStatus"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("evaluating budget", "user_id", userID.String())
+	budget, err := s.reader.GetBudget(ctx, userID)
+	if err != nil {
+		log.Error("failed to get budget", "error", err)
+		return nil, err
+	}
+	month := s.currentMonth()
+	usage, err := s.budgetUsage(ctx, budget, month)
+	if err != nil {
+		log.Error("failed to get budget usage", "error", err)
+		return nil, err
+	}
+
+	status := &model.BudgetStatus{
+		Status:                  model.BudgetStatusOK,
+		Month:                   model.FormatMonthYear(month),
+		WarningThresholdPercent: s.budgetWarning,
+		BudgetUsage:             usage,
+	}
+	switch {
+	case usage.OverBudget:
+		status.Status = model.BudgetStatusExceeded
+	case usage.SpentThisMonth > 0 && usage.SpentThisMonth*100 >= budget.AmountMinor*int64(s.budgetWarning):
+		status.Status = model.BudgetStatusWarning
+	}
+	log.Info("evaluated budget successfully", "status", status.Status)
+	return status, nil
+}
+
+// currentMonth returns the first day of the current month.
+func (s *SubscriptionService) currentMonth() time.Time {
+	now := s.now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// budgetUsage compares budget with what its user spends in month, counted
+// exactly like the total cost of that month.
+func (s *SubscriptionService) budgetUsage(ctx context.Context, budget *model.Budget, month time.Time) (*model.BudgetUsage, error) {
+	total, err := s.GetTotalCost(ctx, &budget.UserID, "", budget.Currency, &month, &month, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &model.BudgetUsage{Budget: budget}
+	if total.TotalCost != nil {
+		usage.SpentThisMonth = *total.TotalCost
+	}
+	usage.Remaining = budget.AmountMinor - usage.SpentThisMonth
+	usage.OverBudget = usage.Remaining < 0
+	return usage, nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// BulkCreate stores subs and returns their IDs in the same order. When
+// atomic is set, either every subscription is stored or none is, and a
+// failure is reported as a *domain.BulkItemError. Otherwise each
+// subscription is stored on its own; failures are returned per index and
+// the IDs of failed items are uuid.Nil.
+func (s *SubscriptionService) BulkCreate(ctx context.Context, subs []model.Subscription, atomic bool) ([]uuid.UUID, []error, error) {
+	const op = "service.BulkCreate"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("creating subscriptions in bulk", "count", len(subs), "atomic", atomic)
+	if err := s.resolveServices(ctx, subs); err != nil {
+		log.Error("failed to resolve services", "error", err)
+		return nil, nil, err
+	}
+	if atomic {
+		if err := s.validateUsers(ctx, subs); err != nil {
+			log.Warn("failed to validate users", "error", err)
+			return nil, nil, err
+		}
+		var ids []uuid.UUID
+		err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			if ids, err = s.writer.CreateMany(ctx, subs); err != nil {
+				return err
+			}
+			if err := s.enforceQuota(ctx, subs...); err != nil {
+				return err
+			}
+			return s.recordCreatedMany(ctx, ids)
+		})
+		if err != nil {
+			log.Error("failed to create subscriptions", "error", err)
+			return nil, nil, err
+		}
+		s.invalidateUsers(subs)
+		log.Info("created subscriptions successfully", "count", len(ids))
+		return ids, nil, nil
+	}
+
+	ids := make([]uuid.UUID, len(subs))
+	errs := make([]error, len(subs))
+	created := make([]model.Subscription, 0, len(subs))
+	for i := range subs {
+		if errs[i] = s.validateUser(ctx, subs[i].UserID); errs[i] != nil {
+			log.Warn("failed to validate user", "index", i, "error", errs[i])
+			continue
+		}
+		errs[i] = s.writer.WithTx(ctx, func(ctx context.Context) error {
+			if err := s.writer.Create(ctx, &subs[i]); err != nil {
+				return err
+			}
+			if err := s.enforceQuota(ctx, subs[i]); err != nil {
+				return err
+			}
+			if err := s.recordCreated(ctx, subs[i].ID); err != nil {
+				return err
+			}
+			ids[i] = subs[i].ID
+			return nil
+		})
+		if errs[i] != nil {
+			log.Warn("failed to create subscription", "index", i, "error", errs[i])
+			continue
+		}
+		created = append(created, subs[i])
+	}
+	s.invalidateUsers(created)
+
+	log.Info("created subscriptions", "created", len(created), "failed", len(subs)-len(created))
+	return ids, errs, nil
+}
+
+// invalidateUsers drops the cached totals of the owners of subs.
+func (s *SubscriptionService) invalidateUsers(subs []model.Subscription) {
+	if len(subs) == 0 {
+		return
+	}
+	userIDs := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		userIDs = append(userIDs, sub.UserID)
+	}
+	s.totalCost.invalidate(userIDs...)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"subscriptions-service/internal/model"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// totalCostCache keeps recent GetTotalCost results per user. Entries expire
+// after ttl and are dropped for a user as soon as one of their
+// subscriptions is written. A zero ttl disables caching.
+type totalCostCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]map[string]totalCostEntry
+}
+
+type totalCostEntry struct {
+	resp      model.TotalCostResponse
+	expiresAt time.Time
+}
+
+func newTotalCostCache(ttl time.Duration) *totalCostCache {
+	return &totalCostCache{ttl: ttl, entries: make(map[uuid.UUID]map[string]totalCostEntry)}
+}
+
+// totalCostKey identifies a GetTotalCost call for a user of tenant. The
+// current month is part of the key because open-ended periods are clamped
+// to it.
+func totalCostKey(tenant uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived bool, now time.Time) string {
+	bound := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return model.FormatMonthYear(*t)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%t|%t|%s", tenant, strings.ToLower(serviceName), currency, bound(from), bound(to), amortize, includeArchived, model.FormatMonthYear(now))
+}
+
+func (c *totalCostCache) get(userID uuid.UUID, key string, now time.Time) (*model.TotalCostResponse, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userID][key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(c.entries[userID], key)
+		return nil, false
+	}
+	resp := entry.resp
+	return &resp, true
+}
+
+func (c *totalCostCache) set(userID uuid.UUID, key string, resp *model.TotalCostResponse, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[userID] == nil {
+		c.entries[userID] = make(map[string]totalCostEntry)
+	}
+	c.entries[userID][key] = totalCostEntry{resp: *resp, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidate drops every cached result for the given users, along with
+// the company-wide results cached under uuid.Nil.
+func (c *totalCostCache) invalidate(userIDs ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, uuid.Nil)
+	for _, userID := range userIDs {
+		delete(c.entries, userID)
+	}
+}
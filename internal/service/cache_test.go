@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// totalCost returns the RUB total of userID, failing t on errors.
+func totalCost(t *testing.T, svc *SubscriptionService, userID uuid.UUID, fresh bool) int64 {
+	t.Helper()
+	resp, err := svc.GetTotalCost(context.Background(), &userID, "", "RUB", nil, nil, false, false, fresh)
+	if err != nil {
+		t.Fatalf("GetTotalCost() error = %v", err)
+	}
+	return *resp.TotalCost
+}
+
+func TestTotalCostCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(svc *SubscriptionService, sub model.Subscription) error
+		want  int64
+	}{
+		{
+			name: "create",
+			write: func(svc *SubscriptionService, sub model.Subscription) error {
+				_, err := svc.Create(context.Background(), &model.Subscription{UserID: sub.UserID, ServiceName: "Spotify", PriceMinor: 300, Currency: "RUB"}, true)
+				return err
+			},
+			want: 800,
+		},
+		{
+			name: "update",
+			write: func(svc *SubscriptionService, sub model.Subscription) error {
+				sub.PriceMinor = 700
+				return svc.Update(context.Background(), &sub, true)
+			},
+			want: 700,
+		},
+		{
+			name: "patch",
+			write: func(svc *SubscriptionService, sub model.Subscription) error {
+				price := 900
+				return svc.UpdateFields(context.Background(), sub.ID, model.SubscriptionPatch{PriceMinor: &price}, true)
+			},
+			want: 900,
+		},
+		{
+			name: "delete",
+			write: func(svc *SubscriptionService, sub model.Subscription) error {
+				return svc.Delete(context.Background(), sub.ID)
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := model.Subscription{ID: uuid.New(), UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", BillingPeriod: model.BillingMonthly, StartDate: month(2025, 1), Status: model.StatusActive, Version: 1}
+			store := newFakeStore(sub)
+			svc := newTestService(store, 0)
+
+			if got := totalCost(t, svc, sub.UserID, false); got != 500 {
+				t.Fatalf("total before the write = %d, want 500", got)
+			}
+			if got := totalCost(t, svc, sub.UserID, false); got != 500 || store.costReads != 1 {
+				t.Fatalf("repeated total = %d after %d reads, want 500 from the cache", got, store.costReads)
+			}
+
+			if err := tt.write(svc, sub); err != nil {
+				t.Fatalf("write error = %v", err)
+			}
+			if got := totalCost(t, svc, sub.UserID, false); got != tt.want {
+				t.Errorf("total after the write = %d, want %d", got, tt.want)
+			}
+			if store.costReads != 2 {
+				t.Errorf("reads = %d, want 2", store.costReads)
+			}
+		})
+	}
+}
+
+func TestTotalCostCacheKeepsOtherUsers(t *testing.T) {
+	subA := model.Subscription{ID: uuid.New(), UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", Status: model.StatusActive, Version: 1}
+	subB := model.Subscription{ID: uuid.New(), UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 200, Currency: "RUB", Status: model.StatusActive, Version: 1}
+	store := newFakeStore(subA, subB)
+	svc := newTestService(store, 0)
+
+	totalCost(t, svc, subA.UserID, false)
+	if err := svc.Delete(context.Background(), subB.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := totalCost(t, svc, subA.UserID, false); got != 500 || store.costReads != 1 {
+		t.Errorf("total of another user = %d after %d reads, want 500 from the cache", got, store.costReads)
+	}
+}
+
+func TestTotalCostFreshBypassesCache(t *testing.T) {
+	sub := model.Subscription{ID: uuid.New(), UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", Status: model.StatusActive, Version: 1}
+	store := newFakeStore(sub)
+	svc := newTestService(store, 0)
+
+	totalCost(t, svc, sub.UserID, false)
+	// A change the service did not make is only seen by fresh reads.
+	changed := sub
+	changed.PriceMinor = 600
+	store.put(context.Background(), changed)
+
+	if got := totalCost(t, svc, sub.UserID, false); got != 500 {
+		t.Errorf("cached total = %d, want 500", got)
+	}
+	if got := totalCost(t, svc, sub.UserID, true); got != 600 || store.costReads != 2 {
+		t.Errorf("fresh total = %d after %d reads, want 600 read again", got, store.costReads)
+	}
+	// A fresh read refreshes the cache for later ones.
+	if got := totalCost(t, svc, sub.UserID, false); got != 600 || store.costReads != 2 {
+		t.Errorf("total after a fresh read = %d after %d reads, want 600 from the cache", got, store.costReads)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type CatalogRepository interface {
+	Create(ctx context.Context, entry *model.CatalogEntry) error
+	GetOrCreate(ctx context.Context, name string) (*model.CatalogEntry, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.CatalogEntry, error)
+	GetByName(ctx context.Context, name string) (*model.CatalogEntry, error)
+	List(ctx context.Context, limit, offset int) ([]model.CatalogEntry, error)
+	Update(ctx context.Context, entry *model.CatalogEntry) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// CatalogService manages the service catalog subscriptions are linked to.
+type CatalogService struct {
+	repo       CatalogRepository
+	autoCreate bool
+	log        *slog.Logger
+}
+
+func NewCatalogService(repo CatalogRepository, cfg config.CatalogConfig, log *slog.Logger) *CatalogService {
+	return &CatalogService{repo: repo, autoCreate: cfg.AutoCreate, log: log}
+}
+
+func (s *CatalogService) Create(ctx context.Context, entry *model.CatalogEntry) error {
+	const op = "service.CreateCatalogEntry"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("creating catalog entry", "name", entry.Name)
+	if err := s.repo.Create(ctx, entry); err != nil {
+		log.Error("failed to create catalog entry", "error", err)
+		return err
+	}
+	log.Info("catalog entry created successfully", "id", entry.ID)
+	return nil
+}
+
+func (s *CatalogService) GetByID(ctx context.Context, id uuid.UUID) (*model.CatalogEntry, error) {
+	const op = "service.GetCatalogEntry"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting catalog entry", "id", id.String())
+	entry, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		log.Error("failed to get catalog entry", "error", err)
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *CatalogService) List(ctx context.Context, limit, offset int) ([]model.CatalogEntry, error) {
+	const op = "service.ListCatalogEntries"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("listing catalog entries")
+	entries, err := s.repo.List(ctx, limit, offset)
+	if err != nil {
+		log.Error("failed to list catalog entries", "error", err)
+		return nil, err
+	}
+	log.Info("listed catalog entries successfully", "count", len(entries))
+	return entries, nil
+}
+
+// Update replaces the entry with entry.ID. Subscriptions linked to it are
+// renamed along with it.
+func (s *CatalogService) Update(ctx context.Context, entry *model.CatalogEntry) error {
+	const op = "service.UpdateCatalogEntry"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("updating catalog entry", "id", entry.ID.String())
+	if err := s.repo.Update(ctx, entry); err != nil {
+		log.Error("failed to update catalog entry", "error", err)
+		return err
+	}
+	log.Info("updated catalog entry successfully", "id", entry.ID.String())
+	return nil
+}
+
+// Delete removes the entry with the given ID. Entries still referenced by
+// subscriptions cannot be deleted.
+func (s *CatalogService) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "service.DeleteCatalogEntry"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("deleting catalog entry", "id", id.String())
+	if err := s.repo.Delete(ctx, id); err != nil {
+		log.Error("failed to delete catalog entry", "error", err)
+		return err
+	}
+	log.Info("deleted catalog entry successfully", "id", id.String())
+	return nil
+}
+
+// Resolve returns the catalog entry named name, matched
+// case-insensitively. A missing entry is created when auto-creation is
+// enabled; otherwise Resolve returns nil.
+func (s *CatalogService) Resolve(ctx context.Context, name string) (*model.CatalogEntry, error) {
+	if s.autoCreate {
+		return s.repo.GetOrCreate(ctx, name)
+	}
+	entry, err := s.repo.GetByName(ctx, name)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	return entry, err
+}
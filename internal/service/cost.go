@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// expandMonths calls fn with the first day of every month sub is billed in,
+// from its start month through its end month, both inclusive. Cancelled
+// subscriptions end with the month they were cancelled in. A non-zero
+// from or to restricts the expansion to months inside that period.
+// Open-ended subscriptions run until to, or until the month of now when no
+// upper bound is given. Every cost calculation goes through here so that
+// endpoints reporting on the same data can never disagree.
+func expandMonths(sub model.Subscription, from, to, now time.Time, fn func(month time.Time) error) error {
+	end := now
+	if !to.IsZero() {
+		end = to
+	}
+	if billedThrough := sub.BilledThrough(); billedThrough != nil {
+		end = billedThrough.Time()
+	}
+
+	first, last := monthIndex(sub.StartDate.Time()), monthIndex(end)
+	if !from.IsZero() {
+		first = max(first, monthIndex(from))
+	}
+	if !to.IsZero() {
+		last = min(last, monthIndex(to))
+	}
+
+	for m := first; m <= last; m++ {
+		if err := fn(monthFromIndex(m)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCost returns total+cost, or domain.ErrCostOverflow when the sum does not
+// fit into int64. Costs are never negative.
+func addCost(total, cost int64) (int64, error) {
+	if cost > math.MaxInt64-total {
+		return 0, domain.ErrCostOverflow
+	}
+	return total + cost, nil
+}
+
+// monthCost returns what sub is charged in month, one of the months
+// expandMonths reports for it. Monthly subscriptions are charged their
+// price every month, yearly ones on every 12-month anniversary of their
+// start month and weekly ones every 7 days from the first day of their
+// start month. With amortize, yearly and weekly prices are spread evenly
+// over the months instead, rounding down so that every 12 months add up to
+// the exact yearly amount. Months up to and including the trial end month
+// are free and those up to and including DiscountUntil are charged the
+// discounted price. The repository computes totals the same way.
+func monthCost(sub model.Subscription, month time.Time, amortize bool) int64 {
+	if sub.TrialEndDate != nil && !sub.TrialEndDate.Time().Before(month) {
+		return 0
+	}
+	price := int64(sub.PriceIn(model.NewMonthYear(month)))
+	k := monthIndex(month) - monthIndex(sub.StartDate.Time())
+	switch {
+	case sub.BillingPeriod == model.BillingYearly && amortize:
+		return spread(price, 1, 12, k)
+	case sub.BillingPeriod == model.BillingWeekly && amortize:
+		return spread(price, 52, 12, k)
+	case sub.BillingPeriod == model.BillingYearly:
+		if k%12 == 0 {
+			return price
+		}
+		return 0
+	case sub.BillingPeriod == model.BillingWeekly:
+		return price * (weeklyCharges(sub.StartDate.Time(), k+1) - weeklyCharges(sub.StartDate.Time(), k))
+	default:
+		return price
+	}
+}
+
+// expandUserCosts calls fn with every month expandMonths reports for sub
+// and the part of its monthCost the user pays, see model.CostShare. The
+// part is taken of the cost accumulated since the first month expanded and
+// every month is charged its growth, so that the months add up to the part
+// of the total the repository reports. Due to rounding, an owner sharing
+// with several members can come out a minor unit below zero in a month.
+func expandUserCosts(sub model.SharedSubscription, from, to, now time.Time, amortize bool, fn func(month time.Time, cost int64) error) error {
+	var accumulated, paid int64
+	return expandMonths(sub.Subscription, from, to, now, func(month time.Time) error {
+		sum, err := addCost(accumulated, monthCost(sub.Subscription, month, amortize))
+		if err != nil {
+			return err
+		}
+		accumulated = sum
+		part := sub.Share.Part(accumulated)
+		cost := part - paid
+		paid = part
+		return fn(month, cost)
+	})
+}
+
+// spread returns the share of price*num/den falling into month k when it
+// is charged monthly from month 0 and every partial sum is rounded down.
+func spread(price, num, den int64, k int) int64 {
+	q, r := price/den, price%den
+	return num*q + num*r*int64(k+1)/den - num*r*int64(k)/den
+}
+
+// weeklyCharges counts the weekly charges made in the first n months after
+// start, the first one being on start itself.
+func weeklyCharges(start time.Time, n int) int64 {
+	days := int64(start.AddDate(0, n, 0).Sub(start).Hours() / 24)
+	return (days + 6) / 7
+}
+
+// addMonths adds n calendar months to t. The day is clamped to the last
+// day of the resulting month, so January 31st plus one month is the last
+// day of February rather than a day in March.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(t.Day(), lastDay), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// nextBillingDate returns the first day on or after today that sub is
+// charged on, following the same schedule as monthCost, or nil when no
+// charge is left. Charges fall on anniversaries of the start date, each
+// computed from the start date itself so that clamped days do not drift.
+// Charges within a trial or after the month sub is billed through do not
+// count.
+func nextBillingDate(sub model.Subscription, today time.Time) *time.Time {
+	if sub.DeletedAt != nil {
+		return nil
+	}
+	start := sub.StartDate.Time()
+	earliest := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if sub.TrialEndDate != nil {
+		if afterTrial := monthFromIndex(monthIndex(sub.TrialEndDate.Time()) + 1); afterTrial.After(earliest) {
+			earliest = afterTrial
+		}
+	}
+
+	var next time.Time
+	switch {
+	case !start.Before(earliest):
+		next = start
+	case sub.BillingPeriod == model.BillingWeekly:
+		days := int(earliest.Sub(start).Hours() / 24)
+		next = start.AddDate(0, 0, (days+6)/7*7)
+	default:
+		step := 1
+		if sub.BillingPeriod == model.BillingYearly {
+			step = 12
+		}
+		k := (monthIndex(earliest) - monthIndex(start) + step - 1) / step
+		next = addMonths(start, k*step)
+		if next.Before(earliest) {
+			next = addMonths(start, (k+1)*step)
+		}
+	}
+
+	if billedThrough := sub.BilledThrough(); billedThrough != nil && monthIndex(next) > monthIndex(billedThrough.Time()) {
+		return nil
+	}
+	return &next
+}
+
+// monthIndex maps t to a monotonically increasing month number so months
+// can be compared regardless of the day.
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// monthFromIndex is the inverse of monthIndex, returning the first day of
+// the month.
+func monthFromIndex(m int) time.Time {
+	return time.Date(m/12, time.Month(m%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GetCostBreakdown computes the total cost of the user's subscriptions,
+// including their part of those shared with them, within the period month
+// by month, optionally split per month
+// (chronologically) and per service (by cost, highest first). Both
+// breakdowns always sum to the returned total; they only make sense for a
+// single currency, so callers check Totals when no currency is given.
+// amortize is passed on to monthCost. The months come from userCostCells.
+func (s *SubscriptionService) GetCostBreakdown(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, byMonth, byService, amortize bool) (*model.TotalCostResponse, error) {
+	const op = "service.GetCostBreakdown"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting cost breakdown", "currency", currency, "by_month", byMonth, "by_service", byService, "in_go", s.expandCostsInGo)
+	cells, counted, err := s.userCostCells(ctx, userID, serviceName, currency, from, to, amortize)
+	if err != nil {
+		log.Error("failed to get costs for breakdown", "error", err)
+		return nil, err
+	}
+
+	resp := &model.TotalCostResponse{Scope: model.TotalCostScopeUser, SubscriptionsCounted: counted}
+	resp.SetPeriod(from, to)
+	var total int64
+	totals := make(map[string]int64)
+	monthCosts := make(map[time.Time]int64)
+	serviceCosts := make(map[string]int64)
+	for _, cell := range cells {
+		// Every partial sum is bounded by the total, so guarding the
+		// total is enough to keep the breakdowns from overflowing.
+		sum, err := addCost(total, cell.Cost)
+		if err != nil {
+			log.Error("total cost overflows", "error", err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		total = sum
+		totals[cell.Currency] += cell.Cost
+		monthCosts[cell.Month] += cell.Cost
+		serviceCosts[cell.ServiceName] += cell.Cost
+	}
+
+	if byMonth {
+		months := make([]time.Time, 0, len(monthCosts))
+		for month := range monthCosts {
+			months = append(months, month)
+		}
+		sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+
+		resp.Months = make([]model.MonthlyCost, 0, len(months))
+		for _, month := range months {
+			resp.Months = append(resp.Months, model.MonthlyCost{Month: model.FormatMonthYear(month), Cost: monthCosts[month]})
+		}
+	}
+
+	if byService {
+		resp.Services = make([]model.ServiceCost, 0, len(serviceCosts))
+		for name, cost := range serviceCosts {
+			resp.Services = append(resp.Services, model.ServiceCost{ServiceName: name, Cost: cost})
+		}
+		sort.Slice(resp.Services, func(i, j int) bool {
+			if resp.Services[i].Cost != resp.Services[j].Cost {
+				return resp.Services[i].Cost > resp.Services[j].Cost
+			}
+			return resp.Services[i].ServiceName < resp.Services[j].ServiceName
+		})
+	}
+
+	resp.SetTotals(currency, totals)
+
+	log.Info("got cost breakdown successfully", "total_cost", total)
+	return resp, nil
+}
+
+// userCostCells returns what the user pays per month, currency and service
+// within the period, along with the number of subscriptions counted. The
+// months are expanded in Postgres unless the service is configured to
+// expand them itself, see costCells.
+func (s *SubscriptionService) userCostCells(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, amortize bool) ([]model.CostCell, int, error) {
+	if s.expandCostsInGo {
+		return s.costCells(ctx, userID, serviceName, currency, from, to, amortize)
+	}
+	return s.reader.GetCostCells(ctx, userID, serviceName, currency, from, to, amortize)
+}
+
+// costCells is the Go counterpart of the repository's GetCostCells: it
+// loads the subscriptions and expands them month by month with
+// expandUserCosts. It is kept for debugging the SQL, which has to agree
+// with it.
+func (s *SubscriptionService) costCells(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, amortize bool) ([]model.CostCell, int, error) {
+	subs, err := s.reader.GetSubscriptionsForTotalCost(ctx, userID, serviceName, currency, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var lower, upper time.Time
+	if from != nil {
+		lower = *from
+	}
+	if to != nil {
+		upper = *to
+	}
+
+	type cellKey struct {
+		month                 time.Time
+		currency, serviceName string
+	}
+	var total int64
+	var counted int
+	costs := make(map[cellKey]int64)
+	for _, sub := range subs {
+		contributed := false
+		if err := expandUserCosts(sub, lower, upper, s.now(), amortize, func(month time.Time, cost int64) error {
+			contributed = true
+			sum, err := addCost(total, cost)
+			if err != nil {
+				return err
+			}
+			total = sum
+			costs[cellKey{month, sub.Currency, sub.ServiceName}] += cost
+			return nil
+		}); err != nil {
+			return nil, 0, fmt.Errorf("subscription %s: %w", sub.ID, err)
+		}
+		if contributed {
+			counted++
+		}
+	}
+
+	cells := make([]model.CostCell, 0, len(costs))
+	for key, cost := range costs {
+		cells = append(cells, model.CostCell{Month: key.month, Currency: key.currency, ServiceName: key.serviceName, Cost: cost})
+	}
+	return cells, counted, nil
+}
+
+// userTotalCost returns what the user pays in currency within [from, to],
+// like GetTotalCost reports it.
+func (s *SubscriptionService) userTotalCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to time.Time) (int64, error) {
+	totals, _, _, err := s.reader.GetTotalCostByCurrency(ctx, &userID, serviceName, currency, &from, &to, false, false)
+	if err != nil {
+		return 0, err
+	}
+	return totals[currency], nil
+}
+
+// CompareCosts compares the total cost in currency within [from, to] with
+// the total of the immediately preceding period of the same number of
+// months.
+func (s *SubscriptionService) CompareCosts(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to time.Time) (*model.CostComparisonResponse, error) {
+	const op = "service.CompareCosts"
+	log := s.log.With(slog.String("op", op))
+
+	months := monthIndex(to) - monthIndex(from) + 1
+	prevTo := monthFromIndex(monthIndex(from) - 1)
+	prevFrom := monthFromIndex(monthIndex(from) - months)
+
+	log.Info("comparing costs", "months", months, "currency", currency)
+	current, err := s.userTotalCost(ctx, userID, serviceName, currency, from, to)
+	if err != nil {
+		log.Error("failed to get current period cost", "error", err)
+		return nil, err
+	}
+	previous, err := s.userTotalCost(ctx, userID, serviceName, currency, prevFrom, prevTo)
+	if err != nil {
+		log.Error("failed to get previous period cost", "error", err)
+		return nil, err
+	}
+
+	resp := &model.CostComparisonResponse{
+		Currency: currency,
+		Current: model.PeriodCost{
+			StartDate: model.FormatMonthYear(from),
+			EndDate:   model.FormatMonthYear(to),
+			TotalCost: current,
+		},
+		Previous: model.PeriodCost{
+			StartDate: model.FormatMonthYear(prevFrom),
+			EndDate:   model.FormatMonthYear(prevTo),
+			TotalCost: previous,
+		},
+		Delta: current - previous,
+	}
+	if previous != 0 {
+		percent := float64(resp.Delta) / float64(previous) * 100
+		resp.DeltaPercent = &percent
+	}
+
+	log.Info("compared costs successfully", "current", current, "previous", previous)
+	return resp, nil
+}
+
+// GetAverageMonthlyCost divides the total cost in currency within [from,
+// to] by the number of months given by basis: every month of the window,
+// or only the months with any spend. A period without spend averages to 0.
+func (s *SubscriptionService) GetAverageMonthlyCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to time.Time, basis string) (*model.AverageCostResponse, error) {
+	const op = "service.GetAverageMonthlyCost"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting average monthly cost", "basis", basis, "currency", currency)
+	resp := &model.AverageCostResponse{
+		Currency:  currency,
+		Basis:     basis,
+		StartDate: model.FormatMonthYear(from),
+		EndDate:   model.FormatMonthYear(to),
+	}
+
+	switch basis {
+	case model.AverageBasisWindow:
+		total, err := s.userTotalCost(ctx, userID, serviceName, currency, from, to)
+		if err != nil {
+			log.Error("failed to get total cost", "error", err)
+			return nil, err
+		}
+		resp.TotalCost = total
+		resp.Months = monthIndex(to) - monthIndex(from) + 1
+	case model.AverageBasisActiveMonths:
+		breakdown, err := s.GetCostBreakdown(ctx, userID, serviceName, currency, &from, &to, true, false, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, month := range breakdown.Months {
+			resp.TotalCost += month.Cost
+			if month.Cost > 0 {
+				resp.Months++
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported basis %q", op, basis)
+	}
+
+	if resp.Months > 0 {
+		resp.AverageMonthlyCost = float64(resp.TotalCost) / float64(resp.Months)
+	}
+
+	log.Info("got average monthly cost successfully", "average", resp.AverageMonthlyCost)
+	return resp, nil
+}
+
+// GetForecast projects the subscriptions priced in currency active in the
+// current month over the next months months, starting with the following
+// month. Subscriptions that do not renew automatically stop contributing
+// after their end date; open-ended and auto-renewing ones run through the
+// whole forecast unless they were cancelled. amortize is passed on to
+// monthCost.
+func (s *SubscriptionService) GetForecast(ctx context.Context, userID uuid.UUID, currency string, months int, amortize bool) (*model.ForecastResponse, error) {
+	const op = "service.GetForecast"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting forecast", "months", months, "currency", currency)
+	now := s.now()
+	current := monthFromIndex(monthIndex(now))
+	from := monthFromIndex(monthIndex(now) + 1)
+	to := monthFromIndex(monthIndex(now) + months)
+
+	subs, err := s.reader.GetSubscriptionsForTotalCost(ctx, userID, "", currency, &current, &current)
+	if err != nil {
+		log.Error("failed to get subscriptions for forecast", "error", err)
+		return nil, err
+	}
+
+	costs := make([]int64, months)
+	resp := &model.ForecastResponse{Currency: currency}
+	for _, sub := range subs {
+		if sub.AutoRenew {
+			sub.EndDate = nil
+		}
+		if err := expandUserCosts(sub, from, to, now, amortize, func(month time.Time, cost int64) error {
+			total, err := addCost(resp.TotalCost, cost)
+			if err != nil {
+				return err
+			}
+			resp.TotalCost = total
+			costs[monthIndex(month)-monthIndex(from)] += cost
+			return nil
+		}); err != nil {
+			log.Error("failed to expand subscription months", "id", sub.ID.String(), "error", err)
+			return nil, fmt.Errorf("%s: subscription %s: %w", op, sub.ID, err)
+		}
+	}
+
+	resp.Months = make([]model.MonthlyCost, months)
+	for i := range costs {
+		resp.Months[i] = model.MonthlyCost{Month: model.FormatMonthYear(monthFromIndex(monthIndex(from) + i)), Cost: costs[i]}
+	}
+
+	log.Info("got forecast successfully", "total_cost", resp.TotalCost)
+	return resp, nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"reflect"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+)
+
+// month returns the MonthYear of month in year.
+func month(year int, month time.Month) model.MonthYear {
+	return model.NewMonthYear(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// costCase is a subscription whose cost over a period is known. The cases
+// are kept in internal/testdata/cost_cases.json, shared with the
+// repository's integration tests holding the SQL to the same figures.
+type costCase struct {
+	Name     string             `json:"name"`
+	Sub      model.Subscription `json:"subscription"`
+	From     model.MonthYear    `json:"from"`
+	To       model.MonthYear    `json:"to"`
+	Amortize bool               `json:"amortize"`
+	Want     int64              `json:"want"`
+}
+
+// costCases loads the shared cost cases, failing t when they cannot be
+// read.
+func costCases(t *testing.T) []costCase {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/cost_cases.json")
+	if err != nil {
+		t.Fatalf("failed to read cost cases: %v", err)
+	}
+	var cases []costCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatalf("failed to decode cost cases: %v", err)
+	}
+	return cases
+}
+
+func TestMonthCost(t *testing.T) {
+	for _, tt := range costCases(t) {
+		t.Run(tt.Name, func(t *testing.T) {
+			var total int64
+			err := expandMonths(tt.Sub, tt.From.Time(), tt.To.Time(), testNow, func(month time.Time) error {
+				var err error
+				total, err = addCost(total, monthCost(tt.Sub, month, tt.Amortize))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("expandMonths() error = %v", err)
+			}
+			if total != tt.Want {
+				t.Errorf("cost = %d, want %d", total, tt.Want)
+			}
+		})
+	}
+}
+
+// An open-ended subscription queried without an upper bound is billed up to
+// the current month and no further.
+func TestMonthCostUpToNow(t *testing.T) {
+	sub := model.Subscription{PriceMinor: 1000, BillingPeriod: model.BillingMonthly, StartDate: month(2025, 1)}
+
+	var months []string
+	var total int64
+	err := expandMonths(sub, month(2025, 1).Time(), time.Time{}, testNow, func(m time.Time) error {
+		months = append(months, model.NewMonthYear(m).String())
+		total += monthCost(sub, m, false)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expandMonths() error = %v", err)
+	}
+	if len(months) != 6 || months[len(months)-1] != "06-2025" {
+		t.Errorf("months = %v, want 01-2025 through 06-2025", months)
+	}
+	if total != 6000 {
+		t.Errorf("cost = %d, want 6000", total)
+	}
+}
+
+func TestGetForecastAutoRenew(t *testing.T) {
+	// The forecast from June 2025 runs July through September; the
+	// subscription ends in August.
+	tests := []struct {
+		name      string
+		autoRenew bool
+		want      []int64
+	}{
+		{name: "auto-renewing runs through the forecast", autoRenew: true, want: []int64{1000, 1000, 1000}},
+		{name: "expiring stops at its end date", autoRenew: false, want: []int64{1000, 1000, 0}},
+	}
+
+	end := month(2025, 8)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := liveSubscription()
+			sub.PriceMinor, sub.EndDate, sub.AutoRenew = 1000, &end, tt.autoRenew
+			svc := newTestService(newFakeStore(sub), 0)
+
+			forecast, err := svc.GetForecast(context.Background(), sub.UserID, "RUB", 3, false)
+			if err != nil {
+				t.Fatalf("GetForecast() error = %v", err)
+			}
+			var total int64
+			got := make([]int64, len(forecast.Months))
+			for i, month := range forecast.Months {
+				got[i] = month.Cost
+				total += month.Cost
+			}
+			if !reflect.DeepEqual(got, tt.want) || forecast.TotalCost != total {
+				t.Errorf("GetForecast() = %v totalling %d, want %v", got, forecast.TotalCost, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		cost    int64
+		want    int64
+		wantErr error
+	}{
+		{name: "small", total: 1000, cost: 500, want: 1500},
+		{name: "up to the maximum", total: math.MaxInt64 - 500, cost: 500, want: math.MaxInt64},
+		{name: "past the maximum", total: math.MaxInt64 - 500, cost: 501, wantErr: domain.ErrCostOverflow},
+		{name: "maximum plus one", total: math.MaxInt64, cost: 1, wantErr: domain.ErrCostOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addCost(tt.total, tt.cost)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("addCost() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("addCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAverageMonthlyCost(t *testing.T) {
+	// Charged 1000 a month from January to March 2025.
+	sub := liveSubscription()
+	end := month(2025, 3)
+	sub.PriceMinor, sub.EndDate = 1000, &end
+	svc := newTestService(newFakeStore(sub), 0)
+	// The active months come from the breakdown, expanded here.
+	svc.expandCostsInGo = true
+
+	tests := []struct {
+		name        string
+		from, to    model.MonthYear
+		basis       string
+		wantTotal   int64
+		wantMonths  int
+		wantAverage float64
+	}{
+		{name: "window", from: month(2025, 1), to: month(2025, 6), basis: model.AverageBasisWindow, wantTotal: 3000, wantMonths: 6, wantAverage: 500},
+		{name: "active months", from: month(2025, 1), to: month(2025, 6), basis: model.AverageBasisActiveMonths, wantTotal: 3000, wantMonths: 3, wantAverage: 1000},
+		{name: "empty window", from: month(2024, 1), to: month(2024, 6), basis: model.AverageBasisWindow, wantTotal: 0, wantMonths: 6, wantAverage: 0},
+		{name: "no active months", from: month(2024, 1), to: month(2024, 6), basis: model.AverageBasisActiveMonths, wantTotal: 0, wantMonths: 0, wantAverage: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.GetAverageMonthlyCost(context.Background(), sub.UserID, "", "RUB", tt.from.Time(), tt.to.Time(), tt.basis)
+			if err != nil {
+				t.Fatalf("GetAverageMonthlyCost() error = %v", err)
+			}
+			if got.TotalCost != tt.wantTotal || got.Months != tt.wantMonths || got.AverageMonthlyCost != tt.wantAverage {
+				t.Errorf("GetAverageMonthlyCost() = %d over %d months averaging %v, want %d over %d averaging %v",
+					got.TotalCost, got.Months, got.AverageMonthlyCost, tt.wantTotal, tt.wantMonths, tt.wantAverage)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// EraseUserData removes everything stored about userID for good: their
+// subscriptions, including soft-deleted and archived ones, their shares
+// in the subscriptions of others, the user itself, the audit entries
+// recorded for them, the idempotency keys pointing at them and any
+// cached results. It all happens in one transaction, which closes with an
+// audit entry recording the erasure and the number of rows removed, but
+// none of their content.
+func (s *SubscriptionService) EraseUserData(ctx context.Context, userID uuid.UUID) (*model.ErasureSummary, error) {
+	const op = "service.EraseUserData"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("erasing user data", "user_id", userID.String())
+	summary := &model.ErasureSummary{UserID: userID}
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if summary.AuditEntries, err = s.audit.DeleteByUser(ctx, userID); err != nil {
+			return err
+		}
+		if summary.IdempotencyKeys, err = s.writer.DeleteIdempotencyKeysByUser(ctx, userID); err != nil {
+			return err
+		}
+		if summary.Subscriptions, err = s.writer.PurgeByUser(ctx, userID); err != nil {
+			return err
+		}
+
+		entry := &model.AuditEntry{Action: model.AuditActionErase}
+		if entry.NewValue, err = json.Marshal(summary); err != nil {
+			return fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		return s.appendAudit(ctx, entry)
+	})
+	if err != nil {
+		log.Error("failed to erase user data", "error", err)
+		return nil, err
+	}
+	s.totalCost.invalidate(userID)
+
+	log.Info("erased user data successfully", "user_id", userID.String(), "subscriptions", summary.Subscriptions, "audit_entries", summary.AuditEntries, "idempotency_keys", summary.IdempotencyKeys)
+	return summary, nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/big"
+	"slices"
+	"subscriptions-service/internal/model"
+	"time"
+)
+
+type ExchangeRateRepository interface {
+	Upsert(ctx context.Context, rates []model.ExchangeRate) error
+	Latest(ctx context.Context, currencies []string, target string, day time.Time) ([]model.ExchangeRate, error)
+}
+
+// RateSource fetches current exchange rates from outside the service.
+type RateSource interface {
+	Fetch(ctx context.Context) ([]model.ExchangeRate, error)
+}
+
+// ErrConversionOverflow is returned when a converted total does not fit
+// into an int64.
+var ErrConversionOverflow = errors.New("converted total is too large")
+
+// ExchangeRateService stores exchange rates and converts totals between
+// currencies with them.
+type ExchangeRateService struct {
+	repo   ExchangeRateRepository
+	source RateSource // nil unless rates are fetched automatically
+	log    *slog.Logger
+	now    func() time.Time
+}
+
+func NewExchangeRateService(repo ExchangeRateRepository, source RateSource, log *slog.Logger) *ExchangeRateService {
+	return &ExchangeRateService{repo: repo, source: source, log: log, now: time.Now}
+}
+
+// Upsert stores rates, replacing the rates of the same pairs and days. Of
+// several rates for the same pair and day the last one wins. It returns a
+// model.ValidationError for invalid rates and stores nothing then.
+func (s *ExchangeRateService) Upsert(ctx context.Context, rates []model.ExchangeRate) ([]model.ExchangeRate, error) {
+	const op = "service.UpsertExchangeRates"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("upserting exchange rates", "count", len(rates))
+	type key struct{ base, quote, asOf string }
+	index := make(map[key]int, len(rates))
+	unique := make([]model.ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		if err := rate.Validate(); err != nil {
+			log.Warn("invalid exchange rate", "error", err)
+			return nil, err
+		}
+		k := key{rate.Base, rate.Quote, rate.AsOf}
+		if i, ok := index[k]; ok {
+			unique[i] = rate
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, rate)
+	}
+
+	if err := s.repo.Upsert(ctx, unique); err != nil {
+		log.Error("failed to upsert exchange rates", "error", err)
+		return nil, err
+	}
+	log.Info("upserted exchange rates successfully", "count", len(unique))
+	return unique, nil
+}
+
+// Refresh fetches the current rates from the rate source and stores them.
+// It returns how many rates were stored.
+func (s *ExchangeRateService) Refresh(ctx context.Context) (int, error) {
+	const op = "service.RefreshExchangeRates"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("refreshing exchange rates")
+	rates, err := s.source.Fetch(ctx)
+	if err != nil {
+		log.Error("failed to fetch exchange rates", "error", err)
+		return 0, err
+	}
+	if len(rates) == 0 {
+		log.Warn("rate source returned no exchange rates")
+		return 0, nil
+	}
+	stored, err := s.Upsert(ctx, rates)
+	if err != nil {
+		return 0, err
+	}
+	log.Info("refreshed exchange rates successfully", "count", len(stored))
+	return len(stored), nil
+}
+
+// Convert converts totals, in minor units per currency, into target with
+// the latest rate known for each currency and rounds the sum half away
+// from zero. Rates stored the other way round are used inverted. It
+// returns a *model.MissingRatesError naming every pair without a rate.
+func (s *ExchangeRateService) Convert(ctx context.Context, totals map[string]int64, target string) (*model.ConvertedTotal, error) {
+	const op = "service.ConvertTotal"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("converting total", "currencies", len(totals), "target", target)
+	currencies := slices.Sorted(maps.Keys(totals))
+	converted := &model.ConvertedTotal{Currency: target, Rates: make([]model.ExchangeRate, 0)}
+	foreign := slices.DeleteFunc(slices.Clone(currencies), func(code string) bool { return code == target })
+	if len(foreign) == 0 {
+		converted.Total = totals[target]
+		return converted, nil
+	}
+
+	latest, err := s.repo.Latest(ctx, foreign, target, s.now())
+	if err != nil {
+		log.Error("failed to get exchange rates", "error", err)
+		return nil, err
+	}
+	direct := make(map[string]model.ExchangeRate, len(latest))
+	inverse := make(map[string]model.ExchangeRate, len(latest))
+	for _, rate := range latest {
+		if rate.Quote == target {
+			direct[rate.Base] = rate
+		} else {
+			inverse[rate.Quote] = rate
+		}
+	}
+
+	sum := new(big.Rat)
+	var missing []string
+	for _, code := range currencies {
+		amount := new(big.Rat).SetInt64(totals[code])
+		if code == target {
+			sum.Add(sum, amount)
+			continue
+		}
+		rate, ok := direct[code]
+		invert := false
+		if !ok {
+			if rate, ok = inverse[code]; !ok {
+				missing = append(missing, code+"/"+target)
+				continue
+			}
+			invert = true
+		}
+		factor, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok || factor.Sign() == 0 {
+			return nil, fmt.Errorf("%s: invalid stored rate %q for %s/%s", op, rate.Rate, rate.Base, rate.Quote)
+		}
+		if invert {
+			factor.Inv(factor)
+		}
+		sum.Add(sum, amount.Mul(amount, factor))
+		converted.Rates = append(converted.Rates, rate)
+	}
+	if len(missing) > 0 {
+		log.Warn("missing exchange rates", "pairs", missing)
+		return nil, &model.MissingRatesError{Pairs: missing}
+	}
+
+	total, ok := roundRat(sum)
+	if !ok {
+		log.Error("converted total overflows")
+		return nil, ErrConversionOverflow
+	}
+	converted.Total = total
+	log.Info("converted total successfully", "total", total)
+	return converted, nil
+}
+
+// roundRat rounds x half away from zero and reports whether the result
+// fits into an int64.
+func roundRat(x *big.Rat) (int64, bool) {
+	quo, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		twice := new(big.Int).Abs(rem)
+		twice.Lsh(twice, 1)
+		if twice.Cmp(x.Denom()) >= 0 {
+			quo.Add(quo, big.NewInt(int64(x.Sign())))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, false
+	}
+	return quo.Int64(), true
+}
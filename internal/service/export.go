@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+)
+
+func (s *SubscriptionService) Export(ctx context.Context, fn func(sub model.Subscription) error) error {
+	const op = "service.Export"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("exporting subscriptions")
+	count := 0
+	err := s.reader.Export(ctx, func(sub model.Subscription) error {
+		count++
+		return fn(sub)
+	})
+	if err != nil {
+		log.Error("failed to export subscriptions", "error", err, "exported", count)
+		return err
+	}
+
+	log.Info("exported subscriptions successfully", "count", count)
+	return nil
+}
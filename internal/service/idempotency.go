@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// CreateIdempotent creates sub once per idempotency key. Replaying a key
+// within its TTL returns the ID of the subscription created the first time
+// with replayed set to true; replaying it with a different request hash
+// fails with domain.ErrIdempotencyKeyReused. New subscriptions are
+// checked for overlaps like in Create. Either way sub is overwritten with
+// the stored subscription.
+func (s *SubscriptionService) CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, allowOverlap bool) (id uuid.UUID, replayed bool, err error) {
+	const op = "service.CreateIdempotent"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("creating subscription with idempotency key")
+	notBefore := s.now().Add(-s.idempotencyKeyTTL)
+	if err := s.resolveService(ctx, sub); err != nil {
+		log.Error("failed to resolve service", "error", err)
+		return uuid.Nil, false, err
+	}
+	if err := s.validateUser(ctx, sub.UserID); err != nil {
+		log.Warn("failed to validate user", "user_id", sub.UserID.String(), "error", err)
+		return uuid.Nil, false, err
+	}
+	if !allowOverlap {
+		// A replay must not be reported as overlapping the subscription
+		// its first attempt created, so only check keys not seen before.
+		_, _, err := s.reader.GetIdempotencyKey(ctx, key, notBefore)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			if err := s.checkOverlap(ctx, sub); err != nil {
+				log.Warn("subscription overlaps", "error", err)
+				return uuid.Nil, false, err
+			}
+		case err != nil:
+			log.Error("failed to look up idempotency key", "error", err)
+			return uuid.Nil, false, err
+		}
+	}
+
+	err = s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if id, replayed, err = s.writer.CreateIdempotent(ctx, sub, key, requestHash, notBefore); err != nil || replayed {
+			return err
+		}
+		if err := s.enforceQuota(ctx, *sub); err != nil {
+			return err
+		}
+		return s.recordCreated(ctx, id)
+	})
+	if err != nil {
+		log.Error("failed to create subscription", "error", err)
+		return uuid.Nil, false, err
+	}
+	if replayed {
+		// Answer a replay with the subscription as it is now, like a
+		// fresh create answers with the stored row.
+		stored, err := s.reader.GetByID(ctx, id, true)
+		if err != nil {
+			log.Error("failed to get replayed subscription", "id", id, "error", err)
+			return uuid.Nil, false, err
+		}
+		*sub = *stored
+		log.Info("replayed idempotent create", "id", id)
+		return id, true, nil
+	}
+
+	s.totalCost.invalidate(sub.UserID)
+	log.Info("subscription created successfully", "id", id)
+	return id, false, nil
+}
+
+// CleanupIdempotencyKeys removes the idempotency keys that outlived their
+// TTL and returns how many were removed.
+func (s *SubscriptionService) CleanupIdempotencyKeys(ctx context.Context) (int64, error) {
+	const op = "service.CleanupIdempotencyKeys"
+	log := s.log.With(slog.String("op", op))
+
+	deleted, err := s.writer.DeleteIdempotencyKeys(ctx, s.now().Add(-s.idempotencyKeyTTL))
+	if err != nil {
+		log.Error("failed to delete expired idempotency keys", "error", err)
+		return 0, err
+	}
+
+	log.Info("deleted expired idempotency keys", "count", deleted)
+	return deleted, nil
+}
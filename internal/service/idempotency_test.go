@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateIdempotent(t *testing.T) {
+	userID := uuid.New()
+	newSub := func() *model.Subscription {
+		return &model.Subscription{UserID: userID, ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", StartDate: month(2025, 1)}
+	}
+	store := newFakeStore()
+	audit := &fakeAuditLog{}
+	svc := newTestService(store, 0)
+	svc.audit = audit
+	ctx := context.Background()
+
+	first := newSub()
+	id, replayed, err := svc.CreateIdempotent(ctx, first, "key-1", "hash-1", false)
+	if err != nil || replayed {
+		t.Fatalf("CreateIdempotent() = %v, %v, want a new subscription", replayed, err)
+	}
+
+	// The replay must not count the subscription its first attempt created
+	// as an overlap.
+	replay := newSub()
+	replayID, replayed, err := svc.CreateIdempotent(ctx, replay, "key-1", "hash-1", false)
+	if err != nil || !replayed || replayID != id {
+		t.Fatalf("replayed CreateIdempotent() = %s, %v, %v, want %s replayed", replayID, replayed, err, id)
+	}
+	if replay.ID != id || replay.Version != first.Version {
+		t.Errorf("replayed subscription = %s version %d, want the stored %s version %d", replay.ID, replay.Version, id, first.Version)
+	}
+
+	if _, _, err := svc.CreateIdempotent(ctx, newSub(), "key-1", "hash-2", false); !errors.Is(err, domain.ErrIdempotencyKeyReused) {
+		t.Errorf("CreateIdempotent() with another request error = %v, want %v", err, domain.ErrIdempotencyKeyReused)
+	}
+
+	if n := len(store.all(ctx)); n != 1 {
+		t.Errorf("stored subscriptions = %d, want 1", n)
+	}
+	if n := len(audit.entries); n != 1 || audit.entries[0].Action != model.AuditActionCreate {
+		t.Errorf("audit entries = %+v, want one creation", audit.entries)
+	}
+}
+
+func TestCreateIdempotentExpiredKey(t *testing.T) {
+	store := newFakeStore()
+	svc := newTestService(store, 0)
+	ctx := context.Background()
+	sub := &model.Subscription{UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", StartDate: month(2025, 1)}
+
+	id, _, err := svc.CreateIdempotent(ctx, sub, "key-1", "hash-1", true)
+	if err != nil {
+		t.Fatalf("CreateIdempotent() error = %v", err)
+	}
+	// Once the key outlived its TTL, it names a new request.
+	svc.now = func() time.Time { return testNow.Add(svc.idempotencyKeyTTL + time.Second) }
+	again := *sub
+	againID, replayed, err := svc.CreateIdempotent(ctx, &again, "key-1", "hash-1", true)
+	if err != nil || replayed || againID == id {
+		t.Errorf("CreateIdempotent() after the TTL = %s, %v, %v, want a new subscription", againID, replayed, err)
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+)
+
+func (s *SubscriptionService) Import(ctx context.Context, subs []model.Subscription) error {
+	const op = "service.Import"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("importing subscriptions", "count", len(subs))
+	if len(subs) == 0 {
+		return nil
+	}
+	if err := s.resolveServices(ctx, subs); err != nil {
+		log.Error("failed to resolve services", "error", err)
+		return err
+	}
+	if err := s.validateUsers(ctx, subs); err != nil {
+		log.Warn("failed to validate users", "error", err)
+		return err
+	}
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		ids, err := s.writer.CreateMany(ctx, subs)
+		if err != nil {
+			return err
+		}
+		if err := s.enforceQuota(ctx, subs...); err != nil {
+			return err
+		}
+		return s.recordCreatedMany(ctx, ids)
+	})
+	if err != nil {
+		log.Error("failed to import subscriptions", "error", err)
+		return err
+	}
+
+	s.invalidateUsers(subs)
+
+	log.Info("imported subscriptions successfully", "count", len(subs))
+	return nil
+}
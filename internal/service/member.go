@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ListMembers returns the users sharing the subscription id with its
+// owner. It returns domain.ErrNotFound when no such subscription exists.
+func (s *SubscriptionService) ListMembers(ctx context.Context, id uuid.UUID) ([]model.SubscriptionMember, error) {
+	const op = "service.ListMembers"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("listing subscription members", "id", id.String())
+	if _, err := s.reader.GetByID(ctx, id, false); err != nil {
+		log.Error("failed to get subscription", "error", err)
+		return nil, err
+	}
+	members, err := s.reader.ListMembers(ctx, id)
+	if err != nil {
+		log.Error("failed to list subscription members", "error", err)
+		return nil, err
+	}
+	log.Info("listed subscription members successfully", "count", len(members))
+	return members, nil
+}
+
+// AddMember shares the subscription member.SubscriptionID with
+// member.UserID, or changes the share of a user who is a member already,
+// and reports whether the member was added. The shares of all members
+// must not exceed 100%; the owner pays whatever is left.
+func (s *SubscriptionService) AddMember(ctx context.Context, member *model.SubscriptionMember) (bool, error) {
+	const op = "service.AddMember"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("adding subscription member", "id", member.SubscriptionID.String(), "user_id", member.UserID.String(), "share_percent", member.SharePercent)
+	sub, err := s.reader.GetByID(ctx, member.SubscriptionID, false)
+	if err != nil {
+		log.Error("failed to get subscription", "error", err)
+		return false, err
+	}
+	added, err := s.writer.SetMember(ctx, member)
+	if err != nil {
+		log.Error("failed to add subscription member", "error", err)
+		return false, err
+	}
+	s.totalCost.invalidate(sub.UserID, member.UserID)
+	log.Info("added subscription member successfully", "added", added)
+	return added, nil
+}
+
+// RemoveMember stops sharing the subscription id with userID, whose share
+// falls back to the owner. It returns domain.ErrNotFound when userID is
+// not a member.
+func (s *SubscriptionService) RemoveMember(ctx context.Context, id, userID uuid.UUID) error {
+	const op = "service.RemoveMember"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("removing subscription member", "id", id.String(), "user_id", userID.String())
+	sub, err := s.reader.GetByID(ctx, id, true)
+	if err != nil {
+		log.Error("failed to get subscription", "error", err)
+		return err
+	}
+	if err := s.writer.RemoveMember(ctx, id, userID); err != nil {
+		log.Error("failed to remove subscription member", "error", err)
+		return err
+	}
+	s.totalCost.invalidate(sub.UserID, userID)
+	log.Info("removed subscription member successfully")
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// GetNotificationPreferences returns the notification preferences of
+// userID, or the defaults when they have not stored any. The reminder
+// worker decides with them whom to notify and how.
+func (s *SubscriptionService) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	const op = "service.GetNotificationPreferences"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting notification preferences", "user_id", userID.String())
+	prefs, err := s.reader.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		log.Info("no notification preferences stored, using defaults")
+		return model.DefaultNotificationPreferences(userID), nil
+	}
+	if err != nil {
+		log.Error("failed to get notification preferences", "error", err)
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreferences stores prefs, replacing the preferences of
+// its user if they have any, and reports whether they were created. It
+// returns a model.ValidationError for preferences out of range.
+func (s *SubscriptionService) SetNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) (bool, error) {
+	const op = "service.SetNotificationPreferences"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("setting notification preferences", "user_id", prefs.UserID.String())
+	if err := prefs.Validate(); err != nil {
+		log.Warn("invalid notification preferences", "error", err)
+		return false, err
+	}
+	if err := s.validateUser(ctx, prefs.UserID); err != nil {
+		log.Warn("failed to validate user", "user_id", prefs.UserID.String(), "error", err)
+		return false, err
+	}
+	created, err := s.writer.SetNotificationPreferences(ctx, prefs)
+	if err != nil {
+		log.Error("failed to set notification preferences", "error", err)
+		return false, err
+	}
+	prefs.IsDefault = false
+	log.Info("set notification preferences successfully", "created", created)
+	return created, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/model"
+	"testing"
+)
+
+func TestCreateRejectsOverlap(t *testing.T) {
+	ptr := func(m model.MonthYear) *model.MonthYear { return &m }
+	existing := liveSubscription()
+	existing.EndDate = ptr(month(2025, 6))
+
+	tests := []struct {
+		name         string
+		sub          model.Subscription
+		allowOverlap bool
+		wantOverlap  bool
+	}{
+		{name: "overlapping", sub: model.Subscription{ServiceName: "Netflix", StartDate: month(2025, 6)}, wantOverlap: true},
+		{name: "overlapping allowed", sub: model.Subscription{ServiceName: "Netflix", StartDate: month(2025, 6)}, allowOverlap: true},
+		{name: "after the existing one", sub: model.Subscription{ServiceName: "Netflix", StartDate: month(2025, 7)}},
+		{name: "another service", sub: model.Subscription{ServiceName: "Spotify", StartDate: month(2025, 3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore(existing)
+			svc := newTestService(store, 0)
+			sub := tt.sub
+			sub.UserID, sub.PriceMinor, sub.Currency = existing.UserID, 500, "RUB"
+
+			_, err := svc.Create(context.Background(), &sub, tt.allowOverlap)
+			var overlapErr *model.OverlapError
+			if errors.As(err, &overlapErr) != tt.wantOverlap {
+				t.Fatalf("Create() error = %v, want overlap: %t", err, tt.wantOverlap)
+			}
+			if tt.wantOverlap {
+				if len(overlapErr.IDs) != 1 || overlapErr.IDs[0] != existing.ID {
+					t.Errorf("conflicting IDs = %v, want [%s]", overlapErr.IDs, existing.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateOverlap(t *testing.T) {
+	ptr := func(m model.MonthYear) *model.MonthYear { return &m }
+	first := liveSubscription()
+	first.EndDate = ptr(month(2025, 6))
+	second := liveSubscription()
+	second.UserID, second.StartDate = first.UserID, month(2025, 7)
+	store := newFakeStore(first, second)
+	svc := newTestService(store, 0)
+
+	// A subscription never overlaps itself.
+	moved := first
+	moved.StartDate = month(2025, 2)
+	if err := svc.Update(context.Background(), &moved, false); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	extended := second
+	extended.StartDate = month(2025, 5)
+	var overlapErr *model.OverlapError
+	if err := svc.Update(context.Background(), &extended, false); !errors.As(err, &overlapErr) || len(overlapErr.IDs) != 1 || overlapErr.IDs[0] != first.ID {
+		t.Errorf("Update() error = %v, want an overlap with %s", err, first.ID)
+	}
+	if err := svc.Update(context.Background(), &extended, true); err != nil {
+		t.Errorf("Update() allowing overlaps error = %v", err)
+	}
+}
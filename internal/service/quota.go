@@ -0,0 +1,38 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// enforceQuota fails with a *model.QuotaExceededError when subs, just
+// stored within the transaction of ctx, leave one of their owners with
+// more active subscriptions than allowed. Counting after the insert lets
+// replays and existing subscriptions pass without a special case. Owners
+// are locked in a fixed order, so that concurrent creations neither exceed
+// the quota together nor deadlock.
+func (s *SubscriptionService) enforceQuota(ctx context.Context, subs ...model.Subscription) error {
+	added := make(map[uuid.UUID]int, len(subs))
+	for i := range subs {
+		added[subs[i].UserID]++
+	}
+	userIDs := slices.SortedFunc(maps.Keys(added), func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	for _, userID := range userIDs {
+		count, limit, err := s.writer.CountActiveForQuota(ctx, userID, s.maxActivePerUser)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && count > limit {
+			return &model.QuotaExceededError{UserID: userID, Count: count - added[userID], Limit: limit}
+		}
+	}
+	return nil
+}
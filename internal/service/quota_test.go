@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/model"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateEnforcesQuotaUnderConcurrency(t *testing.T) {
+	const limit, creates = 3, 8
+	userID := uuid.New()
+	existing := make([]model.Subscription, 0, limit-1)
+	for i := 0; i < limit-1; i++ {
+		existing = append(existing, model.Subscription{ID: uuid.New(), UserID: userID, ServiceName: "Existing", Status: model.StatusActive})
+	}
+	store := newFakeStore(existing...)
+	svc := newTestService(store, limit)
+
+	// The user is one subscription short of the limit, so exactly one of
+	// the concurrent creations may succeed.
+	var wg sync.WaitGroup
+	errs := make([]error, creates)
+	for i := range creates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub := &model.Subscription{UserID: userID, ServiceName: "Netflix", PriceMinor: 500}
+			_, errs[i] = svc.Create(context.Background(), sub, true)
+		}()
+	}
+	wg.Wait()
+
+	created, exceeded := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, model.ErrQuotaExceeded):
+			exceeded++
+			var quotaErr *model.QuotaExceededError
+			if !errors.As(err, &quotaErr) || quotaErr.Count != limit || quotaErr.Limit != limit {
+				t.Errorf("Create() error = %v, want %d of %d active subscriptions", err, limit, limit)
+			}
+		default:
+			t.Errorf("Create() unexpected error = %v", err)
+		}
+	}
+	if created != 1 || exceeded != creates-1 {
+		t.Fatalf("created %d and rejected %d subscriptions, want 1 and %d", created, exceeded, creates-1)
+	}
+
+	stored := 0
+	for _, sub := range store.all(context.Background()) {
+		if sub.UserID == userID {
+			stored++
+		}
+	}
+	if stored != limit {
+		t.Errorf("user has %d subscriptions, want %d", stored, limit)
+	}
+}
+
+func TestCreateAllowsSubscriptionsUpToQuota(t *testing.T) {
+	const limit = 2
+	userID := uuid.New()
+	svc := newTestService(newFakeStore(), limit)
+
+	for i := 0; i < limit; i++ {
+		sub := &model.Subscription{UserID: userID, ServiceName: "Netflix", PriceMinor: 500}
+		if _, err := svc.Create(context.Background(), sub, true); err != nil {
+			t.Fatalf("Create() #%d error = %v", i+1, err)
+		}
+	}
+	sub := &model.Subscription{UserID: userID, ServiceName: "Netflix", PriceMinor: 500}
+	if _, err := svc.Create(context.Background(), sub, true); !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Fatalf("Create() above the quota error = %v, want %v", err, model.ErrQuotaExceeded)
+	}
+}
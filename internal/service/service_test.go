@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testNow is the clock of the services under test.
+var testNow = time.Date(2025, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+// newTestService returns a service storing subscriptions in store, which
+// accepts every user and allows maxActive active subscriptions per user.
+func newTestService(store *fakeStore, maxActive int) *SubscriptionService {
+	s := NewSubscriptionService(store, store, fakeCatalog{}, &fakeAuditLog{}, fakeUsers{},
+		config.CacheConfig{TotalCostTTL: time.Minute},
+		config.CostConfig{},
+		config.IdempotencyConfig{KeyTTL: time.Hour},
+		config.ArchiveConfig{BatchSize: 100},
+		config.QuotaConfig{MaxActivePerUser: maxActive},
+		config.BudgetConfig{WarningThresholdPercent: 80},
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+	)
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+// fakeStore keeps subscriptions in memory. It implements the methods of
+// SubscriptionReader and SubscriptionWriter the tests use; the others
+// panic. Like the repository, WithTx keeps writes invisible to others until
+// fn succeeds, and CountActiveForQuota and GetForUpdate lock the user and
+// the row until the transaction ends.
+type fakeStore struct {
+	SubscriptionReader
+	SubscriptionWriter
+
+	mu        sync.Mutex
+	subs      map[uuid.UUID]model.Subscription
+	locks     map[uuid.UUID]*sync.Mutex
+	keys      map[string]fakeIdempotencyKey
+	members   []model.SubscriptionMember
+	costReads int // calls of GetTotalCostByCurrency
+
+	// beforeUpdate, when set, runs at the start of Update, letting tests
+	// slip in changes made concurrently.
+	beforeUpdate func(id uuid.UUID)
+}
+
+func newFakeStore(subs ...model.Subscription) *fakeStore {
+	store := &fakeStore{
+		subs:  make(map[uuid.UUID]model.Subscription),
+		locks: make(map[uuid.UUID]*sync.Mutex),
+		keys:  make(map[string]fakeIdempotencyKey),
+	}
+	for _, sub := range subs {
+		store.subs[sub.ID] = sub
+	}
+	return store
+}
+
+// fakeIdempotencyKey is an idempotency key stored by fakeStore.
+type fakeIdempotencyKey struct {
+	requestHash string
+	id          uuid.UUID
+	createdAt   time.Time
+}
+
+// fakeTx is the state of a transaction of fakeStore.
+type fakeTx struct {
+	writes map[uuid.UUID]model.Subscription
+	locked map[uuid.UUID]*sync.Mutex
+}
+
+type fakeTxKey struct{}
+
+func txOf(ctx context.Context) *fakeTx {
+	tx, _ := ctx.Value(fakeTxKey{}).(*fakeTx)
+	return tx
+}
+
+func (f *fakeStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txOf(ctx) != nil {
+		return fn(ctx)
+	}
+	tx := &fakeTx{writes: make(map[uuid.UUID]model.Subscription), locked: make(map[uuid.UUID]*sync.Mutex)}
+	defer func() {
+		for _, lock := range tx.locked {
+			lock.Unlock()
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, fakeTxKey{}, tx)); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, sub := range tx.writes {
+		f.subs[id] = sub
+	}
+	return nil
+}
+
+// get returns the subscription id as seen from ctx.
+func (f *fakeStore) get(ctx context.Context, id uuid.UUID) (model.Subscription, bool) {
+	if tx := txOf(ctx); tx != nil {
+		if sub, ok := tx.writes[id]; ok {
+			return sub, true
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub, ok := f.subs[id]
+	return sub, ok
+}
+
+// put stores sub within the transaction of ctx, or right away outside of
+// one.
+func (f *fakeStore) put(ctx context.Context, sub model.Subscription) {
+	if tx := txOf(ctx); tx != nil {
+		tx.writes[sub.ID] = sub
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[sub.ID] = sub
+}
+
+// all returns every subscription as seen from ctx.
+func (f *fakeStore) all(ctx context.Context) []model.Subscription {
+	f.mu.Lock()
+	seen := make(map[uuid.UUID]model.Subscription, len(f.subs))
+	for id, sub := range f.subs {
+		seen[id] = sub
+	}
+	f.mu.Unlock()
+	if tx := txOf(ctx); tx != nil {
+		for id, sub := range tx.writes {
+			seen[id] = sub
+		}
+	}
+	subs := make([]model.Subscription, 0, len(seen))
+	for _, sub := range seen {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (f *fakeStore) Create(ctx context.Context, sub *model.Subscription) error {
+	sub.ID = uuid.New()
+	if sub.Status == "" {
+		sub.Status = model.StatusActive
+	}
+	sub.Version = 1
+	f.put(ctx, *sub)
+	return nil
+}
+
+func (f *fakeStore) GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*model.Subscription, error) {
+	sub, ok := f.get(ctx, id)
+	if !ok || (sub.DeletedAt != nil && !includeDeleted) {
+		return nil, domain.ErrNotFound
+	}
+	return &sub, nil
+}
+
+func (f *fakeStore) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Subscription, error) {
+	subs := make([]model.Subscription, 0, len(ids))
+	for _, id := range ids {
+		if sub, ok := f.get(ctx, id); ok && sub.DeletedAt == nil {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (f *fakeStore) Update(ctx context.Context, sub *model.Subscription) error {
+	if f.beforeUpdate != nil {
+		f.beforeUpdate(sub.ID)
+	}
+	stored, ok := f.get(ctx, sub.ID)
+	if !ok || stored.DeletedAt != nil {
+		return domain.ErrNotFound
+	}
+	if sub.Version != 0 && sub.Version != stored.Version {
+		return domain.ErrVersionConflict
+	}
+	sub.UserID = stored.UserID
+	if sub.Status == "" {
+		sub.Status = stored.Status
+	}
+	sub.Version = stored.Version + 1
+	f.put(ctx, *sub)
+	return nil
+}
+
+func (f *fakeStore) Delete(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	sub, ok := f.get(ctx, id)
+	if !ok || sub.DeletedAt != nil {
+		return uuid.Nil, domain.ErrNotFound
+	}
+	deletedAt := testNow
+	sub.DeletedAt = &deletedAt
+	f.put(ctx, sub)
+	return sub.UserID, nil
+}
+
+// GetTotalCostByCurrency sums the price of the live subscriptions of
+// userID, or of everyone's, per currency. Given both ends of a period, it
+// adds up the part of userID within it month by month instead.
+func (f *fakeStore) GetTotalCostByCurrency(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived bool) (map[string]int64, int, bool, error) {
+	f.mu.Lock()
+	f.costReads++
+	f.mu.Unlock()
+
+	totals := make(map[string]int64)
+	counted := 0
+	if userID != nil && from != nil && to != nil {
+		subs, _ := f.GetSubscriptionsForTotalCost(ctx, *userID, serviceName, currency, from, to)
+		for _, sub := range subs {
+			err := expandUserCosts(sub, *from, *to, testNow, amortize, func(month time.Time, cost int64) error {
+				totals[sub.Currency] += cost
+				return nil
+			})
+			if err != nil {
+				return nil, 0, false, err
+			}
+			counted++
+		}
+		return totals, counted, false, nil
+	}
+	for _, sub := range f.all(ctx) {
+		if sub.DeletedAt != nil || (userID != nil && sub.UserID != *userID) {
+			continue
+		}
+		totals[sub.Currency] += int64(sub.PriceMinor)
+		counted++
+	}
+	return totals, counted, false, nil
+}
+
+// GetSubscriptionsForTotalCost returns the live subscriptions userID owns
+// or is one of the members of, along with the part they pay, ignoring the
+// period.
+func (f *fakeStore) GetSubscriptionsForTotalCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time) ([]model.SharedSubscription, error) {
+	var subs []model.SharedSubscription
+	for _, sub := range f.all(ctx) {
+		if sub.DeletedAt != nil || (currency != "" && sub.Currency != currency) {
+			continue
+		}
+		share := model.CostShare{Owned: sub.UserID == userID}
+		for _, member := range f.members {
+			if member.SubscriptionID != sub.ID {
+				continue
+			}
+			share.MemberShares = append(share.MemberShares, member.SharePercent)
+			if member.UserID == userID {
+				share.SharePercent = member.SharePercent
+			}
+		}
+		if share.Owned || share.SharePercent > 0 {
+			subs = append(subs, model.SharedSubscription{Subscription: sub, Share: share})
+		}
+	}
+	return subs, nil
+}
+
+// GetBudget reports that no user has a budget.
+func (f *fakeStore) GetBudget(ctx context.Context, userID uuid.UUID) (*model.Budget, error) {
+	return nil, domain.ErrNotFound
+}
+
+// FindOverlapping returns the other live subscriptions of the same user
+// and service sharing a month with sub.
+func (f *fakeStore) FindOverlapping(ctx context.Context, sub *model.Subscription) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, other := range f.all(ctx) {
+		switch {
+		case other.ID == sub.ID || other.UserID != sub.UserID || other.DeletedAt != nil || !strings.EqualFold(other.ServiceName, sub.ServiceName):
+		case other.EndDate != nil && other.EndDate.Time().Before(sub.StartDate.Time()):
+		case sub.EndDate != nil && sub.EndDate.Time().Before(other.StartDate.Time()):
+		default:
+			ids = append(ids, other.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeStore) GetIdempotencyKey(ctx context.Context, key string, notBefore time.Time) (string, uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.keys[key]
+	if !ok || stored.createdAt.Before(notBefore) {
+		return "", uuid.Nil, domain.ErrNotFound
+	}
+	return stored.requestHash, stored.id, nil
+}
+
+// CreateIdempotent stores the key right away, unlike the repository, which
+// rolls it back with the transaction.
+func (f *fakeStore) CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, notBefore time.Time) (uuid.UUID, bool, error) {
+	if hash, id, err := f.GetIdempotencyKey(ctx, key, notBefore); err == nil {
+		if hash != requestHash {
+			return uuid.Nil, false, domain.ErrIdempotencyKeyReused
+		}
+		return id, true, nil
+	}
+	if err := f.Create(ctx, sub); err != nil {
+		return uuid.Nil, false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[key] = fakeIdempotencyKey{requestHash: requestHash, id: sub.ID, createdAt: testNow}
+	return sub.ID, false, nil
+}
+
+func (f *fakeStore) EnsureUser(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+func (f *fakeStore) CountActiveForQuota(ctx context.Context, userID uuid.UUID, defaultLimit int) (int, int, error) {
+	f.lock(ctx, userID)
+	count := 0
+	for _, sub := range f.all(ctx) {
+		if sub.UserID == userID && sub.DeletedAt == nil && (sub.Status == model.StatusActive || sub.Status == model.StatusTrialing || sub.Status == model.StatusPaused) {
+			count++
+		}
+	}
+	return count, defaultLimit, nil
+}
+
+func (f *fakeStore) GetForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	f.lock(ctx, id)
+	return f.GetByID(ctx, id, false)
+}
+
+// lock locks the row or user id until the transaction of ctx ends.
+func (f *fakeStore) lock(ctx context.Context, id uuid.UUID) {
+	tx := txOf(ctx)
+	if tx == nil {
+		panic("lock taken outside of a transaction")
+	}
+	if _, ok := tx.locked[id]; ok {
+		return
+	}
+	f.mu.Lock()
+	lock, ok := f.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.locks[id] = lock
+	}
+	f.mu.Unlock()
+	lock.Lock()
+	tx.locked[id] = lock
+}
+
+// fakeCatalog knows no services.
+type fakeCatalog struct{}
+
+func (fakeCatalog) Resolve(ctx context.Context, name string) (*model.CatalogEntry, error) {
+	return nil, nil
+}
+
+// fakeUsers knows every user.
+type fakeUsers struct{}
+
+func (fakeUsers) Exists(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return true, nil
+}
+
+// fakeAuditLog keeps the entries appended to it.
+type fakeAuditLog struct {
+	mu      sync.Mutex
+	entries []model.AuditEntry
+}
+
+func (a *fakeAuditLog) Append(ctx context.Context, entry *model.AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry.ID = int64(len(a.entries) + 1)
+	a.entries = append(a.entries, *entry)
+	return nil
+}
+
+func (a *fakeAuditLog) ListBySubscription(ctx context.Context, id uuid.UUID, limit, offset int) ([]model.AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := make([]model.AuditEntry, 0)
+	for _, entry := range a.entries {
+		if entry.SubscriptionID != nil && *entry.SubscriptionID == id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (a *fakeAuditLog) DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return 0, nil
+}
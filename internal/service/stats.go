@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GetStats aggregates the subscriptions priced in currency per service, or
+// per catalog entry and plan as selected by grouping, along with overall
+// totals.
+func (s *SubscriptionService) GetStats(ctx context.Context, userID *uuid.UUID, currency string, grouping model.StatsGrouping) (*model.StatsResponse, error) {
+	const op = "service.GetStats"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting subscription stats", "currency", currency)
+	services, err := s.reader.GetServiceStats(ctx, userID, currency, grouping)
+	if err != nil {
+		log.Error("failed to get service stats", "error", err)
+		return nil, err
+	}
+
+	stats := &model.StatsResponse{Currency: currency, Services: services}
+	for _, st := range services {
+		stats.Count += st.Count
+		stats.TotalPrice += st.TotalPrice
+	}
+	if stats.Count > 0 {
+		stats.AvgPrice = float64(stats.TotalPrice) / float64(stats.Count)
+	}
+
+	log.Info("got subscription stats successfully", "services", len(services))
+	return stats, nil
+}
+
+// GetSpendSeries reports what the user pays in currency for every month
+// between from and to, both inclusive, months without spend included. The
+// months are those of GetCostBreakdown, so the series adds up to the total
+// cost of the period.
+func (s *SubscriptionService) GetSpendSeries(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time) ([]model.MonthlySpend, error) {
+	const op = "service.GetSpendSeries"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting spend series", "user_id", userID.String(), "currency", currency)
+	cells, _, err := s.userCostCells(ctx, userID, "", currency, &from, &to, false)
+	if err != nil {
+		log.Error("failed to get spend series", "error", err)
+		return nil, err
+	}
+
+	first := monthIndex(from)
+	totals := make([]int64, monthIndex(to)-first+1)
+	for _, cell := range cells {
+		total, err := addCost(totals[monthIndex(cell.Month)-first], cell.Cost)
+		if err != nil {
+			log.Error("monthly spend overflows", "month", model.FormatMonthYear(cell.Month))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		totals[monthIndex(cell.Month)-first] = total
+	}
+
+	series := make([]model.MonthlySpend, len(totals))
+	for i, total := range totals {
+		series[i] = model.MonthlySpend{Month: model.FormatMonthYear(monthFromIndex(first + i)), Total: total}
+	}
+
+	log.Info("got spend series successfully", "months", len(series))
+	return series, nil
+}
+
+// GetTopServices ranks the user's services by total spend in currency
+// within the period [from, to]. With byPlan, every plan of a service is
+// ranked on its own. Ties are broken alphabetically by service name, then
+// by plan.
+func (s *SubscriptionService) GetTopServices(ctx context.Context, userID uuid.UUID, currency string, from, to time.Time, limit int, byPlan bool) ([]model.ServiceSpend, error) {
+	const op = "service.GetTopServices"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting top services", "user_id", userID.String(), "currency", currency)
+	subs, err := s.reader.GetSubscriptionsForTotalCost(ctx, userID, "", currency, nil, nil)
+	if err != nil {
+		log.Error("failed to get subscriptions for top services", "error", err)
+		return nil, err
+	}
+
+	// Subscriptions without a plan, and every subscription unless byPlan,
+	// are keyed by an empty plan, which no stored plan can be.
+	type serviceKey struct{ name, plan string }
+	totals := make(map[serviceKey]int64)
+	for _, sub := range subs {
+		key := serviceKey{name: sub.ServiceName}
+		if byPlan && sub.Plan != nil {
+			key.plan = *sub.Plan
+		}
+		if err := expandUserCosts(sub, from, to, s.now(), false, func(_ time.Time, cost int64) error {
+			total, err := addCost(totals[key], cost)
+			if err != nil {
+				return err
+			}
+			totals[key] = total
+			return nil
+		}); err != nil {
+			if errors.Is(err, domain.ErrCostOverflow) {
+				log.Error("top services total overflows", "service_name", sub.ServiceName)
+				return nil, err
+			}
+			log.Error("failed to expand subscription months", "id", sub.ID.String(), "error", err)
+			return nil, fmt.Errorf("%s: subscription %s: %w", op, sub.ID, err)
+		}
+	}
+
+	top := make([]model.ServiceSpend, 0, len(totals))
+	for key, total := range totals {
+		spend := model.ServiceSpend{ServiceName: key.name, Total: total}
+		if key.plan != "" {
+			plan := key.plan
+			spend.Plan = &plan
+		}
+		top = append(top, spend)
+	}
+	planOf := func(spend model.ServiceSpend) string {
+		if spend.Plan == nil {
+			return ""
+		}
+		return *spend.Plan
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Total != top[j].Total {
+			return top[i].Total > top[j].Total
+		}
+		if top[i].ServiceName != top[j].ServiceName {
+			return top[i].ServiceName < top[j].ServiceName
+		}
+		return planOf(top[i]) < planOf(top[j])
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	log.Info("got top services successfully", "count", len(top))
+	return top, nil
+}
+
+// GetUserSummary summarizes the subscriptions priced in currency the user
+// is billed for in the current month. The monthly cost counts the part of
+// every subscription the user pays in that month, see expandUserCosts, like
+// total_cost does.
+func (s *SubscriptionService) GetUserSummary(ctx context.Context, userID uuid.UUID, currency string) (*model.SummaryResponse, error) {
+	const op = "service.GetUserSummary"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting user summary", "user_id", userID.String(), "currency", currency)
+	subs, err := s.reader.GetSubscriptionsForTotalCost(ctx, userID, "", currency, nil, nil)
+	if err != nil {
+		log.Error("failed to get subscriptions for summary", "error", err)
+		return nil, err
+	}
+
+	now := s.now()
+	summary := &model.SummaryResponse{Currency: currency}
+	for i := range subs {
+		sub := subs[i].Subscription
+		active := false
+		var cost int64
+		if err := expandUserCosts(subs[i], now, now, now, false, func(_ time.Time, monthly int64) error {
+			active, cost = true, monthly
+			return nil
+		}); err != nil {
+			log.Error("failed to expand subscription months", "id", sub.ID.String(), "error", err)
+			return nil, fmt.Errorf("%s: subscription %s: %w", op, sub.ID, err)
+		}
+		if !active {
+			continue
+		}
+
+		summary.ActiveCount++
+		summary.MonthlyCost, err = addCost(summary.MonthlyCost, cost)
+		if err != nil {
+			log.Error("monthly cost overflows", "user_id", userID.String())
+			return nil, err
+		}
+		if summary.MostExpensive == nil || sub.PriceMinor > summary.MostExpensive.PriceMinor {
+			summary.MostExpensive = &subs[i].Subscription
+		}
+		if summary.EarliestStartDate == nil || sub.StartDate.Before(*summary.EarliestStartDate) {
+			summary.EarliestStartDate = &subs[i].StartDate
+		}
+	}
+
+	budget, err := s.reader.GetBudget(ctx, userID)
+	switch {
+	case err == nil:
+		if summary.BudgetUsage, err = s.budgetUsage(ctx, budget, s.currentMonth()); err != nil {
+			log.Error("failed to get budget usage", "error", err)
+			return nil, err
+		}
+	case !errors.Is(err, domain.ErrNotFound):
+		log.Error("failed to get budget", "error", err)
+		return nil, err
+	}
+
+	log.Info("got user summary successfully", "active_count", summary.ActiveCount)
+	return summary, nil
+}
+
+// GetCostReport returns what every user pays in currency within [from, to],
+// counted like GetTotalCost counts it.
+func (s *SubscriptionService) GetCostReport(ctx context.Context, currency string, from, to time.Time, limit, offset int) ([]model.UserCost, error) {
+	const op = "service.GetCostReport"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting cost report", "currency", currency)
+	report, err := s.reader.GetCostReport(ctx, currency, from, to, limit, offset)
+	if err != nil {
+		log.Error("failed to get cost report", "error", err)
+		return nil, err
+	}
+
+	log.Info("got cost report successfully", "users", len(report))
+	return report, nil
+}
+
+// GetDuplicateGroups returns groups of at least minGroupSize subscriptions
+// of the same user and service whose billed periods overlap.
+func (s *SubscriptionService) GetDuplicateGroups(ctx context.Context, minGroupSize, limit, offset int) ([]model.DuplicateGroup, error) {
+	const op = "service.GetDuplicateGroups"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting duplicate groups", "min_group_size", minGroupSize)
+	groups, err := s.reader.GetDuplicateGroups(ctx, minGroupSize, limit, offset)
+	if err != nil {
+		log.Error("failed to get duplicate groups", "error", err)
+		return nil, err
+	}
+
+	log.Info("got duplicate groups successfully", "groups", len(groups))
+	return groups, nil
+}
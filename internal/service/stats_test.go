@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"subscriptions-service/internal/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetUserSummary(t *testing.T) {
+	sub := liveSubscription()
+	sub.PriceMinor = 1000
+	usd := liveSubscription()
+	usd.UserID, usd.PriceMinor, usd.Currency = sub.UserID, 900, "USD"
+	member := uuid.New()
+	store := newFakeStore(sub, usd)
+	store.members = []model.SubscriptionMember{{SubscriptionID: sub.ID, UserID: member, SharePercent: 30}}
+	svc := newTestService(store, 0)
+
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		currency    string
+		wantActive  int
+		wantMonthly int64
+	}{
+		{name: "owner pays the remainder", userID: sub.UserID, currency: "RUB", wantActive: 1, wantMonthly: 700},
+		{name: "member pays their share", userID: member, currency: "RUB", wantActive: 1, wantMonthly: 300},
+		{name: "stranger pays nothing", userID: uuid.New(), currency: "RUB", wantActive: 0, wantMonthly: 0},
+		{name: "other currencies are left out", userID: sub.UserID, currency: "USD", wantActive: 1, wantMonthly: 900},
+		{name: "member of nothing in the currency", userID: member, currency: "USD", wantActive: 0, wantMonthly: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := svc.GetUserSummary(context.Background(), tt.userID, tt.currency)
+			if err != nil {
+				t.Fatalf("GetUserSummary() error = %v", err)
+			}
+			if summary.Currency != tt.currency {
+				t.Errorf("GetUserSummary() currency = %q, want %q", summary.Currency, tt.currency)
+			}
+			if summary.ActiveCount != tt.wantActive || summary.MonthlyCost != tt.wantMonthly {
+				t.Errorf("GetUserSummary() = %d active costing %d, want %d costing %d", summary.ActiveCount, summary.MonthlyCost, tt.wantActive, tt.wantMonthly)
+			}
+		})
+	}
+}
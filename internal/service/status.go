@@ -0,0 +1,46 @@
+package service
+
+import (
+	"subscriptions-service/internal/model"
+)
+
+// transitions lists the statuses each status may change to. Cancelled is
+// terminal.
+var transitions = map[string][]string{
+	model.StatusActive:    {model.StatusPaused, model.StatusCancelled, model.StatusExpired},
+	model.StatusTrialing:  {model.StatusActive, model.StatusPaused, model.StatusCancelled, model.StatusExpired},
+	model.StatusPaused:    {model.StatusActive, model.StatusCancelled, model.StatusExpired},
+	model.StatusExpired:   {model.StatusActive, model.StatusCancelled},
+	model.StatusCancelled: nil,
+}
+
+// checkTransition reports whether a subscription may change from one status
+// to another. Keeping a status is always allowed.
+func checkTransition(from, to string) error {
+	if from == to {
+		return nil
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &model.TransitionError{From: from, To: to}
+}
+
+// checkStatusPatch validates the status change requested by patch against
+// the current status of the subscription. Cancelling goes through Cancel so
+// that cancelled_at is recorded.
+func checkStatusPatch(current string, patch model.SubscriptionPatch) error {
+	if patch.Status == nil {
+		return nil
+	}
+	to := *patch.Status
+	if !model.IsValidStatus(to) {
+		return model.ValidationError("invalid status")
+	}
+	if to == model.StatusCancelled && current != model.StatusCancelled {
+		return model.ValidationError("use the cancel endpoint to cancel a subscription")
+	}
+	return checkTransition(current, to)
+}
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"maps"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/domain"
 	"subscriptions-service/internal/model"
 	"time"
 
@@ -10,150 +14,790 @@ import (
 )
 
 //go:generate mockgen -source=subscription.go -destination=mocks/mock.go
-type SubscriptionRepository interface {
-	Create(ctx context.Context, sub *model.Subscription) (uuid.UUID, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
-	List(ctx context.Context, limit, offset int) ([]model.Subscription, error)
+
+// SubscriptionReader is the read-only part of the subscription storage, all
+// that reporting needs. Reads made with a context passed into
+// SubscriptionWriter.WithTx must take part in that transaction, so a cache
+// wrapping the reader has to pass them through.
+type SubscriptionReader interface {
+	GetEquivalent(ctx context.Context, sub *model.Subscription) (*model.Subscription, error)
+	GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*model.Subscription, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Subscription, error)
+	Exists(ctx context.Context, id uuid.UUID) (bool, error)
+	List(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error)
+	GetAnonymization(ctx context.Context, userID uuid.UUID) (*model.Anonymization, error)
+	ListMembers(ctx context.Context, id uuid.UUID) ([]model.SubscriptionMember, error)
+	CountMatching(ctx context.Context, filter model.DeleteFilter, sampleSize int) (int64, []uuid.UUID, error)
+	GetTotalCostByCurrency(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived bool) (map[string]int64, int, bool, error)
+	GetSubscriptionsForTotalCost(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time) ([]model.SharedSubscription, error)
+	GetCostCells(ctx context.Context, userID uuid.UUID, serviceName, currency string, from, to *time.Time, amortize bool) ([]model.CostCell, int, error)
+	GetServiceStats(ctx context.Context, userID *uuid.UUID, currency string, grouping model.StatsGrouping) ([]model.ServiceStats, error)
+	GetCostReport(ctx context.Context, currency string, from, to time.Time, limit, offset int) ([]model.UserCost, error)
+	GetDuplicateGroups(ctx context.Context, minGroupSize, limit, offset int) ([]model.DuplicateGroup, error)
+	Export(ctx context.Context, fn func(sub model.Subscription) error) error
+	GetIdempotencyKey(ctx context.Context, key string, notBefore time.Time) (string, uuid.UUID, error)
+	FindOverlapping(ctx context.Context, sub *model.Subscription) ([]uuid.UUID, error)
+	GetBudget(ctx context.Context, userID uuid.UUID) (*model.Budget, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+}
+
+// SubscriptionWriter is the part of the subscription storage that changes
+// it, along with the reads that lock rows for a later write.
+type SubscriptionWriter interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	Create(ctx context.Context, sub *model.Subscription) error
+	CreateIfNotExists(ctx context.Context, sub *model.Subscription) (bool, error)
+	GetForUpdate(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
 	Update(ctx context.Context, sub *model.Subscription) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	GetSubscriptionsForTotalCost(ctx context.Context, userID uuid.UUID, serviceName, startDate, endDate string) ([]model.Subscription, error)
+	Delete(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+	Cancel(ctx context.Context, id uuid.UUID, now time.Time, setEndDate bool) (*model.Subscription, bool, error)
+	Renew(ctx context.Context, id uuid.UUID, months int, now time.Time) (*model.Subscription, error)
+	DeleteMatching(ctx context.Context, filter model.DeleteFilter) ([]model.SubscriptionChange, error)
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	PurgeByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	ArchiveBatch(ctx context.Context, cutoff time.Time, batchSize int) ([]model.SubscriptionChange, error)
+	Anonymize(ctx context.Context, userID, syntheticID uuid.UUID) (int64, error)
+	SaveAnonymization(ctx context.Context, anonymization *model.Anonymization) error
+	DeleteAnonymization(ctx context.Context, userID uuid.UUID) error
+	SetMember(ctx context.Context, member *model.SubscriptionMember) (bool, error)
+	RemoveMember(ctx context.Context, id, userID uuid.UUID) error
+	ActivateEndedTrials(ctx context.Context, now time.Time) ([]model.SubscriptionChange, error)
+	ExpireEnded(ctx context.Context, now time.Time) ([]model.SubscriptionChange, error)
+	Reprice(ctx context.Context, serviceName, currency string, userID *uuid.UUID, price int, activeFrom *time.Time) ([]model.SubscriptionChange, error)
+	CreateMany(ctx context.Context, subs []model.Subscription) ([]uuid.UUID, error)
+	CreateIdempotent(ctx context.Context, sub *model.Subscription, key, requestHash string, notBefore time.Time) (uuid.UUID, bool, error)
+	DeleteIdempotencyKeys(ctx context.Context, olderThan time.Time) (int64, error)
+	DeleteIdempotencyKeysByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	EnsureUser(ctx context.Context, userID uuid.UUID) error
+	CountActiveForQuota(ctx context.Context, userID uuid.UUID, defaultLimit int) (int, int, error)
+	SetBudget(ctx context.Context, budget *model.Budget) (bool, error)
+	SetNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) (bool, error)
+}
+
+// AuditLog records changes to subscriptions. Entries are appended within
+// the transaction of the change they record.
+type AuditLog interface {
+	Append(ctx context.Context, entry *model.AuditEntry) error
+	ListBySubscription(ctx context.Context, id uuid.UUID, limit, offset int) ([]model.AuditEntry, error)
+	DeleteByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+// ServiceCatalog resolves service names to catalog entries.
+type ServiceCatalog interface {
+	Resolve(ctx context.Context, name string) (*model.CatalogEntry, error)
 }
 
 type SubscriptionService struct {
-	repo SubscriptionRepository
-	log  *slog.Logger
+	reader            SubscriptionReader
+	writer            SubscriptionWriter
+	catalog           ServiceCatalog
+	audit             AuditLog
+	users             UserValidator
+	log               *slog.Logger
+	now               func() time.Time // clock used for "current month" calculations
+	totalCost         *totalCostCache
+	expandCostsInGo   bool
+	idempotencyKeyTTL time.Duration
+	archiveBatchSize  int
+	maxActivePerUser  int
+	budgetWarning     int // percent of a budget that triggers the warning status
+}
+
+// NewSubscriptionService returns a service reading subscriptions through
+// reader and changing them through writer. Both are usually the same
+// repository.
+func NewSubscriptionService(reader SubscriptionReader, writer SubscriptionWriter, catalog ServiceCatalog, audit AuditLog, users UserValidator, cache config.CacheConfig, cost config.CostConfig, idempotency config.IdempotencyConfig, archive config.ArchiveConfig, quota config.QuotaConfig, budget config.BudgetConfig, log *slog.Logger) *SubscriptionService {
+	return &SubscriptionService{
+		reader:            reader,
+		writer:            writer,
+		catalog:           catalog,
+		audit:             audit,
+		users:             users,
+		log:               log,
+		now:               time.Now,
+		totalCost:         newTotalCostCache(cache.TotalCostTTL),
+		expandCostsInGo:   cost.ExpandInGo,
+		idempotencyKeyTTL: idempotency.KeyTTL,
+		archiveBatchSize:  archive.BatchSize,
+		maxActivePerUser:  quota.MaxActivePerUser,
+		budgetWarning:     budget.WarningThresholdPercent,
+	}
+}
+
+// resolveService links sub to the catalog entry for its service name and
+// takes over the canonical name of the entry. Without an entry sub is left
+// unlinked.
+func (s *SubscriptionService) resolveService(ctx context.Context, sub *model.Subscription) error {
+	entry, err := s.catalog.Resolve(ctx, sub.ServiceName)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		sub.ServiceID = nil
+		return nil
+	}
+	sub.ServiceID, sub.ServiceName = &entry.ID, entry.Name
+	return nil
 }
 
-func NewSubscriptionService(repo SubscriptionRepository, log *slog.Logger) *SubscriptionService {
-	return &SubscriptionService{repo: repo, log: log}
+// resolveServices calls resolveService for every subscription in subs.
+func (s *SubscriptionService) resolveServices(ctx context.Context, subs []model.Subscription) error {
+	for i := range subs {
+		if err := s.resolveService(ctx, &subs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *SubscriptionService) Create(ctx context.Context, sub *model.Subscription) (uuid.UUID, error) {
+// Create stores sub and returns it populated with the stored row, carrying its
+// ID, timestamps and the defaults set by the database. Unless allowOverlap is
+// set, it fails with a *model.OverlapError when the user already has a
+// subscription to the same service in one of sub's months. It fails with
+// domain.ErrUnknownUser when the user service does not know the user and with a
+// *model.QuotaExceededError when the user has as many active subscriptions as
+// allowed already.
+func (s *SubscriptionService) Create(ctx context.Context, sub *model.Subscription, allowOverlap bool) (*model.Subscription, error) {
 	const op = "service.Create"
 	log := s.log.With(slog.String("op", op))
 
 	log.Info("creating subscription")
-	id, err := s.repo.Create(ctx, sub)
+	if err := s.resolveService(ctx, sub); err != nil {
+		log.Error("failed to resolve service", "error", err)
+		return nil, err
+	}
+	if err := s.validateUser(ctx, sub.UserID); err != nil {
+		log.Warn("failed to validate user", "user_id", sub.UserID.String(), "error", err)
+		return nil, err
+	}
+	if !allowOverlap {
+		if err := s.checkOverlap(ctx, sub); err != nil {
+			log.Warn("subscription overlaps", "error", err)
+			return nil, err
+		}
+	}
+
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.writer.Create(ctx, sub); err != nil {
+			return err
+		}
+		if err := s.enforceQuota(ctx, *sub); err != nil {
+			return err
+		}
+		return s.recordCreated(ctx, sub.ID)
+	})
+	if err != nil {
+		log.Error("failed to create subscription", "error", err)
+		return nil, err
+	}
+	s.totalCost.invalidate(sub.UserID)
+	log.Info("subscription created successfully", "id", sub.ID)
+	return sub, nil
+}
+
+// CreateIfNotExists stores sub unless the user already has a subscription
+// to the same service starting in the same month, in which case sub is
+// replaced by the stored one. It reports whether sub was created. The
+// equivalent subscription itself does not count as an overlap.
+func (s *SubscriptionService) CreateIfNotExists(ctx context.Context, sub *model.Subscription, allowOverlap bool) (bool, error) {
+	const op = "service.CreateIfNotExists"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("creating subscription if it does not exist")
+	if err := s.resolveService(ctx, sub); err != nil {
+		log.Error("failed to resolve service", "error", err)
+		return false, err
+	}
+	if err := s.validateUser(ctx, sub.UserID); err != nil {
+		log.Warn("failed to validate user", "user_id", sub.UserID.String(), "error", err)
+		return false, err
+	}
+	if !allowOverlap {
+		existing, err := s.reader.GetEquivalent(ctx, sub)
+		switch {
+		case err == nil:
+			log.Info("subscription already exists", "id", existing.ID)
+			*sub = *existing
+			return false, nil
+		case !errors.Is(err, domain.ErrNotFound):
+			log.Error("failed to look up existing subscription", "error", err)
+			return false, err
+		}
+		if err := s.checkOverlap(ctx, sub); err != nil {
+			log.Warn("subscription overlaps", "error", err)
+			return false, err
+		}
+	}
+
+	var created bool
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.writer.CreateIfNotExists(ctx, sub); err != nil || !created {
+			return err
+		}
+		if err := s.enforceQuota(ctx, *sub); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, model.AuditActionCreate, sub.ID, nil, sub)
+	})
 	if err != nil {
 		log.Error("failed to create subscription", "error", err)
-		return uuid.Nil, err
+		return false, err
 	}
-	log.Info("subscription created successfully", "id", id)
-	return id, nil
+	if created {
+		s.totalCost.invalidate(sub.UserID)
+		log.Info("subscription created successfully", "id", sub.ID)
+	} else {
+		log.Info("subscription already exists", "id", sub.ID)
+	}
+	return created, nil
 }
 
-func (s *SubscriptionService) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+// GetByID returns the subscription with the given ID. Soft-deleted
+// subscriptions are only returned with includeDeleted.
+func (s *SubscriptionService) GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*model.Subscription, error) {
 	const op = "service.GetByID"
 	log := s.log.With(slog.String("op", op))
 
-	log.Info("getting subscription by id", "id", id.String())
-	sub, err := s.repo.GetByID(ctx, id)
+	log.Info("getting subscription by id", "id", id.String(), "include_deleted", includeDeleted)
+	sub, err := s.reader.GetByID(ctx, id, includeDeleted)
 	if err != nil {
 		log.Error("failed to get subscription by id", "error", err)
 		return nil, err
 	}
+	s.setNextBillingDate(sub)
 	log.Info("got subscription by id successfully", "id", id.String())
 	return sub, nil
 }
 
-func (s *SubscriptionService) List(ctx context.Context, limit, offset int) ([]model.Subscription, error) {
+// setNextBillingDate fills in the read-only next billing date of sub.
+func (s *SubscriptionService) setNextBillingDate(sub *model.Subscription) {
+	sub.NextBillingDate = nil
+	if next := nextBillingDate(*sub, s.now()); next != nil {
+		formatted := next.Format(time.DateOnly)
+		sub.NextBillingDate = &formatted
+	}
+}
+
+func (s *SubscriptionService) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	const op = "service.Exists"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("checking subscription exists", "id", id.String())
+	exists, err := s.reader.Exists(ctx, id)
+	if err != nil {
+		log.Error("failed to check subscription exists", "error", err)
+		return false, err
+	}
+	log.Info("checked subscription exists successfully", "id", id.String(), "exists", exists)
+	return exists, nil
+}
+
+// GetByIDs returns the live subscriptions with the given IDs and, in
+// request order, the IDs matching none. Repeated IDs are looked up once.
+func (s *SubscriptionService) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Subscription, []uuid.UUID, error) {
+	const op = "service.GetByIDs"
+	log := s.log.With(slog.String("op", op))
+
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	log.Info("getting subscriptions by ids", "count", len(unique))
+	subs, err := s.reader.GetByIDs(ctx, unique)
+	if err != nil {
+		log.Error("failed to get subscriptions by ids", "error", err)
+		return nil, nil, err
+	}
+
+	found := make(map[uuid.UUID]struct{}, len(subs))
+	for _, sub := range subs {
+		found[sub.ID] = struct{}{}
+	}
+
+	notFound := make([]uuid.UUID, 0)
+	for _, id := range unique {
+		if _, ok := found[id]; !ok {
+			notFound = append(notFound, id)
+		}
+	}
+	if subs == nil {
+		subs = make([]model.Subscription, 0)
+	}
+
+	log.Info("got subscriptions by ids successfully", "found", len(subs), "not_found", len(notFound))
+	return subs, notFound, nil
+}
+
+func (s *SubscriptionService) List(ctx context.Context, filter model.SubscriptionFilter, limit, offset int) ([]model.Subscription, error) {
 	const op = "service.List"
 	log := s.log.With(slog.String("op", op))
 
 	log.Info("listing subscriptions")
-	subs, err := s.repo.List(ctx, limit, offset)
+	subs, err := s.reader.List(ctx, filter, limit, offset)
 	if err != nil {
 		log.Error("failed to list subscriptions", "error", err)
 		return nil, err
 	}
+	for i := range subs {
+		s.setNextBillingDate(&subs[i])
+	}
 	log.Info("listed subscriptions successfully", "count", len(subs))
 	return subs, nil
 }
 
-func (s *SubscriptionService) Update(ctx context.Context, sub *model.Subscription) error {
+// Update replaces the subscription with sub.ID, enforcing the same overlap
+// rule as Create.
+func (s *SubscriptionService) Update(ctx context.Context, sub *model.Subscription, allowOverlap bool) error {
 	const op = "service.Update"
 	log := s.log.With(slog.String("op", op))
 
 	log.Info("updating subscription", "id", sub.ID.String())
-
-	_, err := s.repo.GetByID(ctx, sub.ID)
-	if err != nil {
-		log.Error("failed to get subscription before update", "error", err)
+	if err := s.resolveService(ctx, sub); err != nil {
+		log.Error("failed to resolve service", "error", err)
 		return err
 	}
+	if !allowOverlap {
+		if err := s.checkOverlap(ctx, sub); err != nil {
+			log.Warn("subscription overlaps", "error", err)
+			return err
+		}
+	}
 
-	if err := s.repo.Update(ctx, sub); err != nil {
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.reader.GetByID(ctx, sub.ID, false)
+		if err != nil {
+			return err
+		}
+		if err := s.writer.Update(ctx, sub); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, model.AuditActionUpdate, sub.ID, before, sub)
+	})
+	if err != nil {
 		log.Error("failed to update subscription", "error", err)
 		return err
 	}
+	s.totalCost.invalidate(sub.UserID)
 	log.Info("updated subscription successfully", "id", sub.ID.String())
 	return nil
 }
 
-func (s *SubscriptionService) Delete(ctx context.Context, id uuid.UUID) error {
-	const op = "service.Delete"
+// errEmptyPatch is reported for partial updates that set no field.
+const errEmptyPatch = "at least one field must be provided"
+
+// UpdateFields applies patch to the stored subscription and persists the
+// result. Fields not set in patch keep their current values. It returns a
+// model.ValidationError when the merged subscription is invalid and
+// enforces the same overlap rule as Create. When patch.Version is set and
+// the subscription has moved on, it returns domain.ErrVersionConflict. The
+// read, merge and write run in one transaction holding the row lock.
+func (s *SubscriptionService) UpdateFields(ctx context.Context, id uuid.UUID, patch model.SubscriptionPatch, allowOverlap bool) error {
+	const op = "service.UpdateFields"
 	log := s.log.With(slog.String("op", op))
 
-	log.Info("deleting subscription", "id", id.String())
+	log.Info("patching subscription", "id", id.String())
+	if patch.IsEmpty() {
+		log.Warn("empty patch")
+		return model.ValidationError(errEmptyPatch)
+	}
 
-	_, err := s.repo.GetByID(ctx, id)
+	// The row stays locked from the read to the write, so that concurrent
+	// patches apply one after the other instead of overwriting each other.
+	var sub *model.Subscription
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		sub, err = s.writer.GetForUpdate(ctx, id)
+		if err != nil {
+			log.Error("failed to get subscription before patch", "error", err)
+			return err
+		}
+		if patch.Version != 0 && patch.Version != sub.Version {
+			log.Warn("stale subscription version", "expected", patch.Version, "actual", sub.Version)
+			return domain.ErrVersionConflict
+		}
+		if err := checkStatusPatch(sub.Status, patch); err != nil {
+			log.Warn("invalid status change", "error", err)
+			return err
+		}
+
+		before := *sub
+		before.Metadata = maps.Clone(sub.Metadata)
+		patch.Apply(sub)
+		// Without an expected version the write is last-write-wins, which
+		// the lock makes safe.
+		sub.Version = patch.Version
+		if err := sub.Validate(); err != nil {
+			log.Warn("patched subscription is invalid", "error", err)
+			return err
+		}
+		if patch.ServiceName != nil {
+			if err := s.resolveService(ctx, sub); err != nil {
+				log.Error("failed to resolve service", "error", err)
+				return err
+			}
+		}
+		if !allowOverlap {
+			if err := s.checkOverlap(ctx, sub); err != nil {
+				log.Warn("patched subscription overlaps", "error", err)
+				return err
+			}
+		}
+
+		if err := s.writer.Update(ctx, sub); err != nil {
+			log.Error("failed to patch subscription", "error", err)
+			return err
+		}
+		if err := s.recordChange(ctx, model.AuditActionUpdate, id, &before, sub); err != nil {
+			log.Error("failed to record patch", "error", err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		log.Error("failed to get subscription before delete", "error", err)
 		return err
 	}
+	s.totalCost.invalidate(sub.UserID)
+	log.Info("patched subscription successfully", "id", id.String())
+	return nil
+}
 
-	if err := s.repo.Delete(ctx, id); err != nil {
+func (s *SubscriptionService) Delete(ctx context.Context, id uuid.UUID) error {
+	const op = "service.Delete"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("deleting subscription", "id", id.String())
+
+	var userID uuid.UUID
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.reader.GetByID(ctx, id, false)
+		if err != nil {
+			return err
+		}
+		if userID, err = s.writer.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, model.AuditActionDelete, id, before, nil)
+	})
+	if err != nil {
 		log.Error("failed to delete subscription", "error", err)
 		return err
 	}
+	s.totalCost.invalidate(userID)
 	log.Info("deleted subscription successfully", "id", id.String())
 	return nil
 }
 
-func (s *SubscriptionService) GetTotalCost(ctx context.Context, userID uuid.UUID, serviceName, startDate, endDate string) (int, error) {
+// GetTotalCost returns the cost of the user's subscriptions over the period,
+// or of everyone's subscriptions when userID is nil. Unless a currency is
+// given, the cost is reported per currency. With amortize, yearly and
+// weekly prices are spread evenly over the months instead of being counted
+// when they are charged. With includeArchived, archived subscriptions are
+// counted as well. A user's cost includes their share of the subscriptions
+// shared with them. Results are served from the cache unless fresh is set.
+func (s *SubscriptionService) GetTotalCost(ctx context.Context, userID *uuid.UUID, serviceName, currency string, from, to *time.Time, amortize, includeArchived, fresh bool) (*model.TotalCostResponse, error) {
 	const op = "service.GetTotalCost"
 	log := s.log.With(slog.String("op", op))
 
-	log.Info("getting total cost")
-	subs, err := s.repo.GetSubscriptionsForTotalCost(ctx, userID, serviceName, startDate, endDate)
+	// Company-wide totals are cached under uuid.Nil, which every write
+	// invalidates.
+	scope, cacheUserID := model.TotalCostScopeAll, uuid.Nil
+	if userID != nil {
+		scope, cacheUserID = model.TotalCostScopeUser, *userID
+	}
+
+	now := s.now()
+	tenant, _ := model.TenantFromContext(ctx)
+	key := totalCostKey(tenant, serviceName, currency, from, to, amortize, includeArchived, now)
+	if !fresh {
+		if resp, ok := s.totalCost.get(cacheUserID, key, now); ok {
+			log.Info("got total cost from cache")
+			return resp, nil
+		}
+	}
+
+	log.Info("getting total cost", "scope", scope, "currency", currency)
+	totals, counted, shared, err := s.reader.GetTotalCostByCurrency(ctx, userID, serviceName, currency, from, to, amortize, includeArchived)
 	if err != nil {
-		log.Error("failed to get subscriptions for total cost", "error", err)
-		return 0, err
+		log.Error("failed to get total cost", "error", err)
+		return nil, err
 	}
 
-	var totalCost int
-	monthlyCosts := make(map[time.Month]map[int]int)
+	resp := &model.TotalCostResponse{Scope: scope, SubscriptionsCounted: counted}
+	resp.SetTotals(currency, totals)
+	resp.SetPeriod(from, to)
+	// Writes only invalidate the owner of the subscription they change, so
+	// totals depending on subscriptions owned by others are not cached.
+	if !shared {
+		s.totalCost.set(cacheUserID, key, resp, now)
+	}
 
-	for _, sub := range subs {
-		start, err := time.Parse("2006-01-02", sub.StartDate)
+	log.Info("got total cost successfully", "currencies", len(totals), "subscriptions_counted", counted)
+	return resp, nil
+}
+
+// Cancel cancels the subscription as of now; it stops accruing cost after
+// the current month. With setEndDate an open-ended subscription also gets
+// the current month as its end date. Cancelling a cancelled subscription
+// returns it unchanged. The returned flag reports whether anything changed.
+func (s *SubscriptionService) Cancel(ctx context.Context, id uuid.UUID, setEndDate bool) (*model.Subscription, bool, error) {
+	const op = "service.Cancel"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("cancelling subscription", "id", id.String(), "set_end_date", setEndDate)
+	var sub *model.Subscription
+	var cancelled bool
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.reader.GetByID(ctx, id, false)
 		if err != nil {
-			log.Error("failed to parse start date", "error", err)
-			continue
+			return err
+		}
+		if sub, cancelled, err = s.writer.Cancel(ctx, id, s.now(), setEndDate); err != nil || !cancelled {
+			return err
 		}
+		return s.recordChange(ctx, model.AuditActionCancel, id, before, sub)
+	})
+	if err != nil {
+		log.Error("failed to cancel subscription", "error", err)
+		return nil, false, err
+	}
+	if !cancelled {
+		log.Info("subscription already cancelled", "id", id.String())
+		return sub, false, nil
+	}
+	s.totalCost.invalidate(sub.UserID)
+	log.Info("cancelled subscription successfully", "id", id.String())
+	return sub, true, nil
+}
 
-		end := time.Now().AddDate(10, 0, 0) // 10 years in the future for open-ended subscriptions
-		if sub.EndDate != nil {
-			end, err = time.Parse("2006-01-02", *sub.EndDate)
-			if err != nil {
-				log.Error("failed to parse end date", "error", err)
-				continue
-			}
+// Renew extends the subscription by months months from its end date, or
+// from the current month when it is open-ended or has already ended.
+func (s *SubscriptionService) Renew(ctx context.Context, id uuid.UUID, months int) (*model.Subscription, error) {
+	const op = "service.Renew"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("renewing subscription", "id", id.String(), "months", months)
+	var sub *model.Subscription
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		before, err := s.reader.GetByID(ctx, id, false)
+		if err != nil {
+			return err
 		}
+		if sub, err = s.writer.Renew(ctx, id, months, s.now()); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, model.AuditActionRenew, id, before, sub)
+	})
+	if err != nil {
+		log.Error("failed to renew subscription", "error", err)
+		return nil, err
+	}
+	s.totalCost.invalidate(sub.UserID)
+	log.Info("renewed subscription successfully", "id", id.String(), "end_date", sub.EndDate.String())
+	return sub, nil
+}
+
+// Reprice sets price on the subscriptions to serviceName priced in
+// currency, optionally only those of userID. With onlyActive, subscriptions that ended before the
+// current month keep their historic price. The change and its audit
+// entries are written in one transaction. It returns the number of
+// subscriptions changed.
+func (s *SubscriptionService) Reprice(ctx context.Context, serviceName, currency string, userID *uuid.UUID, price int, onlyActive bool) (int64, error) {
+	const op = "service.Reprice"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("repricing subscriptions", "service_name", serviceName, "currency", currency, "price", price, "only_active", onlyActive)
+	var activeFrom *time.Time
+	if onlyActive {
+		now := s.now()
+		activeFrom = &now
+	}
+
+	var changes []model.SubscriptionChange
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changes, err = s.writer.Reprice(ctx, serviceName, currency, userID, price, activeFrom); err != nil {
+			return err
+		}
+		return s.recordChanges(ctx, model.AuditActionUpdate, changes)
+	})
+	if err != nil {
+		log.Error("failed to reprice subscriptions", "error", err)
+		return 0, err
+	}
+	if len(changes) > 0 {
+		s.totalCost.invalidate(changedOwners(changes)...)
+	}
+	log.Info("repriced subscriptions successfully", "updated", len(changes))
+	return int64(len(changes)), nil
+}
+
+// PurgeDeleted removes the subscriptions soft-deleted more than olderThan
+// ago for good and returns how many were removed.
+func (s *SubscriptionService) PurgeDeleted(ctx context.Context, olderThan time.Duration) (int64, error) {
+	const op = "service.PurgeDeleted"
+	log := s.log.With(slog.String("op", op))
+
+	purged, err := s.writer.PurgeDeletedBefore(ctx, s.now().Add(-olderThan))
+	if err != nil {
+		log.Error("failed to purge deleted subscriptions", "error", err)
+		return 0, err
+	}
+
+	log.Info("purged deleted subscriptions", "count", purged, "older_than", olderThan.String())
+	return purged, nil
+}
 
-		for d := start; d.Before(end); d = d.AddDate(0, 1, 0) {
-			if monthlyCosts[d.Month()] == nil {
-				monthlyCosts[d.Month()] = make(map[int]int)
+// Archive moves the subscriptions that ended before the month of cutoff to
+// the archive and returns how many were moved. Archived subscriptions only
+// count towards total costs that ask for them. The cutoff must not be
+// later than the current month, so that running subscriptions stay put.
+// Every batch is moved and recorded in a transaction of its own, so that
+// locks are held briefly and an interrupted run keeps the batches it
+// finished.
+func (s *SubscriptionService) Archive(ctx context.Context, cutoff time.Time) (int64, error) {
+	const op = "service.Archive"
+	log := s.log.With(slog.String("op", op))
+
+	if model.NewMonthYear(cutoff).Time().After(model.NewMonthYear(s.now()).Time()) {
+		return 0, model.ValidationError("older_than must not be later than the current month")
+	}
+
+	var archived int64
+	for {
+		var batch []model.SubscriptionChange
+		err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			if batch, err = s.writer.ArchiveBatch(ctx, cutoff, s.archiveBatchSize); err != nil {
+				return err
 			}
-			monthlyCosts[d.Month()][d.Year()] += sub.Price
+			return s.recordChanges(ctx, model.AuditActionArchive, batch)
+		})
+		if err != nil {
+			log.Error("failed to archive subscriptions", "error", err, "archived", archived)
+			return archived, err
+		}
+
+		archived += int64(len(batch))
+		if len(batch) > 0 {
+			s.totalCost.invalidate(changedOwners(batch)...)
+		}
+		if len(batch) < s.archiveBatchSize {
+			break
 		}
 	}
 
-	for _, yearCosts := range monthlyCosts {
-		for _, cost := range yearCosts {
-			totalCost += cost
+	log.Info("archived subscriptions", "count", archived, "older_than", model.FormatMonthYear(cutoff))
+	return archived, nil
+}
+
+// ActivateEndedTrials moves trialing subscriptions whose trial is over to
+// active and returns how many were moved.
+func (s *SubscriptionService) ActivateEndedTrials(ctx context.Context) (int64, error) {
+	const op = "service.ActivateEndedTrials"
+	log := s.log.With(slog.String("op", op))
+
+	var changes []model.SubscriptionChange
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changes, err = s.writer.ActivateEndedTrials(ctx, s.now()); err != nil {
+			return err
 		}
+		return s.recordChanges(ctx, model.AuditActionUpdate, changes)
+	})
+	if err != nil {
+		log.Error("failed to activate ended trials", "error", err)
+		return 0, err
 	}
 
-	log.Info("got total cost successfully", "total_cost", totalCost)
-	return totalCost, nil
+	log.Info("activated ended trials", "count", len(changes))
+	return int64(len(changes)), nil
+}
+
+// ExpireEnded marks subscriptions that do not renew automatically as
+// expired once their end date has passed and returns how many were marked.
+// Subscriptions that renew automatically never expire on their own.
+func (s *SubscriptionService) ExpireEnded(ctx context.Context) (int64, error) {
+	const op = "service.ExpireEnded"
+	log := s.log.With(slog.String("op", op))
+
+	var changes []model.SubscriptionChange
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changes, err = s.writer.ExpireEnded(ctx, s.now()); err != nil {
+			return err
+		}
+		return s.recordChanges(ctx, model.AuditActionUpdate, changes)
+	})
+	if err != nil {
+		log.Error("failed to expire ended subscriptions", "error", err)
+		return 0, err
+	}
+
+	log.Info("expired ended subscriptions", "count", len(changes))
+	return int64(len(changes)), nil
+}
+
+// deleteSampleSize is the number of matching IDs a dry run of
+// DeleteMatching reports.
+const deleteSampleSize = 100
+
+// DeleteMatching deletes the subscriptions matching filter and returns how
+// many were removed. A dry run only counts them and additionally returns a
+// sample of their IDs.
+func (s *SubscriptionService) DeleteMatching(ctx context.Context, filter model.DeleteFilter, dryRun bool) (int64, []uuid.UUID, error) {
+	const op = "service.DeleteMatching"
+	log := s.log.With(slog.String("op", op))
+
+	if dryRun {
+		log.Info("counting subscriptions to delete", "user_id", filter.UserID.String())
+		count, sample, err := s.reader.CountMatching(ctx, filter, deleteSampleSize)
+		if err != nil {
+			log.Error("failed to count subscriptions", "error", err)
+			return 0, nil, err
+		}
+		log.Info("counted subscriptions to delete", "count", count)
+		return count, sample, nil
+	}
+
+	log.Info("deleting subscriptions", "user_id", filter.UserID.String())
+	var changes []model.SubscriptionChange
+	err := s.writer.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if changes, err = s.writer.DeleteMatching(ctx, filter); err != nil {
+			return err
+		}
+		for i := range changes {
+			changes[i].After = nil
+		}
+		return s.recordChanges(ctx, model.AuditActionDelete, changes)
+	})
+	if err != nil {
+		log.Error("failed to delete subscriptions", "error", err)
+		return 0, nil, err
+	}
+	if len(changes) > 0 {
+		s.totalCost.invalidate(filter.UserID)
+	}
+	log.Info("deleted subscriptions successfully", "count", len(changes))
+	return int64(len(changes)), nil, nil
+}
+
+// checkOverlap returns a *model.OverlapError when another subscription of
+// the same user and service is billed in one of sub's months.
+func (s *SubscriptionService) checkOverlap(ctx context.Context, sub *model.Subscription) error {
+	ids, err := s.reader.FindOverlapping(ctx, sub)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return &model.OverlapError{IDs: ids}
+	}
+	return nil
 }
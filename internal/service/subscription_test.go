@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// liveSubscription returns an active subscription of a new user.
+func liveSubscription() model.Subscription {
+	return model.Subscription{ID: uuid.New(), UserID: uuid.New(), ServiceName: "Netflix", PriceMinor: 500, Currency: "RUB", BillingPeriod: model.BillingMonthly, StartDate: month(2025, 1), Status: model.StatusActive, Version: 1}
+}
+
+func TestUpdateMissingSubscription(t *testing.T) {
+	deletedAt := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+	deleted := liveSubscription()
+	deleted.DeletedAt = &deletedAt
+
+	tests := []struct {
+		name  string
+		store func() (*fakeStore, model.Subscription)
+	}{
+		{
+			name: "unknown ID",
+			store: func() (*fakeStore, model.Subscription) {
+				return newFakeStore(), liveSubscription()
+			},
+		},
+		{
+			name: "deleted ID",
+			store: func() (*fakeStore, model.Subscription) {
+				return newFakeStore(deleted), deleted
+			},
+		},
+		{
+			name: "deleted concurrently",
+			store: func() (*fakeStore, model.Subscription) {
+				sub := liveSubscription()
+				store := newFakeStore(sub)
+				// The delete commits after the service read the row and
+				// before its write.
+				store.beforeUpdate = func(id uuid.UUID) {
+					if _, err := store.Delete(context.Background(), id); err != nil {
+						t.Fatalf("Delete() error = %v", err)
+					}
+				}
+				return store, sub
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, sub := tt.store()
+			audit := &fakeAuditLog{}
+			svc := newTestService(store, 0)
+			svc.audit = audit
+
+			sub.DeletedAt = nil
+			sub.PriceMinor = 700
+			if err := svc.Update(context.Background(), &sub, true); !errors.Is(err, domain.ErrNotFound) {
+				t.Fatalf("Update() error = %v, want %v", err, domain.ErrNotFound)
+			}
+			if len(audit.entries) != 0 {
+				t.Errorf("audit entries = %d, want none for a failed update", len(audit.entries))
+			}
+			if stored, ok := store.get(context.Background(), sub.ID); ok && stored.PriceMinor != 500 {
+				t.Errorf("stored price = %d, want 500 unchanged", stored.PriceMinor)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// patchConcurrently applies patches to the subscription id at the same
+// time and returns their errors. Every write is delayed so that patches
+// not serialized by the row lock would read the same row.
+func patchConcurrently(store *fakeStore, svc *SubscriptionService, sub model.Subscription, patches ...model.SubscriptionPatch) []error {
+	store.beforeUpdate = func(id uuid.UUID) { time.Sleep(10 * time.Millisecond) }
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(patches))
+	for i, patch := range patches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = svc.UpdateFields(context.Background(), sub.ID, patch, true)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestConcurrentPatchesKeepBothChanges(t *testing.T) {
+	sub := liveSubscription()
+	sub.Metadata = map[string]string{"team": "core"}
+	store := newFakeStore(sub)
+	svc := newTestService(store, 0)
+
+	price, notes, tag := 700, "shared with roommates", "yes"
+	errs := patchConcurrently(store, svc, sub,
+		model.SubscriptionPatch{PriceMinor: &price, Metadata: map[string]*string{"shared": &tag}},
+		model.SubscriptionPatch{Notes: &notes, Metadata: map[string]*string{"reviewed": &tag}},
+	)
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateFields() error = %v", err)
+		}
+	}
+
+	stored, _ := store.get(context.Background(), sub.ID)
+	if stored.PriceMinor != price {
+		t.Errorf("price = %d, want %d", stored.PriceMinor, price)
+	}
+	if stored.Notes == nil || *stored.Notes != notes {
+		t.Errorf("notes = %v, want %q", stored.Notes, notes)
+	}
+	for _, key := range []string{"team", "shared", "reviewed"} {
+		if _, ok := stored.Metadata[key]; !ok {
+			t.Errorf("metadata = %v, want key %q", stored.Metadata, key)
+		}
+	}
+	if stored.Version != sub.Version+2 {
+		t.Errorf("version = %d, want %d", stored.Version, sub.Version+2)
+	}
+}
+
+func TestConcurrentVersionedPatchesConflict(t *testing.T) {
+	sub := liveSubscription()
+	store := newFakeStore(sub)
+	svc := newTestService(store, 0)
+
+	// Both patches expect the version they read; only the first to take
+	// the lock may apply.
+	price, notes := 700, "shared with roommates"
+	errs := patchConcurrently(store, svc, sub,
+		model.SubscriptionPatch{PriceMinor: &price, Version: sub.Version},
+		model.SubscriptionPatch{Notes: &notes, Version: sub.Version},
+	)
+
+	applied, conflicts := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			applied++
+		case errors.Is(err, domain.ErrVersionConflict):
+			conflicts++
+		default:
+			t.Errorf("UpdateFields() unexpected error = %v", err)
+		}
+	}
+	if applied != 1 || conflicts != 1 {
+		t.Errorf("applied = %d, conflicts = %d, want one of each", applied, conflicts)
+	}
+	if stored, _ := store.get(context.Background(), sub.ID); stored.Version != sub.Version+1 {
+		t.Errorf("version = %d, want %d", stored.Version, sub.Version+1)
+	}
+}
+
+func TestEmptyPatchRejected(t *testing.T) {
+	sub := liveSubscription()
+	store := newFakeStore(sub)
+	svc := newTestService(store, 0)
+
+	err := svc.UpdateFields(context.Background(), sub.ID, model.SubscriptionPatch{}, true)
+	if !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("UpdateFields() error = %v, want %v", err, domain.ErrValidation)
+	}
+	if stored, _ := store.get(context.Background(), sub.ID); stored.Version != sub.Version {
+		t.Errorf("version = %d, want %d", stored.Version, sub.Version)
+	}
+}
+
+func TestPatchLeavesOtherFieldsUntouched(t *testing.T) {
+	plan, notes, percent := "Family", "shared with roommates", 10
+	end, trialEnd, discountUntil := month(2025, 12), month(2025, 2), month(2025, 3)
+	newPrice, newNotes, newEnd := 700, "just me", month(2026, 6)
+
+	tests := []struct {
+		name   string
+		patch  model.SubscriptionPatch
+		change func(sub *model.Subscription)
+	}{
+		{name: "price", patch: model.SubscriptionPatch{PriceMinor: &newPrice}, change: func(sub *model.Subscription) { sub.PriceMinor = newPrice }},
+		{name: "notes", patch: model.SubscriptionPatch{Notes: &newNotes}, change: func(sub *model.Subscription) { sub.Notes = &newNotes }},
+		{name: "end date", patch: model.SubscriptionPatch{EndDate: &newEnd}, change: func(sub *model.Subscription) { sub.EndDate = &newEnd }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := liveSubscription()
+			sub.Plan, sub.Notes, sub.EndDate, sub.AutoRenew = &plan, &notes, &end, true
+			sub.TrialEndDate, sub.DiscountPercent, sub.DiscountUntil = &trialEnd, &percent, &discountUntil
+			sub.Metadata = map[string]string{"team": "core"}
+			store := newFakeStore(sub)
+			svc := newTestService(store, 0)
+
+			if err := svc.UpdateFields(context.Background(), sub.ID, tt.patch, true); err != nil {
+				t.Fatalf("UpdateFields() error = %v", err)
+			}
+
+			stored, _ := store.get(context.Background(), sub.ID)
+			want := sub
+			tt.change(&want)
+			want.Version, want.UpdatedAt = stored.Version, stored.UpdatedAt
+			if !reflect.DeepEqual(stored, want) {
+				t.Errorf("stored = %+v, want %+v", stored, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+}
+
+// UserService manages the users subscriptions belong to.
+type UserService struct {
+	repo UserRepository
+	log  *slog.Logger
+}
+
+func NewUserService(repo UserRepository, log *slog.Logger) *UserService {
+	return &UserService{repo: repo, log: log}
+}
+
+// Create registers user, generating an ID unless it has one.
+func (s *UserService) Create(ctx context.Context, user *model.User) error {
+	const op = "service.CreateUser"
+	log := s.log.With(slog.String("op", op))
+
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	log.Info("creating user", "id", user.ID.String())
+	if err := s.repo.Create(ctx, user); err != nil {
+		log.Error("failed to create user", "error", err)
+		return err
+	}
+	log.Info("user created successfully", "id", user.ID.String())
+	return nil
+}
+
+func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	const op = "service.GetUser"
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("getting user", "id", id.String())
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		log.Error("failed to get user", "error", err)
+		return nil, err
+	}
+	return user, nil
+}
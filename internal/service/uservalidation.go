@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"subscriptions-service/internal/domain"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// UserValidator checks user IDs against the company-wide user service.
+type UserValidator interface {
+	Exists(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// ErrUserServiceUnavailable is returned when a user cannot be checked
+// because the user service did not answer.
+var ErrUserServiceUnavailable = errors.New("user service is unavailable")
+
+// validateUser checks that the user service knows userID and registers the
+// user in the users table subscriptions reference. It returns
+// domain.ErrUnknownUser for users the user service does not know.
+func (s *SubscriptionService) validateUser(ctx context.Context, userID uuid.UUID) error {
+	exists, err := s.users.Exists(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUserServiceUnavailable, err)
+	}
+	if !exists {
+		return domain.ErrUnknownUser
+	}
+	return s.writer.EnsureUser(ctx, userID)
+}
+
+// validateUsers calls validateUser once for every distinct owner of subs.
+// A failure is reported as a *domain.BulkItemError naming the first
+// subscription of the user.
+func (s *SubscriptionService) validateUsers(ctx context.Context, subs []model.Subscription) error {
+	checked := make(map[uuid.UUID]bool, len(subs))
+	for i := range subs {
+		if checked[subs[i].UserID] {
+			continue
+		}
+		if err := s.validateUser(ctx, subs[i].UserID); err != nil {
+			return &domain.BulkItemError{Index: i, Err: err}
+		}
+		checked[subs[i].UserID] = true
+	}
+	return nil
+}
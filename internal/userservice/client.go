@@ -0,0 +1,91 @@
+// Package userservice talks to the company-wide user service, the source
+// of truth for which users exist.
+package userservice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"subscriptions-service/internal/config"
+	"subscriptions-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Client checks users against the user service over HTTP. Users the
+// service confirmed are remembered in a small LRU cache; users it does not
+// know are asked about every time, so they can be created in the meantime.
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	failOpen bool
+	known    *lru
+	log      *slog.Logger
+}
+
+func NewClient(cfg config.UserServiceConfig, log *slog.Logger) *Client {
+	return &Client{
+		baseURL:  cfg.BaseURL,
+		http:     &http.Client{Timeout: cfg.Timeout},
+		failOpen: cfg.FailOpen,
+		known:    newLRU(cfg.CacheSize),
+		log:      log,
+	}
+}
+
+// Exists reports whether the user service knows userID within the tenant
+// of ctx. When the service cannot be reached or answers with anything but
+// 200 or 404, Exists fails, unless the client is configured to fail open,
+// in which case the user is assumed to exist.
+func (c *Client) Exists(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tenant, _ := model.TenantFromContext(ctx)
+	key := tenant.String() + "/" + userID.String()
+	if c.known.contains(key) {
+		return true, nil
+	}
+
+	exists, err := c.lookup(ctx, tenant, userID)
+	if err != nil {
+		if c.failOpen {
+			c.log.Warn("user service unavailable, accepting user", "user_id", userID.String(), "error", err)
+			return true, nil
+		}
+		return false, err
+	}
+	if exists {
+		c.known.add(key)
+	}
+	return exists, nil
+}
+
+// lookup asks the user service for userID.
+func (c *Client) lookup(ctx context.Context, tenant, userID uuid.UUID) (bool, error) {
+	endpoint, err := url.JoinPath(c.baseURL, "users", userID.String())
+	if err != nil {
+		return false, fmt.Errorf("userservice: invalid base url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("userservice: failed to build request: %w", err)
+	}
+	if tenant != uuid.Nil {
+		req.Header.Set("X-Tenant-ID", tenant.String())
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("userservice: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("userservice: unexpected status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,52 @@
+package userservice
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a set of at most size keys that forgets the least recently used
+// key first. A zero size keeps nothing.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is the most recently used key
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// contains reports whether key is in the set and marks it as used.
+func (c *lru) contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// add puts key into the set, evicting the least recently used key when the
+// set is full.
+func (c *lru) add(key string) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+	c.items[key] = c.order.PushFront(key)
+}
@@ -0,0 +1,26 @@
+package userservice
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Stub stands in for the user service in tests and local development.
+// Without Known every user exists; otherwise only the users in Known do.
+// A non-nil Err is returned from every call, as if the user service were
+// unreachable.
+type Stub struct {
+	Known map[uuid.UUID]bool
+	Err   error
+}
+
+func (s *Stub) Exists(_ context.Context, userID uuid.UUID) (bool, error) {
+	if s.Err != nil {
+		return false, s.Err
+	}
+	if s.Known == nil {
+		return true, nil
+	}
+	return s.Known[userID], nil
+}
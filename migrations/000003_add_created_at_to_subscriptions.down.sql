@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscriptions_created_at_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX idx_subscriptions_created_at_id ON subscriptions(created_at, id);
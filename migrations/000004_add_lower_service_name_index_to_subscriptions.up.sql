@@ -0,0 +1 @@
+CREATE INDEX idx_subscriptions_user_id_lower_service_name ON subscriptions(user_id, LOWER(service_name));
@@ -0,0 +1,2 @@
+-- At most one open-ended subscription per user and service.
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL;
@@ -0,0 +1,2 @@
+-- At most one subscription per user and service starting in a given month.
+CREATE UNIQUE INDEX idx_subscriptions_user_service_start ON subscriptions(user_id, LOWER(service_name), start_date);
@@ -0,0 +1,8 @@
+DELETE FROM subscriptions WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_subscriptions_user_service_start;
+CREATE UNIQUE INDEX idx_subscriptions_user_service_start ON subscriptions(user_id, LOWER(service_name), start_date);
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL;
+
+ALTER TABLE subscriptions DROP COLUMN deleted_at;
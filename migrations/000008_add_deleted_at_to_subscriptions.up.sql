@@ -0,0 +1,7 @@
+ALTER TABLE subscriptions ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Soft-deleted subscriptions must not block new ones.
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL AND deleted_at IS NULL;
+DROP INDEX idx_subscriptions_user_service_start;
+CREATE UNIQUE INDEX idx_subscriptions_user_service_start ON subscriptions(user_id, LOWER(service_name), start_date) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+-- Existing rows have not been modified as far as we know.
+UPDATE subscriptions SET updated_at = created_at;
@@ -0,0 +1,5 @@
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL AND deleted_at IS NULL;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+ALTER TABLE subscriptions ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled'));
+ALTER TABLE subscriptions ADD COLUMN cancelled_at TIMESTAMPTZ;
+
+-- A cancelled subscription no longer blocks a new active one.
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL AND deleted_at IS NULL AND status <> 'cancelled';
@@ -0,0 +1,4 @@
+UPDATE subscriptions SET status = 'active' WHERE status IN ('trialing', 'paused', 'expired');
+
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_status_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check CHECK (status IN ('active', 'cancelled'));
@@ -0,0 +1,5 @@
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_status_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check CHECK (status IN ('active', 'trialing', 'paused', 'cancelled', 'expired'));
+
+-- Subscriptions that ended before the current month have expired.
+UPDATE subscriptions SET status = 'expired' WHERE status = 'active' AND end_date < date_trunc('month', now());
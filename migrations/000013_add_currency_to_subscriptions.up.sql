@@ -0,0 +1,3 @@
+-- Existing subscriptions are backfilled with the default currency
+-- (CURRENCY_DEFAULT defaults to RUB as well).
+ALTER TABLE subscriptions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
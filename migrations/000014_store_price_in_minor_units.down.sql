@@ -0,0 +1,3 @@
+UPDATE subscriptions SET price_minor = ROUND(price_minor / 100.0);
+ALTER TABLE subscriptions RENAME COLUMN price_minor TO price;
+ALTER TABLE subscriptions ALTER COLUMN price TYPE INT;
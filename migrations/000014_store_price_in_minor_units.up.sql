@@ -0,0 +1,5 @@
+-- Prices used to be whole rubles; they are now stored in minor units
+-- (kopecks, cents).
+ALTER TABLE subscriptions ALTER COLUMN price TYPE BIGINT;
+ALTER TABLE subscriptions RENAME COLUMN price TO price_minor;
+UPDATE subscriptions SET price_minor = price_minor * 100;
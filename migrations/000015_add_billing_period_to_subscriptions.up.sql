@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN billing_period VARCHAR(16) NOT NULL DEFAULT 'monthly' CHECK (billing_period IN ('monthly', 'yearly', 'weekly'));
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_discount_check;
+ALTER TABLE subscriptions DROP COLUMN discount_until;
+ALTER TABLE subscriptions DROP COLUMN discount_percent;
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN discount_percent SMALLINT CHECK (discount_percent BETWEEN 0 AND 100);
+ALTER TABLE subscriptions ADD COLUMN discount_until DATE CHECK (discount_until >= start_date);
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_discount_check CHECK ((discount_percent IS NULL) = (discount_until IS NULL));
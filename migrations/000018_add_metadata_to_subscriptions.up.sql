@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(metadata) = 'object');
+CREATE INDEX idx_subscriptions_metadata ON subscriptions USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN notes TEXT CHECK (char_length(notes) <= 2000);
@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions DROP COLUMN service_id;
+DROP TABLE IF EXISTS services;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS services (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    url TEXT,
+    category VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX idx_services_lower_name ON services(LOWER(name));
+
+ALTER TABLE subscriptions ADD COLUMN service_id UUID REFERENCES services(id);
+CREATE INDEX idx_subscriptions_service_id ON subscriptions(service_id);
@@ -0,0 +1,5 @@
+SELECT set_config('audit_log.erasure', 'on', true);
+DELETE FROM audit_log WHERE subscription_id IS NULL;
+ALTER TABLE audit_log ALTER COLUMN subscription_id SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'audit_log is append-only'; END; $$ LANGUAGE plpgsql;
@@ -0,0 +1,12 @@
+ALTER TABLE audit_log ALTER COLUMN subscription_id DROP NOT NULL;
+
+-- Deletes are let through for the transaction erasing a user's data, which
+-- sets audit_log.erasure first.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('audit_log.erasure', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_anonymizations;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS anonymized_at;
@@ -0,0 +1,9 @@
+ALTER TABLE subscriptions ADD COLUMN anonymized_at TIMESTAMPTZ;
+
+-- Maps anonymized users to the synthetic user their subscriptions were
+-- moved to. Irreversible anonymizations leave no row here.
+CREATE TABLE IF NOT EXISTS user_anonymizations (
+    user_id UUID PRIMARY KEY,
+    synthetic_user_id UUID NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- Subscriptions that ended long ago are moved here to keep the
+-- subscriptions table small. Columns added to subscriptions must be added
+-- here as well.
+CREATE TABLE IF NOT EXISTS subscriptions_archive (LIKE subscriptions INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+ALTER TABLE subscriptions_archive ADD PRIMARY KEY (id);
+CREATE INDEX idx_subscriptions_archive_user_id ON subscriptions_archive(user_id);
@@ -0,0 +1,22 @@
+ALTER TABLE user_anonymizations DROP CONSTRAINT user_anonymizations_pkey;
+ALTER TABLE user_anonymizations ADD PRIMARY KEY (user_id);
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+
+DROP INDEX idx_services_lower_name;
+CREATE UNIQUE INDEX idx_services_lower_name ON services(LOWER(name));
+DROP INDEX idx_subscriptions_archive_tenant_user_id;
+CREATE INDEX idx_subscriptions_archive_user_id ON subscriptions_archive(user_id);
+DROP INDEX idx_subscriptions_user_service_start;
+CREATE UNIQUE INDEX idx_subscriptions_user_service_start ON subscriptions(user_id, LOWER(service_name), start_date) WHERE deleted_at IS NULL;
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(user_id, LOWER(service_name)) WHERE end_date IS NULL AND deleted_at IS NULL AND status <> 'cancelled';
+DROP INDEX idx_subscriptions_tenant_user_id;
+CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
+
+ALTER TABLE user_anonymizations DROP COLUMN tenant_id;
+ALTER TABLE audit_log DROP COLUMN tenant_id;
+ALTER TABLE idempotency_keys DROP COLUMN tenant_id;
+ALTER TABLE services DROP COLUMN tenant_id;
+ALTER TABLE subscriptions_archive DROP COLUMN tenant_id;
+ALTER TABLE subscriptions DROP COLUMN tenant_id;
@@ -0,0 +1,30 @@
+-- Existing data belongs to the default tenant. New rows always name their
+-- tenant, so the default is dropped again.
+ALTER TABLE subscriptions ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE subscriptions ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE subscriptions_archive ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE subscriptions_archive ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE services ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE services ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE idempotency_keys ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE idempotency_keys ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE audit_log ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE audit_log ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE user_anonymizations ADD COLUMN tenant_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001';
+ALTER TABLE user_anonymizations ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX idx_subscriptions_user_id;
+CREATE INDEX idx_subscriptions_tenant_user_id ON subscriptions(tenant_id, user_id);
+DROP INDEX idx_subscriptions_active_user_service;
+CREATE UNIQUE INDEX idx_subscriptions_active_user_service ON subscriptions(tenant_id, user_id, LOWER(service_name)) WHERE end_date IS NULL AND deleted_at IS NULL AND status <> 'cancelled';
+DROP INDEX idx_subscriptions_user_service_start;
+CREATE UNIQUE INDEX idx_subscriptions_user_service_start ON subscriptions(tenant_id, user_id, LOWER(service_name), start_date) WHERE deleted_at IS NULL;
+DROP INDEX idx_subscriptions_archive_user_id;
+CREATE INDEX idx_subscriptions_archive_tenant_user_id ON subscriptions_archive(tenant_id, user_id);
+DROP INDEX idx_services_lower_name;
+CREATE UNIQUE INDEX idx_services_lower_name ON services(tenant_id, LOWER(name));
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (tenant_id, key);
+ALTER TABLE user_anonymizations DROP CONSTRAINT user_anonymizations_pkey;
+ALTER TABLE user_anonymizations ADD PRIMARY KEY (tenant_id, user_id);
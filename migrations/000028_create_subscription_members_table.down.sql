@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS subscription_members_cleanup ON subscriptions_archive;
+DROP TRIGGER IF EXISTS subscription_members_cleanup ON subscriptions;
+DROP FUNCTION IF EXISTS subscription_members_cleanup();
+DROP TABLE IF EXISTS subscription_members;
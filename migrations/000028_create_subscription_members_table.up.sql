@@ -0,0 +1,26 @@
+-- Users sharing a subscription with its owner, who keeps the remainder of
+-- the price. There is no foreign key to subscriptions because archiving
+-- moves subscriptions to another table; members are removed by the
+-- trigger below once their subscription is gone from both.
+CREATE TABLE IF NOT EXISTS subscription_members (
+    subscription_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    tenant_id UUID NOT NULL,
+    share_percent SMALLINT NOT NULL CHECK (share_percent BETWEEN 1 AND 100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, user_id)
+);
+CREATE INDEX idx_subscription_members_tenant_user_id ON subscription_members(tenant_id, user_id);
+
+CREATE FUNCTION subscription_members_cleanup() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subscriptions WHERE id = OLD.id)
+        AND NOT EXISTS (SELECT 1 FROM subscriptions_archive WHERE id = OLD.id) THEN
+        DELETE FROM subscription_members WHERE subscription_id = OLD.id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER subscription_members_cleanup AFTER DELETE ON subscriptions FOR EACH ROW EXECUTE FUNCTION subscription_members_cleanup();
+CREATE TRIGGER subscription_members_cleanup AFTER DELETE ON subscriptions_archive FOR EACH ROW EXECUTE FUNCTION subscription_members_cleanup();
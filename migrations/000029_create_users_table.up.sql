@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users (
+    tenant_id UUID NOT NULL,
+    id UUID NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, id)
+);
+
+-- Every user_id in use becomes a user of the tenant it is used in.
+INSERT INTO users (tenant_id, id)
+SELECT tenant_id, user_id FROM subscriptions
+UNION
+SELECT tenant_id, user_id FROM subscriptions_archive
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_user FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE RESTRICT;
@@ -0,0 +1,8 @@
+-- Tenants listed here override the configured maximum number of active
+-- subscriptions per user.
+CREATE TABLE IF NOT EXISTS tenant_quotas (
+    tenant_id UUID PRIMARY KEY,
+    max_active_subscriptions_per_user INTEGER NOT NULL CHECK (max_active_subscriptions_per_user > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS budgets (
+    tenant_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    amount_minor BIGINT NOT NULL CHECK (amount_minor >= 0),
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    period VARCHAR(16) NOT NULL DEFAULT 'monthly' CHECK (period IN ('monthly')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, user_id),
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    tenant_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    remind_before_renewal_days SMALLINT NOT NULL CHECK (remind_before_renewal_days BETWEEN 1 AND 90),
+    email_enabled BOOLEAN NOT NULL,
+    webhook_url VARCHAR(2048),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, user_id),
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- One unit of base is worth rate units of quote as of the given day. Rates
+-- are shared by all tenants.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base CHAR(3) NOT NULL CHECK (base ~ '^[A-Z]{3}$'),
+    quote CHAR(3) NOT NULL CHECK (quote ~ '^[A-Z]{3}$'),
+    as_of DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (base, quote, as_of),
+    CHECK (base <> quote)
+);